@@ -0,0 +1,78 @@
+// Copyright © 2018 Joel Rebello <joel.rebello@booking.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/butler"
+)
+
+// auditCmd represents the audit command
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Compare bmc config with the declared config, without applying changes.",
+	Run: func(cmd *cobra.Command, args []string) {
+		audit()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+}
+
+func audit() {
+
+	runConfig.Audit = true
+	validateConfigureArgs()
+
+	inventoryChan, butlerChan, stopChan := pre()
+
+	//Read in BMC configuration data
+	assetConfig, err := readAssetConfig()
+	if err != nil {
+		log.Fatal("Unable to read BMC configuration: ", viper.GetString("bmcCfgDir"), " Error: ", err)
+		os.Exit(1)
+	}
+
+	//iterate over the inventory channel for assets,
+	//create a butler message for each asset along with the configuration to compare against.
+loop:
+	for {
+		select {
+		case assetList, ok := <-inventoryChan:
+			if !ok {
+				break loop
+			}
+			for _, asset := range assetList {
+				asset.Audit = true
+				butlerMsg := butler.Msg{Asset: asset, AssetConfig: assetConfig}
+				// butlers stop receiving assets once the stop channel is closed.
+				select {
+				case butlerChan <- butlerMsg:
+				case <-stopChan:
+					break loop
+				}
+			}
+		case <-stopChan:
+			break loop
+		}
+	}
+
+	post(butlerChan, stopChan)
+}
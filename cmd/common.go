@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/signal"
@@ -8,26 +9,81 @@ import (
 	"sync"
 	"syscall"
 
+	"github.com/spf13/viper"
+
 	"github.com/bmc-toolbox/bmcbutler/pkg/asset"
 	"github.com/bmc-toolbox/bmcbutler/pkg/butler"
+	"github.com/bmc-toolbox/bmcbutler/pkg/checkpoint"
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+	"github.com/bmc-toolbox/bmcbutler/pkg/credentials"
 	"github.com/bmc-toolbox/bmcbutler/pkg/inventory"
+	"github.com/bmc-toolbox/bmcbutler/pkg/metrics"
+	"github.com/bmc-toolbox/bmcbutler/pkg/report"
+	"github.com/bmc-toolbox/bmcbutler/pkg/resource"
 	"github.com/bmc-toolbox/bmcbutler/pkg/secrets"
-	metrics "github.com/bmc-toolbox/gin-go-metrics"
+	"github.com/bmc-toolbox/bmcbutler/pkg/signer"
+	"github.com/bmc-toolbox/bmcbutler/pkg/state"
 )
 
 var (
-	butlers   *butler.Butler
-	commandWG sync.WaitGroup
-	interrupt bool
+	butlers         *butler.Butler
+	commandWG       sync.WaitGroup
+	runReport       *report.Collector
+	inventorySource inventory.Source
+	stopOnce        sync.Once
 )
 
+// halt closes the stop channel to have all go routines exit,
+// the stop channel is closed once, on an interrupt signal or when a rollout is halted.
+func halt(stopChan chan struct{}) {
+	stopOnce.Do(func() { close(stopChan) })
+}
+
+// interrupted returns true once the stop channel is closed.
+func interrupted(stopChan <-chan struct{}) bool {
+	select {
+	case <-stopChan:
+		return true
+	default:
+		return false
+	}
+}
+
 // post handles clean up actions
 // - closes the butler channel
 // - Waits for all go routines in commandWG to finish.
-func post(butlerChan chan butler.Msg) {
+// - Closes the state store.
+// - Writes the run report if one was declared.
+// - Writes the run checkpoint, marked finished unless interrupted.
+func post(butlerChan chan butler.Msg, stopChan chan struct{}) {
 	close(butlerChan)
 	commandWG.Wait()
 	metrics.Close(true)
+
+	if butlers.State != nil {
+		err := butlers.State.Close()
+		if err != nil {
+			log.Error("Unable to close state store: ", err)
+		}
+	}
+
+	if runReport != nil {
+		err := runReport.Write(runConfig.Report.JSON, runConfig.Report.JUnit)
+		if err != nil {
+			log.Error("Unable to write run report: ", err)
+		}
+	}
+
+	if butlers.Checkpoint != nil {
+		err := butlers.Checkpoint.Close(!interrupted(stopChan))
+		if err != nil {
+			log.Error("Unable to write run checkpoint: ", err)
+		}
+
+		if interrupted(stopChan) {
+			log.Warnf("Run %s interrupted, resume with: bmcbutler configure --resume %s", butlers.Checkpoint.RunID(), butlers.Checkpoint.RunID())
+		}
+	}
 }
 
 // Any flags to override configuration goes here.
@@ -45,6 +101,35 @@ func overrideConfigFromFlags() {
 	}
 
 	runConfig.CfgFile = cfgFile
+}
+
+// Any flags to override configuration that is read in from the config file goes here.
+func overrideLoadedConfigFromFlags() {
+	if reportJSON != "" || reportJUnit != "" {
+		if runConfig.Report == nil {
+			runConfig.Report = &config.Report{}
+		}
+
+		if reportJSON != "" {
+			runConfig.Report.JSON = reportJSON
+		}
+
+		if reportJUnit != "" {
+			runConfig.Report.JUnit = reportJUnit
+		}
+	}
+
+	if serveInterval > 0 {
+		runConfig.Serve.Interval = serveInterval
+	}
+
+	if serveListen != "" {
+		runConfig.Serve.Listen = serveListen
+	}
+
+	if runConfig.Force {
+		log.Info("Invoked with --force, resources unchanged since last applied will be re-applied.")
+	}
 
 	if runConfig.DryRun {
 		log.Info("Invoked with --dryrun.")
@@ -56,119 +141,157 @@ func overrideConfigFromFlags() {
 // - Setup metrics channel
 // - Spawn the metrics forwarder go routine
 // - Setup the inventory channel over which to receive assets
-// - Based on the inventory source (dora/csv), Spawn the asset retriever go routine.
+// - Spawn the asset retriever go routine for the inventory source declared.
+// - Skip assets actioned in the run resumed, if a checkpoint was declared.
 // - Spawn butlers
 // - Return inventory channel, butler channel.
-func pre() (inventoryChan chan []asset.Asset, butlerChan chan butler.Msg, stopChan chan struct{}) {
+func pre() (inventoryChan <-chan []asset.Asset, butlerChan chan butler.Msg, stopChan chan struct{}) {
+
+	butlerChan, stopChan = setup()
+	inventoryChan = retrieveInventory(runConfig, stopChan)
+
+	if butlers.Checkpoint != nil {
+		inventoryChan = checkpointed(butlers.Checkpoint, inventoryChan, stopChan)
+	}
+
+	return inventoryChan, butlerChan, stopChan
+}
+
+// setup loads the configuration, sets up metrics, secrets,
+// spawns the butlers and the go routine to listen to interrupt signals.
+func setup() (butlerChan chan butler.Msg, stopChan chan struct{}) {
 
 	// load config
 	overrideConfigFromFlags()
 	runConfig.Load(runConfig.CfgFile)
+	overrideLoadedConfigFromFlags()
 
 	//Channel used to indicate goroutines to exit.
 	stopChan = make(chan struct{})
 
 	//Initialize metrics collection.
-	err := metrics.Setup(
-		runConfig.Metrics.Client,
-		runConfig.Metrics.Graphite.Host,
-		runConfig.Metrics.Graphite.Port,
-		runConfig.Metrics.Graphite.Prefix,
-		runConfig.Metrics.Graphite.FlushInterval,
-	)
+	err := metrics.Setup(runConfig.Metrics)
 	if err != nil {
 		fmt.Printf("Failed to set up monitoring: %s", err)
 		os.Exit(1)
 	}
 
-	// A channel to receive inventory assets
-	inventoryChan = make(chan []asset.Asset, 5)
-
-	//determine inventory to fetch asset data.
-	inventorySource := runConfig.Inventory.Source
-
-	//based on inventory source, invoke assetRetriever
-	var assetRetriever func()
-
-	switch inventorySource {
-	case "enc":
-		inventoryInstance := inventory.Enc{
-			Config:     runConfig,
-			Log:        log,
-			BatchSize:  10,
-			AssetsChan: inventoryChan,
-			StopChan:   stopChan,
-		}
-
-		assetRetriever = inventoryInstance.AssetRetrieve()
-	case "csv":
-		inventoryInstance := inventory.Csv{
-			Config:     runConfig,
-			Log:        log,
-			AssetsChan: inventoryChan,
-		}
-
-		assetRetriever = inventoryInstance.AssetRetrieve()
-	case "dora":
-		inventoryInstance := inventory.Dora{
-			Config:     runConfig,
-			Log:        log,
-			BatchSize:  10,
-			AssetsChan: inventoryChan,
-		}
-
-		assetRetriever = inventoryInstance.AssetRetrieve()
-	case "iplist":
-		inventoryInstance := inventory.IPList{
-			Channel:   inventoryChan,
-			Config:    runConfig,
-			BatchSize: 1,
-			Log:       log,
-		}
-
-		assetRetriever = inventoryInstance.AssetRetrieve()
-	default:
-		fmt.Println("Unknown/no inventory source declared in cfg: ", inventorySource)
+	//Set up the inventory source declared in config.
+	inventorySource, err = inventory.New(runConfig, log)
+	if err != nil {
+		fmt.Printf("Failed to set up inventory source: %s\n", err)
 		os.Exit(1)
 	}
 
-	//invoke asset retriever routine
-	//this routine returns assets over the inventoryChan.
-	go assetRetriever()
-
 	// Spawn butlers to work
 	butlerChan = make(chan butler.Msg, 2)
 
 	butlers = &butler.Butler{
 		ButlerChan: butlerChan,
-		StopChan:   stopChan,
 		Config:     runConfig,
 		Log:        log,
 		SyncWG:     &commandWG,
 	}
 
+	if runConfig.Report != nil {
+		runReport = report.NewCollector()
+		butlers.Report = runReport
+	}
+
+	// the state store records resources applied and the credentials that worked on assets.
+	if runConfig.State != nil && !runConfig.DryRun {
+		store, err := state.Open(runConfig.State.File)
+		if err != nil {
+			log.Fatalf("[Error] %s", err.Error())
+		}
+
+		butlers.State = store
+	}
+
+	// configure runs are checkpointed to be resumed if interrupted.
+	if runConfig.Checkpoint != nil && runConfig.Configure && !runConfig.DryRun {
+		butlers.Checkpoint = setupCheckpoint()
+	}
+
+	// the vault client is shared by the secrets store, the vault cert signer, credential rotation and per asset credentials,
+	// its token is kept valid for long runs.
+	var vaultClient *secrets.Client
+	if runConfig.VaultRequired() {
+		vaultClient, err = secrets.NewClient(*runConfig.Vault)
+		if err != nil {
+			log.Fatalf("[Error] setting up vault client: %s", err.Error())
+		}
+
+		go vaultClient.Renew(log, stopChan)
+		butlers.Vault = vaultClient
+	}
+
 	// load secrets from vault
+	var vaultStore *secrets.Store
 	if runConfig.SecretsFromVault {
-
-		store, err := secrets.Load(*runConfig.Vault)
+		vaultStore, err = secrets.Load(vaultClient)
 		if err != nil {
 			log.Fatalf("[Error] loading secrets from vault: %s", err.Error())
 		}
+	}
 
-		runConfig.Credentials, err = store.SetCredentials(runConfig.Credentials)
+	// secrets are looked up from the providers declared, and vault.
+	secretsProvider, err := secrets.New(runConfig.Secrets, vaultStore)
+	if err != nil {
+		log.Fatalf("[Error] setting up secrets providers: %s", err.Error())
+	}
+
+	if secretsProvider != nil {
+		runConfig.Credentials, err = secrets.SetCredentials(secretsProvider, runConfig.Credentials)
 		if err != nil {
-			log.Fatalf("[Error] loading secrets from vault: %s", err.Error())
+			log.Fatalf("[Error] loading secrets: %s", err.Error())
+		}
+
+		if runConfig.CertSigner != nil && runConfig.CertSigner.LemurSigner != nil &&
+			strings.HasPrefix(runConfig.CertSigner.LemurSigner.Key, "lookup_secret::") {
+			runConfig.CertSigner.LemurSigner.Key, err = secrets.GetSignerToken(secretsProvider, runConfig.CertSigner.LemurSigner.Key)
+			if err != nil {
+				log.Fatalf("[Error] loading secrets: %s", err.Error())
+			}
+		}
+
+		if strings.HasPrefix(runConfig.Serve.Token, "lookup_secret::") {
+			runConfig.Serve.Token, err = secrets.GetSignerToken(secretsProvider, runConfig.Serve.Token)
+			if err != nil {
+				log.Fatalf("[Error] loading secrets: %s", err.Error())
+			}
 		}
 
-		runConfig.CertSigner.LemurSigner.Key, err = store.GetSignerToken(runConfig.CertSigner.LemurSigner.Key)
+		if runConfig.AssetCredentials != nil {
+			for vendor, vendorCredentials := range runConfig.AssetCredentials.Vendors {
+				runConfig.AssetCredentials.Vendors[vendor], err = secrets.SetCredentials(secretsProvider, vendorCredentials)
+				if err != nil {
+					log.Fatalf("[Error] loading secrets: %s", err.Error())
+				}
+			}
+		}
+
+		butlers.Secrets = secretsProvider
+	}
+
+	// credentials are resolved per asset, the credential that worked on an asset is recorded in the state store.
+	butlers.Creds = credentials.NewResolver(runConfig.AssetCredentials, runConfig.Credentials, butlers.Secrets, vaultClient, butlers.State, log)
+
+	// the cert signer is only of use when configuration is applied.
+	if runConfig.CertSigner != nil && runConfig.Configure {
+		certSigner, err := signer.New(runConfig.CertSigner, vaultClient, butlers.Secrets, log)
 		if err != nil {
-			log.Fatalf("[Error] loading secrets from vault: %s", err.Error())
+			log.Fatalf("[Error] setting up cert signer: %s", err.Error())
 		}
 
-		butlers.Secrets = store
+		butlers.Signer = certSigner
 	}
 
-	go butlers.Runner()
+	// assets being actioned are abandoned once the stop channel is closed.
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() { <-stopChan; cancel() }()
+
+	go butlers.Runner(ctx)
 	commandWG.Add(1)
 
 	//setup a sigchan
@@ -177,13 +300,105 @@ func pre() (inventoryChan chan []asset.Asset, butlerChan chan butler.Msg, stopCh
 	go func() {
 		select {
 		case <-sigChan:
-			interrupt = true
 			log.Warn("Interrupt SIGINT/SIGTERM received.")
-			close(stopChan)
+			halt(stopChan)
 		case <-stopChan:
 			return
 		}
 	}()
 
-	return inventoryChan, butlerChan, stopChan
+	return butlerChan, stopChan
+}
+
+// setupCheckpoint returns the checkpoint recorder for the run, or the run resumed,
+// when just the failures of the run resumed are retried, the inventory is filtered by their serials.
+func setupCheckpoint() *checkpoint.Recorder {
+
+	if runConfig.Resume == "" {
+		recorder, err := checkpoint.New(runConfig.Checkpoint, "configure")
+		if err != nil {
+			log.Fatalf("[Error] %s", err.Error())
+		}
+
+		log.Infof("Run %s checkpointed, if interrupted resume with --resume %s", recorder.RunID(), recorder.RunID())
+		return recorder
+	}
+
+	recorder, err := checkpoint.Resume(runConfig.Checkpoint, runConfig.Resume)
+	if err != nil {
+		log.Fatalf("[Error] %s", err.Error())
+	}
+
+	if runConfig.RetryFailed {
+		failed := recorder.Failed()
+		if len(failed) == 0 {
+			log.Infof("No assets failed in run %s, nothing to retry.", runConfig.Resume)
+			os.Exit(0)
+		}
+
+		runConfig.FilterParams.Serials = strings.Join(failed, ",")
+		log.Infof("Retrying %d assets that failed in run %s.", len(failed), runConfig.Resume)
+		return recorder
+	}
+
+	log.Infof("Resuming run %s, assets already actioned in the run are skipped.", runConfig.Resume)
+	return recorder
+}
+
+// checkpointed returns an inventory channel of the assets not yet actioned in the checkpointed run,
+// assets are skipped by serial, since inventory sources don't return assets in a stable order,
+// the inventory position of each asset read is recorded for the checkpoint offset, a progress hint.
+func checkpointed(recorder *checkpoint.Recorder, inventoryChan <-chan []asset.Asset, stopChan <-chan struct{}) <-chan []asset.Asset {
+
+	assetsChan := make(chan []asset.Asset)
+
+	go func() {
+		defer close(assetsChan)
+
+		var position int
+		for assetList := range inventoryChan {
+			pending := make([]asset.Asset, 0, len(assetList))
+			for _, a := range assetList {
+				switch {
+				case runConfig.RetryFailed:
+					// the inventory is filtered by the failed serials, their positions are of no use.
+					if !recorder.Completed(a.Serial) {
+						pending = append(pending, a)
+					}
+				case recorder.Actioned(a.Serial):
+					recorder.Skip(position)
+				default:
+					recorder.Read(a.Serial, position)
+					pending = append(pending, a)
+				}
+
+				position++
+			}
+
+			if len(pending) == 0 {
+				continue
+			}
+
+			select {
+			case assetsChan <- pending:
+			case <-stopChan:
+				return
+			}
+		}
+	}()
+
+	return assetsChan
+}
+
+// retrieveInventory spawns the asset retriever go routine for the declared inventory source,
+// assets are filtered by the given config filter params and locations,
+// the returned inventory channel is closed by the retriever once all assets have been sent.
+func retrieveInventory(cfg *config.Params, stopChan chan struct{}) (inventoryChan <-chan []asset.Asset) {
+	return inventory.Retrieve(inventorySource, inventory.NewFilter(cfg), stopChan, log)
+}
+
+// readAssetConfig reads in the BMC configuration templates from the bmcCfgDir,
+// the configuration.yml or the base.yml along with the location, vendor, model, serial layers.
+func readAssetConfig() (*resource.Templates, error) {
+	return resource.ReadTemplates(viper.GetString("bmcCfgDir"))
 }
@@ -15,14 +15,17 @@
 package cmd
 
 import (
-	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
 	"github.com/bmc-toolbox/bmcbutler/pkg/butler"
-	"github.com/bmc-toolbox/bmcbutler/pkg/resource"
+)
+
+var (
+	resume      string
+	retryFailed bool
 )
 
 // configureCmd represents the configure command
@@ -36,12 +39,26 @@ var configureCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(configureCmd)
+
+	configureCmd.Flags().IntVarP(&configureRollout.canary, "canary", "", 0, "Configure the given number of assets first, then wait for --confirm or --soak before the rest.")
+	configureCmd.Flags().DurationVarP(&configureRollout.soak, "soak", "", 0, "Time to wait after a rollout stage before the next stage e.g 15m.")
+	configureCmd.Flags().BoolVarP(&configureRollout.confirm, "confirm", "", false, "Prompt for confirmation after a rollout stage before the next stage.")
+	configureCmd.Flags().BoolVarP(&configureRollout.byLocation, "stage-by-location", "", false, "Configure assets in rollout stages, a location at a time.")
+	configureCmd.Flags().IntVarP(&configureRollout.maxFailures, "max-failures", "", 0, "Halt once more than the given number of assets failed to configure.")
+	configureCmd.Flags().StringVarP(&resume, "resume", "", "", "Resume the interrupted run with the given run ID, skipping assets already actioned (see checkpoint directive in config).")
+	configureCmd.Flags().BoolVarP(&retryFailed, "retry-failed", "", false, "With --resume, configure just the assets that failed in the run.")
+	configureCmd.Flags().Float64VarP(&configureRollout.maxFailureRate, "max-failure-rate", "", 0, "Halt once the ratio of assets that failed to configure exceeds the given rate e.g 0.1")
 }
 
 func validateConfigureArgs() {
 
-	//one of these args are required
-	if !runConfig.FilterParams.All &&
+	if runConfig.RetryFailed {
+		validateRetryFailedArgs()
+	}
+
+	//one of these args are required, unless the assets are identified by the run checkpoint.
+	if !runConfig.RetryFailed &&
+		!runConfig.FilterParams.All &&
 		!runConfig.FilterParams.Chassis &&
 		!runConfig.FilterParams.Servers &&
 		runConfig.FilterParams.Serials == "" &&
@@ -56,27 +73,74 @@ func validateConfigureArgs() {
 		os.Exit(1)
 	}
 
+	if configureRollout.maxFailureRate < 0 || configureRollout.maxFailureRate > 1 {
+		log.Error("--max-failure-rate expected to be between 0 and 1.")
+		os.Exit(1)
+	}
+
+	if configureRollout.confirm && configureRollout.soak > 0 {
+		log.Error("--confirm --soak are mutually exclusive args.")
+		os.Exit(1)
+	}
+
+	if runConfig.Resume != "" && runConfig.DryRun {
+		log.Error("--resume --dryrun are mutually exclusive args.")
+		os.Exit(1)
+	}
+}
+
+// validateRetryFailedArgs validates the args when just the failures of a run are retried,
+// the assets are identified by the run checkpoint, so no filter args are expected.
+func validateRetryFailedArgs() {
+
+	if runConfig.Resume == "" {
+		log.Error("--retry-failed expects the run to be declared with --resume.")
+		os.Exit(1)
+	}
+
+	if runConfig.FilterParams.All ||
+		runConfig.FilterParams.Chassis ||
+		runConfig.FilterParams.Servers ||
+		runConfig.FilterParams.Serials != "" ||
+		runConfig.FilterParams.Ips != "" {
+
+		log.Error("--retry-failed --all/--chassis/--servers/--serials/--ips are mutually exclusive args.")
+		os.Exit(1)
+	}
 }
 
 func configure() {
 
 	runConfig.Configure = true
+	runConfig.Resume = resume
+	runConfig.RetryFailed = retryFailed
 	validateConfigureArgs()
 
 	inventoryChan, butlerChan, stopChan := pre()
 
 	//Read in BMC configuration data
-	assetConfigDir := viper.GetString("bmcCfgDir")
-	assetConfigFile := fmt.Sprintf("%s/%s", assetConfigDir, "configuration.yml")
-
-	//returns the file read as a slice of bytes
 	//config may contain templated values.
-	assetConfig, err := resource.ReadYamlTemplate(assetConfigFile)
+	assetConfig, err := readAssetConfig()
 	if err != nil {
-		log.Fatal("Unable to read BMC configuration: ", assetConfigFile, " Error: ", err)
+		log.Fatal("Unable to read BMC configuration: ", viper.GetString("bmcCfgDir"), " Error: ", err)
 		os.Exit(1)
 	}
 
+	if !runConfig.SkipValidate && !validAssetConfig(assetConfig) {
+		log.Fatal("BMC configuration invalid, not applied (see bmcbutler validate --help).")
+	}
+
+	//halt the rollout if the failure thresholds are exceeded.
+	done := make(chan struct{})
+	go configureRollout.monitor(stopChan, done)
+	defer close(done)
+
+	if configureRollout.staged() {
+		configureRollout.run(inventoryChan, butlerChan, stopChan, assetConfig)
+		post(butlerChan, stopChan)
+		return
+	}
+
 	//iterate over the inventory channel for assets,
 	//create a butler message for each asset along with the configuration,
 	//at this point templated values in the config are not yet rendered.
@@ -90,16 +154,17 @@ loop:
 			for _, asset := range assetList {
 				asset.Configure = true
 				butlerMsg := butler.Msg{Asset: asset, AssetConfig: assetConfig}
-				if interrupt {
+				// butlers stop receiving assets once the stop channel is closed.
+				select {
+				case butlerChan <- butlerMsg:
+				case <-stopChan:
 					break loop
 				}
-
-				butlerChan <- butlerMsg
 			}
 		case <-stopChan:
-			interrupt = true
+			break loop
 		}
 	}
 
-	post(butlerChan)
+	post(butlerChan, stopChan)
 }
@@ -15,12 +15,19 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
+	"strings"
+
 	"github.com/spf13/cobra"
 
 	"github.com/bmc-toolbox/bmcbutler/pkg/butler"
+	"github.com/bmc-toolbox/bmcbutler/pkg/butler/command"
 )
 
-// configureCmd represents the configure command
+var execArgs string
+
+// executeCmd represents the execute command
 var executeCmd = &cobra.Command{
 	Use:   "execute",
 	Short: "Execute actions on bmcs.",
@@ -31,23 +38,66 @@ var executeCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(executeCmd)
+
+	executeCmd.Flags().StringVarP(&execArgs, "args", "", "", "Arguments to the command as key=value pairs (separated by commas - no spaces) e.g --args position=3")
+
+	// list the registered commands in the help text.
+	var commands []string
+	for _, c := range command.List() {
+		commands = append(commands, fmt.Sprintf("  %-18s %s", c.Name, c.Description))
+	}
+
+	executeCmd.Long = fmt.Sprintf("Execute actions on bmcs.\n\nCommands (--command):\n%s", strings.Join(commands, "\n"))
+}
+
+// validateExecuteArgs validates the command and its arguments,
+// before any assets are retrieved.
+func validateExecuteArgs() command.Args {
+
+	cmd, err := command.Get(execCommand)
+	if err != nil {
+		log.Error(err)
+		os.Exit(1)
+	}
+
+	args, err := command.ParseArgs(execArgs)
+	if err != nil {
+		log.Error(err)
+		os.Exit(1)
+	}
+
+	err = cmd.ValidateArgs(args)
+	if err != nil {
+		log.Errorf("Command %s: %s", execCommand, err)
+		os.Exit(1)
+	}
+
+	return args
 }
 
 func execute() {
 
 	runConfig.Execute = true
-	inventoryChan, butlerChan, _ := pre()
+	args := validateExecuteArgs()
+
+	inventoryChan, butlerChan, stopChan := pre()
 
 	//iterate over the inventory channel for assets,
-	//create a butler message for each asset along with the configuration,
-	//at this point templated values in the config are not yet rendered.
+	//create a butler message for each asset along with the command to execute,
+	//butlers stop receiving assets once the stop channel is closed.
+loop:
 	for assetList := range inventoryChan {
 		for _, asset := range assetList {
 			asset.Execute = true
-			butlerMsg := butler.Msg{Asset: asset, AssetExecute: execCommand}
-			butlerChan <- butlerMsg
+			butlerMsg := butler.Msg{Asset: asset, AssetExecute: execCommand, ExecuteArgs: args}
+
+			select {
+			case butlerChan <- butlerMsg:
+			case <-stopChan:
+				break loop
+			}
 		}
 	}
 
-	post(butlerChan)
+	post(butlerChan, stopChan)
 }
@@ -0,0 +1,197 @@
+// Copyright © 2018 Joel Rebello <joel.rebello@booking.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/asset"
+	"github.com/bmc-toolbox/bmcbutler/pkg/inventory"
+	"github.com/bmc-toolbox/bmcbutler/pkg/resource"
+)
+
+var (
+	renderAsset      asset.Asset
+	renderExtra      string
+	renderProvenance bool
+)
+
+// renderCmd represents the render command
+var renderCmd = &cobra.Command{
+	Use:   "render",
+	Short: "Print the rendered BMC configuration for assets, without connecting to them.",
+	Long: `Print the rendered BMC configuration and the resources that would be applied for assets,
+without connecting to the BMCs, secrets and password, token values are masked.
+
+Assets are retrieved from the inventory (--all/--chassis/--servers/--serials/--ips),
+or declared by flags (--vendor, --model, --serial, --asset-type, --location, --extra).
+
+  bmcbutler render --vendor dell --model idrac9 --asset-type server --location ams2 --extra company=acme
+  bmcbutler render --serials <serial>
+
+bmcbutler exits non zero if the configuration fails to render for an asset.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		render()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(renderCmd)
+
+	renderCmd.Flags().StringVarP(&renderAsset.Vendor, "vendor", "", "", "Vendor of the asset to render the configuration for e.g dell")
+	renderCmd.Flags().StringVarP(&renderAsset.Model, "model", "", "", "Model of the asset to render the configuration for e.g idrac9")
+	renderCmd.Flags().StringVarP(&renderAsset.Serial, "serial", "", "", "Serial of the asset to render the configuration for.")
+	renderCmd.Flags().StringVarP(&renderAsset.Type, "asset-type", "", "server", "Type of the asset to render the configuration for, server or chassis.")
+	renderCmd.Flags().StringVarP(&renderAsset.Location, "location", "", "", "Location of the asset to render the configuration for.")
+	renderCmd.Flags().StringVarP(&renderExtra, "extra", "", "", "Extra attributes of the asset to render the configuration for e.g company=acme,state=live")
+	renderCmd.Flags().BoolVarP(&renderProvenance, "provenance", "", false, "List the configuration layer each value was declared in.")
+}
+
+// renderAssetFromFlags returns true if the asset to render was declared by flags.
+func renderAssetFromFlags() bool {
+	return renderAsset.Vendor != "" || renderAsset.Model != "" || renderAsset.Serial != "" ||
+		renderAsset.Location != "" || renderExtra != ""
+}
+
+func render() {
+
+	fromFlags := renderAssetFromFlags()
+	fromInventory := runConfig.FilterParams.All || runConfig.FilterParams.Chassis || runConfig.FilterParams.Servers ||
+		runConfig.FilterParams.Serials != "" || runConfig.FilterParams.Ips != ""
+
+	if fromFlags == fromInventory {
+		log.Error("Expected either asset flags --vendor/--model/--serial/--location/--extra or inventory flags --all/--chassis/--servers/--serials/--ips (try --help)")
+		os.Exit(1)
+	}
+
+	// load config
+	overrideConfigFromFlags()
+	runConfig.Load(runConfig.CfgFile)
+	overrideLoadedConfigFromFlags()
+
+	//Read in BMC configuration data
+	assetConfig, err := readAssetConfig()
+	if err != nil {
+		log.Error("Unable to read BMC configuration: ", viper.GetString("bmcCfgDir"), " Error: ", err)
+		os.Exit(1)
+	}
+
+	var failed int
+	if fromFlags {
+		renderAsset.Extra = make(map[string]string)
+		for _, kv := range strings.Split(renderExtra, ",") {
+			parts := strings.SplitN(kv, "=", 2)
+			if len(parts) != 2 {
+				continue
+			}
+
+			renderAsset.Extra[parts[0]] = parts[1]
+		}
+		if !renderConfig(os.Stdout, renderAsset, assetConfig) {
+			failed++
+		}
+	} else {
+		inventorySource, err = inventory.New(runConfig, log)
+		if err != nil {
+			log.Error("Failed to set up inventory source: ", err)
+			os.Exit(1)
+		}
+
+		for assetList := range retrieveInventory(runConfig, make(chan struct{})) {
+			for _, a := range assetList {
+				if !renderConfig(os.Stdout, a, assetConfig) {
+					failed++
+				}
+			}
+		}
+	}
+
+	if failed > 0 {
+		log.Errorf("Configuration failed to render for %d asset(s).", failed)
+		os.Exit(1)
+	}
+}
+
+// renderConfig writes the rendered configuration for the asset along with the resources that would be applied,
+// returns false if the configuration failed to render.
+func renderConfig(w io.Writer, a asset.Asset, assetConfig *resource.Templates) bool {
+
+	var config *resource.Config
+
+	if a.IPAddress == "" && len(a.IPAddresses) > 0 {
+		a.IPAddress = a.IPAddresses[0]
+	}
+
+	fmt.Fprintf(w, "---\n# serial: %s, ip: %s, vendor: %s, model: %s, type: %s, location: %s\n",
+		a.Serial, a.IPAddress, a.Vendor, a.Model, a.Type, a.Location)
+
+	if a.Error != "" {
+		fmt.Fprintf(w, "# error: inventory: %s\n", a.Error)
+		return false
+	}
+
+	resourceInstance := resource.Resource{Log: log, Asset: &a, MaskSecrets: true}
+
+	rendered, provenance, err := resourceInstance.RenderLayers(assetConfig)
+	if err == nil {
+		config, err = resource.UnmarshalResources(rendered, true)
+	}
+
+	if err != nil {
+		fmt.Fprintf(w, "# error: %s\n", err)
+		log.WithField("Serial", a.Serial).Error("Configuration failed to render: ", err)
+		return false
+	}
+
+	if config == nil {
+		fmt.Fprintf(w, "# resources: none declared\n")
+		return true
+	}
+
+	resources := config.Declared()
+	if len(runConfig.Resources) > 0 {
+		resources = runConfig.Resources
+	}
+
+	fmt.Fprintf(w, "# resources: %s\n", strings.Join(resources, ", "))
+
+	if renderProvenance {
+		var layers []string
+		for _, layer := range assetConfig.Layers(&a) {
+			layers = append(layers, layer.Name)
+		}
+
+		fmt.Fprintf(w, "# layers: %s\n", strings.Join(layers, ", "))
+		for _, key := range provenance.Keys() {
+			fmt.Fprintf(w, "#   %s: %s\n", key, provenance[key])
+		}
+	}
+
+	masked, err := resource.MaskYaml(rendered)
+	if err != nil {
+		fmt.Fprintf(w, "# error: %s\n", err)
+		return false
+	}
+
+	fmt.Fprintf(w, "%s", masked)
+
+	return true
+}
@@ -0,0 +1,351 @@
+// Copyright © 2018 Joel Rebello <joel.rebello@booking.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/asset"
+	"github.com/bmc-toolbox/bmcbutler/pkg/butler"
+	"github.com/bmc-toolbox/bmcbutler/pkg/resource"
+)
+
+// minFailureRateSample is the number of configured assets after which the failure rate is checked,
+// unless a canary was declared, in which case the rate is checked once the canary is done.
+const minFailureRateSample = 10
+
+// rollout holds the staged rollout params for configure,
+// assets are configured in stages, the rollout is halted if the failure thresholds are exceeded.
+type rollout struct {
+	canary         int           //number of assets configured in the first stage.
+	soak           time.Duration //time to wait after a stage before the next stage.
+	confirm        bool          //prompt for confirmation after a stage before the next stage.
+	byLocation     bool          //stage assets by location.
+	maxFailures    int           //halt once more than these assets failed to configure.
+	maxFailureRate float64       //halt once the ratio of assets that failed to configure exceeds this rate.
+}
+
+var configureRollout rollout
+
+// staged returns true if assets are to be configured in stages.
+func (r *rollout) staged() bool {
+	return r.canary > 0 || r.byLocation
+}
+
+// exceeded returns true along with the reason, if the failure thresholds are exceeded.
+func (r *rollout) exceeded(counts butler.Counts) (bool, string) {
+
+	if r.maxFailures > 0 && counts.ConfigureFail > int64(r.maxFailures) {
+		return true, fmt.Sprintf("%d assets failed to configure, max failures %d", counts.ConfigureFail, r.maxFailures)
+	}
+
+	configured := counts.ConfigureSuccess + counts.ConfigureFail
+
+	sample := int64(minFailureRateSample)
+	if r.canary > 0 {
+		sample = int64(r.canary)
+	}
+
+	if r.maxFailureRate > 0 && configured >= sample {
+		rate := float64(counts.ConfigureFail) / float64(configured)
+		if rate > r.maxFailureRate {
+			return true, fmt.Sprintf("failure rate %.2f of %d assets configured, max failure rate %.2f", rate, configured, r.maxFailureRate)
+		}
+	}
+
+	return false, ""
+}
+
+// check halts the rollout if the failure thresholds are exceeded,
+// returns true if the rollout was halted.
+func (r *rollout) check(stopChan chan struct{}) bool {
+
+	exceeded, reason := r.exceeded(butlers.Counts())
+	if !exceeded {
+		return false
+	}
+
+	log.WithFields(logrus.Fields{
+		"component": "rollout",
+		"Reason":    reason,
+	}).Error("Failure threshold exceeded, rollout halted.")
+
+	halt(stopChan)
+
+	return true
+}
+
+// monitor checks the failure thresholds until the done channel is closed or the rollout is halted.
+func (r *rollout) monitor(stopChan chan struct{}, done <-chan struct{}) {
+
+	if r.maxFailures == 0 && r.maxFailureRate == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if r.check(stopChan) {
+				return
+			}
+		case <-done:
+			return
+		case <-stopChan:
+			return
+		}
+	}
+}
+
+// waitForStage waits until the butlers handled the given number of assets,
+// returns false if the rollout was interrupted.
+func (r *rollout) waitForStage(sent int64, stopChan chan struct{}) bool {
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for butlers.Counts().Handled < sent {
+		select {
+		case <-ticker.C:
+		case <-stopChan:
+			return false
+		}
+	}
+
+	return true
+}
+
+// proceed is invoked once a stage is done, it checks the failure thresholds,
+// then waits for the confirmation or the soak time before the next stage,
+// returns false if the rollout is not to proceed.
+func (r *rollout) proceed(stage string, sent int64, stopChan chan struct{}) bool {
+
+	if !r.waitForStage(sent, stopChan) || r.check(stopChan) {
+		return false
+	}
+
+	counts := butlers.Counts()
+	log.WithFields(logrus.Fields{
+		"component": "rollout",
+		"Stage":     stage,
+		"Success":   counts.ConfigureSuccess,
+		"Failed":    counts.ConfigureFail,
+	}).Info("Rollout stage done.")
+
+	if r.confirm {
+		fmt.Printf("Rollout stage %s done, %d assets configured, %d failed. Continue rollout? [y/N]: ",
+			stage, counts.ConfigureSuccess, counts.ConfigureFail)
+
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if answer != "y" && answer != "yes" {
+			log.WithFields(logrus.Fields{
+				"component": "rollout",
+				"Stage":     stage,
+			}).Warn("Rollout not confirmed, halted.")
+			return false
+		}
+
+		return true
+	}
+
+	if r.soak > 0 {
+		log.WithFields(logrus.Fields{
+			"component": "rollout",
+			"Stage":     stage,
+			"Soak":      r.soak,
+		}).Info("Soaking before the next rollout stage.")
+
+		select {
+		case <-time.After(r.soak):
+		case <-stopChan:
+			return false
+		}
+
+		// failures may surface during the soak.
+		if r.check(stopChan) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// canaryStage reads assets from the inventory channel until the canary stage is complete,
+// returns the canary assets, the rest of the assets read along with them,
+// and true if the inventory channel has more assets to be read.
+func (r *rollout) canaryStage(inventoryChan <-chan []asset.Asset, stopChan chan struct{}) (canary []asset.Asset, rest []asset.Asset, more bool) {
+
+	for len(canary) < r.canary {
+		select {
+		case assetList, ok := <-inventoryChan:
+			if !ok {
+				return canary, rest, false
+			}
+
+			for idx, a := range assetList {
+				if len(canary) == r.canary {
+					rest = append(rest, assetList[idx:]...)
+					break
+				}
+
+				canary = append(canary, a)
+			}
+		case <-stopChan:
+			return nil, nil, false
+		}
+	}
+
+	return canary, rest, true
+}
+
+// locationStages reads the rest of the assets from the inventory channel and returns them in a stage per location,
+// the locations are staged in the order they were first read.
+func (r *rollout) locationStages(assets []asset.Asset, inventoryChan <-chan []asset.Asset, more bool, stopChan chan struct{}) (names []string, stages [][]asset.Asset) {
+
+	locations := make(map[string][]asset.Asset)
+	stage := func(assetList []asset.Asset) {
+		for _, a := range assetList {
+			if _, exists := locations[a.Location]; !exists {
+				names = append(names, a.Location)
+			}
+
+			locations[a.Location] = append(locations[a.Location], a)
+		}
+	}
+
+	stage(assets)
+
+	for more {
+		select {
+		case assetList, ok := <-inventoryChan:
+			if !ok {
+				more = false
+				break
+			}
+
+			stage(assetList)
+		case <-stopChan:
+			return nil, nil
+		}
+	}
+
+	for _, name := range names {
+		stages = append(stages, locations[name])
+	}
+
+	return names, stages
+}
+
+// run configures the assets in stages, the assets of a stage are passed to the butlers,
+// once the stage is done the rollout proceeds to the next stage, unless it was halted.
+// The canary is passed to the butlers as soon as its assets are read from the inventory,
+// the rest of the assets are passed on as they are read, unless staged by location,
+// which requires the whole inventory to be read first.
+func (r *rollout) run(inventoryChan <-chan []asset.Asset, butlerChan chan<- butler.Msg, stopChan chan struct{}, assetConfig *resource.Templates) {
+
+	var sent int64
+	send := func(assets []asset.Asset) bool {
+		for _, a := range assets {
+			a.Configure = true
+			select {
+			case butlerChan <- butler.Msg{Asset: a, AssetConfig: assetConfig}:
+				sent++
+			case <-stopChan:
+				return false
+			}
+		}
+
+		return true
+	}
+
+	started := func(stage string, assets int) {
+		log.WithFields(logrus.Fields{
+			"component": "rollout",
+			"Stage":     stage,
+			"Assets":    assets,
+		}).Info("Rollout stage started.")
+	}
+
+	canary, rest, more := r.canaryStage(inventoryChan, stopChan)
+	if interrupted(stopChan) {
+		return
+	}
+
+	if len(canary) > 0 {
+		started("canary", len(canary))
+		if !send(canary) {
+			return
+		}
+
+		// no stage follows a canary that holds all assets.
+		if len(rest) == 0 && !more {
+			return
+		}
+
+		if !r.proceed("canary", sent, stopChan) {
+			return
+		}
+	}
+
+	if !r.byLocation {
+		log.WithFields(logrus.Fields{
+			"component": "rollout",
+			"Stage":     "rest",
+		}).Info("Rollout stage started, assets are passed on as they are read from the inventory.")
+
+		if !send(rest) {
+			return
+		}
+
+		for more {
+			select {
+			case assetList, ok := <-inventoryChan:
+				if !ok {
+					return
+				}
+
+				if !send(assetList) {
+					return
+				}
+			case <-stopChan:
+				return
+			}
+		}
+
+		return
+	}
+
+	names, stages := r.locationStages(rest, inventoryChan, more, stopChan)
+	for idx, stage := range stages {
+		started(names[idx], len(stage))
+		if !send(stage) {
+			return
+		}
+
+		// the last stage needs no confirmation.
+		if idx < len(stages)-1 && !r.proceed(names[idx], sent, stopChan) {
+			return
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/asset"
+	"github.com/bmc-toolbox/bmcbutler/pkg/butler"
+)
+
+// TestRolloutExceeded tests the failure thresholds,
+// the failure rate is only checked once enough assets were configured.
+func TestRolloutExceeded(t *testing.T) {
+
+	cases := []struct {
+		r        rollout
+		counts   butler.Counts
+		exceeded bool
+	}{
+		{rollout{maxFailures: 2}, butler.Counts{ConfigureFail: 2}, false},
+		{rollout{maxFailures: 2}, butler.Counts{ConfigureFail: 3}, true},
+		{rollout{maxFailureRate: 0.2}, butler.Counts{ConfigureSuccess: 2, ConfigureFail: 2}, false},
+		{rollout{maxFailureRate: 0.2}, butler.Counts{ConfigureSuccess: 8, ConfigureFail: 2}, false},
+		{rollout{maxFailureRate: 0.2}, butler.Counts{ConfigureSuccess: 7, ConfigureFail: 3}, true},
+		{rollout{maxFailureRate: 0.2, canary: 4}, butler.Counts{ConfigureSuccess: 2, ConfigureFail: 2}, true},
+		{rollout{}, butler.Counts{ConfigureFail: 100}, false},
+	}
+
+	for idx, c := range cases {
+		exceeded, reason := c.r.exceeded(c.counts)
+		if exceeded != c.exceeded {
+			t.Fatalf("case %d: expected exceeded %t, got %t (%s)", idx, c.exceeded, exceeded, reason)
+		}
+	}
+}
+
+// TestRolloutStages tests assets are staged into the canary,
+// followed by a stage per location or the rest of the assets.
+func TestRolloutStages(t *testing.T) {
+
+	inventory := func() <-chan []asset.Asset {
+		c := make(chan []asset.Asset, 2)
+		c <- []asset.Asset{{Serial: "1", Location: "ams2"}, {Serial: "2", Location: "lhr4"}}
+		c <- []asset.Asset{{Serial: "3", Location: "ams2"}, {Serial: "4", Location: "fra1"}}
+		close(c)
+		return c
+	}
+
+	r := rollout{canary: 1, byLocation: true}
+	inventoryChan := inventory()
+	canary, rest, more := r.canaryStage(inventoryChan, make(chan struct{}))
+	if len(canary) != 1 || canary[0].Serial != "1" || len(rest) != 1 || !more {
+		t.Fatalf("Expected canary of asset 1 with asset 2 read along, got %+v %+v", canary, rest)
+	}
+
+	names, stages := r.locationStages(rest, inventoryChan, more, make(chan struct{}))
+	if len(names) != 3 || names[0] != "lhr4" || names[1] != "ams2" || names[2] != "fra1" {
+		t.Fatalf("Expected lhr4, ams2, fra1 stages, got %v", names)
+	}
+
+	if len(stages[1]) != 1 || stages[1][0].Serial != "3" {
+		t.Fatalf("Unexpected stages %+v", stages)
+	}
+
+	r = rollout{canary: 3}
+	canary, rest, _ = r.canaryStage(inventory(), make(chan struct{}))
+	if len(canary) != 3 || len(rest) != 1 {
+		t.Fatalf("Expected canary of 3 assets and the rest, got %+v %+v", canary, rest)
+	}
+}
+
+// TestRolloutCanaryStreamed tests the canary is staged as soon as its assets are read,
+// without waiting for the rest of the inventory.
+func TestRolloutCanaryStreamed(t *testing.T) {
+
+	inventoryChan := make(chan []asset.Asset)
+	defer close(inventoryChan)
+
+	go func() { inventoryChan <- []asset.Asset{{Serial: "1"}, {Serial: "2"}} }()
+
+	r := rollout{canary: 2}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		canary, _, more := r.canaryStage(inventoryChan, make(chan struct{}))
+		if len(canary) != 2 || !more {
+			t.Errorf("Expected canary of 2 assets with more to be read, got %+v", canary)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the canary to be staged before the inventory was read in full")
+	}
+}
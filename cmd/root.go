@@ -32,6 +32,8 @@ var (
 	cfgFile        string
 	execCommand    string
 	locations      string
+	reportJSON     string
+	reportJUnit    string
 	resources      string
 	runConfig      *config.Params
 )
@@ -99,6 +101,7 @@ func init() {
 	rootCmd.PersistentFlags().BoolVarP(&runConfig.FilterParams.Chassis, "chassis", "", false, "Action just Chassis assets.")
 	rootCmd.PersistentFlags().BoolVarP(&runConfig.FilterParams.Servers, "servers", "", false, "Action just Server assets.")
 	rootCmd.PersistentFlags().BoolVarP(&runConfig.DryRun, "dryrun", "", false, "Only log assets that will be actioned.")
+	rootCmd.PersistentFlags().BoolVarP(&runConfig.Force, "force", "", false, "Apply resources even if unchanged since the last apply (see state directive in config).")
 	rootCmd.PersistentFlags().StringVarP(&runConfig.FilterParams.Serials, "serials", "", "", "Serial(s) of the asset to setup config (separated by commas - no spaces).")
 	rootCmd.PersistentFlags().StringVarP(&runConfig.FilterParams.Ips, "ips", "", "", "IP Address(s) of the asset to setup config (separated by commas - no spaces).")
 
@@ -106,6 +109,8 @@ func init() {
 	rootCmd.PersistentFlags().IntVarP(&butlersToSpawn, "butlers", "b", 0, "Number of butlers to spawn (override butlersToSpawn directive in config)")
 	rootCmd.PersistentFlags().StringVarP(&locations, "locations", "l", "", "Action assets by given location(s). (override locations directive in config)")
 	rootCmd.PersistentFlags().StringVarP(&resources, "resources", "r", "", "Apply one or more resources instead of the whole config (e.g -r syslog,ntp).")
+	rootCmd.PersistentFlags().StringVarP(&reportJSON, "report-json", "", "", "Write a JSON report of actioned assets to the given file (override report.json directive in config)")
+	rootCmd.PersistentFlags().StringVarP(&reportJUnit, "report-junit", "", "", "Write a JUnit XML report of actioned assets to the given file (override report.junit directive in config)")
 	rootCmd.PersistentFlags().StringVarP(&cfgFile, "config", "c", "/etc/bmcbutler/bmcbutler.yml", "Configuration file for bmcbutler (default: /etc/bmcbutler/bmcbutler.yml)")
 
 	//move to exec
@@ -0,0 +1,73 @@
+// Copyright © 2018 Joel Rebello <joel.rebello@booking.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/butler"
+)
+
+// rotateCmd represents the rotate-credentials command
+var rotateCmd = &cobra.Command{
+	Use:   "rotate-credentials",
+	Short: "Rotate the BMC user password, the new password is written to vault.",
+	Long: `Rotate the password of the BMC user declared in the rotate configuration section.
+
+A new password is generated for each asset, set on the BMC and verified by logging in with it,
+the password is then written to the vault KV path <rotate.path>/<serial>.
+If the new password can't be set, verified or written to vault, the previous password is set again.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		rotate()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rotateCmd)
+}
+
+func rotate() {
+
+	runConfig.RotateCreds = true
+	validateConfigureArgs()
+
+	inventoryChan, butlerChan, stopChan := pre()
+
+	//iterate over the inventory channel for assets,
+	//create a butler message for each asset to rotate its credentials.
+loop:
+	for {
+		select {
+		case assetList, ok := <-inventoryChan:
+			if !ok {
+				break loop
+			}
+			for _, asset := range assetList {
+				asset.Rotate = true
+				butlerMsg := butler.Msg{Asset: asset}
+				// butlers stop receiving assets once the stop channel is closed.
+				select {
+				case butlerChan <- butlerMsg:
+				case <-stopChan:
+					break loop
+				}
+			}
+		case <-stopChan:
+			break loop
+		}
+	}
+
+	post(butlerChan, stopChan)
+}
@@ -0,0 +1,282 @@
+// Copyright © 2018 Joel Rebello <joel.rebello@booking.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/api"
+	"github.com/bmc-toolbox/bmcbutler/pkg/asset"
+	"github.com/bmc-toolbox/bmcbutler/pkg/butler"
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+	"github.com/bmc-toolbox/bmcbutler/pkg/metrics"
+	"github.com/bmc-toolbox/bmcbutler/pkg/resource"
+)
+
+var (
+	serveInterval time.Duration
+	serveListen   string
+)
+
+// configCheckInterval is how often the BMC configuration is checked for changes between runs.
+const configCheckInterval = time.Minute
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run as a daemon, periodically apply config to bmcs.",
+	Long: `Run as a daemon, keeping the butlers running.
+
+On each interval the inventory is retrieved from the configured source,
+and the BMC configuration is applied to every asset, correcting any drift.
+The BMC configuration is checked for changes every minute, a change triggers a run
+with the new configuration without a restart.
+A run report, when declared, is written before each run with the records of the previous run.
+Runs are not checkpointed.
+
+When a listen address is declared, an HTTP API is served to submit jobs and query their results,
+the API is served on localhost unless a host is declared, requests present the serve.token declared as a bearer token,
+  POST /api/v1/jobs      {"action": "configure", "serials": ["<serial>"], "resources": ["syslog"]}
+  GET  /api/v1/jobs      list jobs
+  GET  /api/v1/jobs/<id> job status and per asset results`,
+	Run: func(cmd *cobra.Command, args []string) {
+		serve()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().DurationVarP(&serveInterval, "interval", "", 0, "Interval between reconcile runs e.g 30m (override serve.interval directive in config)")
+	serveCmd.Flags().StringVarP(&serveListen, "listen", "", "", "Address to serve the HTTP API on e.g :8080 for localhost (override serve.listen directive in config)")
+}
+
+func serve() {
+
+	component := "serve"
+	runConfig.Configure = true
+	runConfig.Reconcile = true
+
+	//reconcile all assets unless a filter was passed.
+	if !runConfig.FilterParams.Chassis &&
+		!runConfig.FilterParams.Servers &&
+		runConfig.FilterParams.Serials == "" &&
+		runConfig.FilterParams.Ips == "" {
+		runConfig.FilterParams.All = true
+	}
+
+	validateConfigureArgs()
+
+	// serve reconciles all assets on each run, its runs are not checkpointed to be resumed.
+	runConfig.Checkpoint = nil
+
+	butlerChan, stopChan := setup()
+
+	//the current BMC configuration, shared with the API.
+	var currentAssetConfig atomic.Value
+
+	assetConfig, readConfig := reloadAssetConfig(nil)
+	currentAssetConfig.Store(assetConfig)
+
+	var apiServer *api.Server
+	if runConfig.Serve.Listen != "" {
+		listen, err := api.ListenAddress(runConfig.Serve.Listen, runConfig.Serve.Token)
+		if err != nil {
+			log.Fatalf("[Error] %s", err.Error())
+		}
+
+		apiServer = &api.Server{
+			Listen:      listen,
+			Token:       runConfig.Serve.Token,
+			Config:      runConfig,
+			Log:         log,
+			ButlerChan:  butlerChan,
+			StopChan:    stopChan,
+			AssetConfig: func() *resource.Templates { return currentAssetConfig.Load().(*resource.Templates) },
+			Inventory: func(cfg *config.Params) <-chan []asset.Asset {
+				return retrieveInventory(cfg, stopChan)
+			},
+			Report: butlers.Report,
+		}
+
+		//records are added to their API job, then passed on to the run report.
+		butlers.Report = apiServer
+		go apiServer.Run()
+	}
+
+	for {
+		log.WithFields(logrus.Fields{
+			"component": component,
+			"Interval":  runConfig.Serve.Interval,
+		}).Info("Reconcile run started.")
+
+		start := time.Now()
+		count, stopped := reconcile(assetConfig, butlerChan, stopChan)
+		if stopped {
+			break
+		}
+
+		metrics.IncrCounter([]string{"butler", "reconcile_runs"}, 1)
+
+		log.WithFields(logrus.Fields{
+			"component": component,
+			"Assets":    count,
+			"Duration":  time.Since(start).String(),
+			"Next run":  time.Now().Add(runConfig.Serve.Interval).Format(time.RFC3339),
+		}).Info("Reconcile run complete, assets handed to butlers.")
+
+		waitForNextRun(readConfig, stopChan)
+		if interrupted(stopChan) {
+			break
+		}
+
+		flushReport()
+
+		assetConfig, readConfig = reloadAssetConfig(assetConfig)
+		currentAssetConfig.Store(assetConfig)
+	}
+
+	//jobs being dispatched by the API need to return before the butler channel is closed.
+	if apiServer != nil {
+		apiServer.Wait()
+	}
+
+	post(butlerChan, stopChan)
+}
+
+// flushReport writes the run report for the reconcile run, records are collected afresh for the next run,
+// records of assets still being actioned are reported with the next run.
+func flushReport() {
+
+	if runReport == nil {
+		return
+	}
+
+	err := runReport.Flush(runConfig.Report.JSON, runConfig.Report.JUnit)
+	if err != nil {
+		log.Error("Unable to write run report: ", err)
+	}
+}
+
+// waitForNextRun blocks until the serve interval has passed,
+// the BMC configuration has changed or an interrupt was received.
+func waitForNextRun(current *resource.Templates, stopChan chan struct{}) {
+
+	timer := time.NewTimer(runConfig.Serve.Interval)
+	defer timer.Stop()
+
+	ticker := time.NewTicker(configCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			return
+		case <-ticker.C:
+			assetConfig, err := readAssetConfig()
+			if err == nil && !current.Equal(assetConfig) {
+				return
+			}
+		case <-stopChan:
+			return
+		}
+	}
+}
+
+// reloadAssetConfig reads in the BMC configuration, returns the configuration to be applied and the configuration read,
+// if the read fails or the configuration read is invalid the current configuration is to be applied.
+func reloadAssetConfig(current *resource.Templates) (*resource.Templates, *resource.Templates) {
+
+	component := "serve"
+	assetConfigDir := viper.GetString("bmcCfgDir")
+
+	assetConfig, err := readAssetConfig()
+	if err != nil {
+		if current == nil {
+			log.Fatal("Unable to read BMC configuration: ", assetConfigDir, " Error: ", err)
+		}
+
+		log.WithFields(logrus.Fields{
+			"component": component,
+			"Dir":       assetConfigDir,
+			"Error":     err,
+		}).Warn("Unable to re-read BMC configuration, continuing with the previous configuration.")
+		return current, current
+	}
+
+	if current != nil && current.Equal(assetConfig) {
+		return current, current
+	}
+
+	if !runConfig.SkipValidate && !validAssetConfig(assetConfig) {
+		if current == nil {
+			log.Fatal("BMC configuration invalid, not applied (see bmcbutler validate --help).")
+		}
+
+		log.WithFields(logrus.Fields{
+			"component": component,
+			"Dir":       assetConfigDir,
+		}).Warn("BMC configuration invalid, continuing with the previous configuration.")
+		return current, assetConfig
+	}
+
+	if current != nil {
+		log.WithFields(logrus.Fields{
+			"component": component,
+			"Dir":       assetConfigDir,
+		}).Info("BMC configuration changed, reloaded.")
+	}
+
+	return assetConfig, assetConfig
+}
+
+// reconcile retrieves assets from the inventory and passes them to the butlers
+// to be configured, returns the number of assets passed to the butlers,
+// and true if an interrupt was received.
+func reconcile(assetConfig *resource.Templates, butlerChan chan<- butler.Msg, stopChan chan struct{}) (count int, stopped bool) {
+
+	inventoryChan := retrieveInventory(runConfig, stopChan)
+
+	for {
+		var assetList []asset.Asset
+		var ok bool
+
+		select {
+		case assetList, ok = <-inventoryChan:
+			if !ok {
+				return count, false
+			}
+		case <-stopChan:
+			return count, true
+		}
+
+		for _, asset := range assetList {
+			asset.Configure = true
+			butlerMsg := butler.Msg{Asset: asset, AssetConfig: assetConfig}
+
+			select {
+			case butlerChan <- butlerMsg:
+				count++
+			case <-stopChan:
+				return count, true
+			}
+		}
+	}
+}
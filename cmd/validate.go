@@ -0,0 +1,115 @@
+// Copyright © 2018 Joel Rebello <joel.rebello@booking.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"os"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/asset"
+	"github.com/bmc-toolbox/bmcbutler/pkg/resource"
+)
+
+// validateCmd represents the validate command
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate the BMC configuration for a set of sample assets.",
+	Long: `Render the BMC configuration for each sample asset and validate it,
+unknown keys and invalid values (ports, roles, power regulator, bios settings, cert attributes) are reported,
+no BMCs are connected to and secrets are not looked up.
+
+The sample assets are declared in the validate section of bmcbutler.yml,
+else an asset of each vendor, model supported is validated for, in each of the locations.
+
+The configuration is validated before configure runs, unless --skip-validate is passed.
+
+bmcbutler exits non zero if the configuration is invalid for any of the sample assets.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		validate()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+	rootCmd.PersistentFlags().BoolVarP(&runConfig.SkipValidate, "skip-validate", "", false, "Skip validating the BMC configuration before it is applied.")
+}
+
+func validate() {
+
+	// load config
+	overrideConfigFromFlags()
+	runConfig.Load(runConfig.CfgFile)
+	overrideLoadedConfigFromFlags()
+
+	assetConfig, err := readAssetConfig()
+	if err != nil {
+		log.Error("Unable to read BMC configuration: ", viper.GetString("bmcCfgDir"), " Error: ", err)
+		os.Exit(1)
+	}
+
+	if !validAssetConfig(assetConfig) {
+		os.Exit(1)
+	}
+
+	log.WithFields(logrus.Fields{
+		"component": "validate",
+		"Templates": assetConfig.Names(),
+	}).Info("BMC configuration valid.")
+}
+
+// sampleAssets returns the sample assets declared in the config,
+// the configuration is validated for each of these assets in each of the locations.
+func sampleAssets() []asset.Asset {
+
+	var samples []asset.Asset
+	if runConfig.Validate != nil {
+		for _, s := range runConfig.Validate.Assets {
+			samples = append(samples, asset.Asset{
+				Vendor:   s.Vendor,
+				Model:    s.Model,
+				Type:     s.Type,
+				Location: s.Location,
+				Serial:   s.Serial,
+				Extra:    s.Extra,
+			})
+		}
+	}
+
+	return resource.SampleAssets(samples, runConfig.Locations)
+}
+
+// validAssetConfig validates the BMC configuration for the sample assets,
+// logs the errors for each sample asset the configuration is invalid for.
+func validAssetConfig(assetConfig *resource.Templates) bool {
+
+	invalid := resource.ValidateTemplates(assetConfig, sampleAssets(), log)
+	for _, v := range invalid {
+		for _, e := range v.Errors {
+			log.WithFields(logrus.Fields{
+				"component": "validate",
+				"Vendor":    v.Asset.Vendor,
+				"Model":     v.Asset.Model,
+				"AssetType": v.Asset.Type,
+				"Location":  v.Asset.Location,
+				"Error":     e,
+			}).Error("BMC configuration invalid.")
+		}
+	}
+
+	return len(invalid) == 0
+}
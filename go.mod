@@ -9,6 +9,7 @@ require (
 	github.com/frankban/quicktest v1.11.3 // indirect
 	github.com/gammazero/deque v0.0.0-20201010052221-3932da5530cc // indirect
 	github.com/gammazero/workerpool v1.1.1
+	github.com/gin-gonic/gin v1.3.0
 	github.com/gobuffalo/flect v0.2.2 // indirect
 	github.com/gobuffalo/helpers v0.6.1 // indirect
 	github.com/gobuffalo/plush v3.8.3+incompatible
@@ -22,13 +23,17 @@ require (
 	github.com/hashicorp/go-rootcerts v1.0.2 // indirect
 	github.com/hashicorp/vault/api v1.0.4
 	github.com/microcosm-cc/bluemonday v1.0.4 // indirect
+	github.com/mitchellh/mapstructure v1.4.1
 	github.com/pierrec/lz4 v2.6.0+incompatible // indirect
+	github.com/prometheus/client_golang v1.9.0
 	github.com/rcrowley/go-metrics v0.0.0-20201227073835-cf1acfcdf475 // indirect
 	github.com/sirupsen/logrus v1.7.1
 	github.com/spf13/cobra v1.1.3
 	github.com/spf13/viper v1.7.1
-	golang.org/x/time v0.0.0-20210220033141-f8bda1e9f3ba // indirect
+	github.com/ugorji/go v1.1.7 // indirect
+	go.etcd.io/bbolt v1.3.5
+	golang.org/x/time v0.0.0-20210220033141-f8bda1e9f3ba
+	gopkg.in/go-playground/validator.v9 v9.31.0
 	gopkg.in/square/go-jose.v2 v2.5.1 // indirect
 	gopkg.in/yaml.v2 v2.4.0
-
 )
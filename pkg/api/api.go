@@ -0,0 +1,348 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/asset"
+	"github.com/bmc-toolbox/bmcbutler/pkg/butler"
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+	"github.com/bmc-toolbox/bmcbutler/pkg/metrics"
+	"github.com/bmc-toolbox/bmcbutler/pkg/report"
+	"github.com/bmc-toolbox/bmcbutler/pkg/resource"
+)
+
+// Server serves the HTTP API to submit jobs and query their status,
+// assets in submitted jobs are passed to the butlers over the butler channel.
+type Server struct {
+	Listen      string
+	Token       string         //when set, API requests are expected to present the token as a bearer token.
+	Config      *config.Params //bmcbutler config, cli params
+	Log         *logrus.Logger
+	ButlerChan  chan<- butler.Msg
+	StopChan    <-chan struct{}
+	AssetConfig func() *resource.Templates                       //returns the BMC configuration templates read in from the bmcCfgDir
+	Inventory   func(config *config.Params) <-chan []asset.Asset //returns assets matching the config filter params
+	Report      report.Recorder                                  //When set, records are passed on once added to their job.
+
+	mutex   sync.Mutex
+	jobs    map[string]*Job
+	jobIDs  []string //job ids in the order they were submitted
+	syncWG  sync.WaitGroup
+	handler http.Handler
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Run serves the API until the stop channel is closed.
+func (s *Server) Run() {
+
+	component := "api"
+
+	server := &http.Server{Addr: s.Listen, Handler: s.router()}
+
+	s.syncWG.Add(1)
+	go func() {
+		defer s.syncWG.Done()
+
+		<-s.StopChan
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		err := server.Shutdown(ctx)
+		if err != nil {
+			s.Log.WithFields(logrus.Fields{
+				"component": component,
+				"Error":     err,
+			}).Warn("API server shutdown returned error.")
+		}
+	}()
+
+	s.Log.WithFields(logrus.Fields{
+		"component": component,
+		"Listen":    s.Listen,
+	}).Info("API server listening.")
+
+	err := server.ListenAndServe()
+	if err != nil && err != http.ErrServerClosed {
+		s.Log.WithFields(logrus.Fields{
+			"component": component,
+			"Listen":    s.Listen,
+			"Error":     err,
+		}).Fatal("API server failed.")
+	}
+}
+
+// Wait waits for the API server and any jobs being dispatched to the butlers to return,
+// this is to be invoked after the stop channel is closed and before the butler channel is closed.
+func (s *Server) Wait() {
+	s.syncWG.Wait()
+}
+
+// router sets up the API routes.
+func (s *Server) router() http.Handler {
+
+	if s.handler != nil {
+		return s.handler
+	}
+
+	gin.SetMode(gin.ReleaseMode)
+
+	r := gin.New()
+	r.Use(gin.Recovery())
+
+	v1 := r.Group("/api/v1")
+	v1.Use(s.authorize)
+	v1.GET("/jobs", s.listJobs)
+	v1.POST("/jobs", s.submitJob)
+	v1.GET("/jobs/:id", s.getJob)
+
+	s.handler = r
+
+	return r
+}
+
+// authorize rejects requests that do not present the API token, if one was declared.
+func (s *Server) authorize(c *gin.Context) {
+
+	if s.Token == "" {
+		return
+	}
+
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.Token)) != 1 {
+		metrics.IncrCounter([]string{"api", "requests_unauthorized"}, 1)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse{Error: "unauthorized"})
+	}
+}
+
+// ListenAddress returns the address to serve the API on, the API is served on localhost unless a host is declared,
+// a token is expected to be declared to serve the API on other than a loopback address.
+func ListenAddress(listen string, token string) (string, error) {
+
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return "", fmt.Errorf("invalid API listen address %s: %s", listen, err)
+	}
+
+	if host == "" {
+		host = "127.0.0.1"
+	}
+
+	ip := net.ParseIP(host)
+	loopback := host == "localhost" || (ip != nil && ip.IsLoopback())
+	if !loopback && token == "" {
+		return "", fmt.Errorf("API listen address %s is not a loopback address, expected serve.token to be declared", listen)
+	}
+
+	return net.JoinHostPort(host, port), nil
+}
+
+// listJobs returns a summary of the jobs, most recent first.
+func (s *Server) listJobs(c *gin.Context) {
+
+	s.mutex.Lock()
+	jobs := make([]Job, 0, len(s.jobIDs))
+	for i := len(s.jobIDs) - 1; i >= 0; i-- {
+		jobs = append(jobs, s.jobs[s.jobIDs[i]].summary())
+	}
+	s.mutex.Unlock()
+
+	c.JSON(http.StatusOK, jobs)
+}
+
+// getJob returns the job along with the per asset results.
+func (s *Server) getJob(c *gin.Context) {
+
+	s.mutex.Lock()
+	job, exists := s.jobs[c.Param("id")]
+	var j Job
+	if exists {
+		j = job.detail()
+	}
+	s.mutex.Unlock()
+
+	if !exists {
+		c.JSON(http.StatusNotFound, errorResponse{Error: "job not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, j)
+}
+
+// submitJob validates the job request, queues the job and returns it.
+func (s *Server) submitJob(c *gin.Context) {
+
+	var req JobRequest
+
+	err := c.BindJSON(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	err = req.validate(s.Config)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	select {
+	case <-s.StopChan:
+		c.JSON(http.StatusServiceUnavailable, errorResponse{Error: "shutting down"})
+		return
+	default:
+	}
+
+	job := &Job{
+		ID:      newJobID(),
+		Request: req,
+		Status:  JobQueued,
+		Created: time.Now(),
+	}
+
+	s.addJob(job)
+	summary := job.summary()
+
+	s.Log.WithFields(logrus.Fields{
+		"component": "api",
+		"JobID":     job.ID,
+		"Action":    req.Action,
+		"Serials":   req.Serials,
+		"Ips":       req.Ips,
+		"Locations": req.Locations,
+	}).Info("Job submitted.")
+
+	metrics.IncrCounter([]string{"api", "jobs_submitted"}, 1)
+
+	s.syncWG.Add(1)
+	go s.dispatch(job.ID, req)
+
+	c.JSON(http.StatusAccepted, summary)
+}
+
+// addJob adds the job to the job list, dropping the oldest completed job if required.
+func (s *Server) addJob(job *Job) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.jobs == nil {
+		s.jobs = make(map[string]*Job)
+	}
+
+	if len(s.jobIDs) >= maxJobs {
+		for i, id := range s.jobIDs {
+			if s.jobs[id].Status == JobDone {
+				delete(s.jobs, id)
+				s.jobIDs = append(s.jobIDs[:i], s.jobIDs[i+1:]...)
+				break
+			}
+		}
+	}
+
+	s.jobs[job.ID] = job
+	s.jobIDs = append(s.jobIDs, job.ID)
+}
+
+// dispatch retrieves the assets for the job from the inventory,
+// and passes them to the butlers.
+func (s *Server) dispatch(id string, req JobRequest) {
+
+	defer s.syncWG.Done()
+
+	var assetConfig *resource.Templates
+	if req.Action != "execute" {
+		assetConfig = s.AssetConfig()
+	}
+
+	s.updateJob(id, func(j *Job) { j.Status = JobRunning })
+
+	inventoryChan := s.Inventory(req.jobConfig(s.Config))
+
+	var count int
+	for assetList := range inventoryChan {
+		for _, a := range assetList {
+
+			switch req.Action {
+			case "configure":
+				a.Configure = true
+			case "audit":
+				a.Audit = true
+			case "execute":
+				a.Execute = true
+			}
+
+			msg := butler.Msg{
+				Asset:        a,
+				AssetConfig:  assetConfig,
+				AssetExecute: req.Command,
+				ExecuteArgs:  req.Args,
+				Resources:    req.Resources,
+				JobID:        id,
+			}
+
+			select {
+			case s.ButlerChan <- msg:
+				count++
+				s.updateJob(id, func(j *Job) { j.Assets = count })
+			case <-s.StopChan:
+				// the job is done once the assets passed to the butlers are reported.
+				s.updateJob(id, func(j *Job) {
+					j.Error = "interrupted"
+					j.dispatched = true
+					j.updateStatus()
+				})
+				return
+			}
+		}
+	}
+
+	s.updateJob(id, func(j *Job) {
+		j.Assets = count
+		j.dispatched = true
+		if count == 0 {
+			j.Error = "no assets found"
+		}
+
+		j.updateStatus()
+	})
+}
+
+// updateJob invokes the update func on the job while holding the lock.
+func (s *Server) updateJob(id string, update func(j *Job)) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	job, exists := s.jobs[id]
+	if !exists {
+		return
+	}
+
+	update(job)
+}
+
+// Add adds a record received from a butler to its job,
+// records are then passed on to the run report.
+func (s *Server) Add(r report.Record) {
+
+	if r.JobID != "" {
+		s.updateJob(r.JobID, func(j *Job) { j.add(r) })
+	}
+
+	if s.Report != nil {
+		s.Report.Add(r)
+	}
+}
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/asset"
+	"github.com/bmc-toolbox/bmcbutler/pkg/butler"
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+	"github.com/bmc-toolbox/bmcbutler/pkg/report"
+	"github.com/bmc-toolbox/bmcbutler/pkg/resource"
+)
+
+func testServer(butlerChan chan butler.Msg) *Server {
+
+	return &Server{
+		Config:      &config.Params{FilterParams: &config.FilterParams{}, Locations: []string{"ams2"}},
+		Log:         logrus.New(),
+		ButlerChan:  butlerChan,
+		StopChan:    make(chan struct{}),
+		AssetConfig: func() *resource.Templates { return resource.NewTemplates([]byte("syslog:\n  server: 10.0.0.1\n")) },
+		Inventory: func(cfg *config.Params) <-chan []asset.Asset {
+			ch := make(chan []asset.Asset, 1)
+			ch <- []asset.Asset{{Serial: cfg.FilterParams.Serials, IPAddresses: []string{"10.0.0.2"}}}
+			close(ch)
+			return ch
+		},
+	}
+}
+
+func request(t *testing.T, s *Server, method, path string, body interface{}) (int, []byte) {
+
+	var b bytes.Buffer
+	if body != nil {
+		err := json.NewEncoder(&b).Encode(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req := httptest.NewRequest(method, path, &b)
+	w := httptest.NewRecorder()
+	s.router().ServeHTTP(w, req)
+
+	return w.Code, w.Body.Bytes()
+}
+
+// TestSubmitJob tests a job is passed to the butlers,
+// and the job is marked done once the butler result is added.
+func TestSubmitJob(t *testing.T) {
+
+	butlerChan := make(chan butler.Msg, 1)
+	s := testServer(butlerChan)
+
+	code, body := request(t, s, "POST", "/api/v1/jobs", JobRequest{Action: "configure", Serials: []string{"FOOBAR"}, Resources: []string{"syslog"}})
+	if code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, code, body)
+	}
+
+	var job Job
+	err := json.Unmarshal(body, &job)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := <-butlerChan
+	if msg.JobID != job.ID || msg.Asset.Serial != "FOOBAR" || !msg.Asset.Configure || msg.Resources[0] != "syslog" {
+		t.Fatalf("Unexpected butler msg: %+v", msg)
+	}
+
+	s.Wait()
+	s.Add(report.Record{JobID: job.ID, Serial: "FOOBAR", Applied: []string{"syslog"}, Success: true})
+
+	code, body = request(t, s, "GET", "/api/v1/jobs/"+job.ID, nil)
+	if code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, code, body)
+	}
+
+	err = json.Unmarshal(body, &job)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if job.Status != JobDone || job.Succeeded != 1 || len(job.Results) != 1 {
+		t.Fatalf("Expected job to be done with one result, got %+v", job)
+	}
+
+	code, _ = request(t, s, "GET", "/api/v1/jobs/unknown", nil)
+	if code != http.StatusNotFound {
+		t.Fatalf("Expected status %d for unknown job, got %d", http.StatusNotFound, code)
+	}
+}
+
+// TestAuthorize tests requests are rejected unless they present the API token, when one is declared.
+func TestAuthorize(t *testing.T) {
+
+	s := testServer(make(chan butler.Msg, 1))
+	s.Token = "secret"
+
+	code, _ := request(t, s, "GET", "/api/v1/jobs", nil)
+	if code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d without a token, got %d", http.StatusUnauthorized, code)
+	}
+
+	for token, expected := range map[string]int{"Bearer secret": http.StatusOK, "Bearer foo": http.StatusUnauthorized} {
+		req := httptest.NewRequest("GET", "/api/v1/jobs", nil)
+		req.Header.Set("Authorization", token)
+		w := httptest.NewRecorder()
+		s.router().ServeHTTP(w, req)
+
+		if w.Code != expected {
+			t.Errorf("Expected status %d with the authorization %s, got %d", expected, token, w.Code)
+		}
+	}
+}
+
+// TestListenAddress tests the API is served on localhost unless a host is declared,
+// and a token is expected to serve it on other than a loopback address.
+func TestListenAddress(t *testing.T) {
+
+	tc := []struct {
+		listen   string
+		token    string
+		expected string
+		err      bool
+	}{
+		{":8080", "", "127.0.0.1:8080", false},
+		{"localhost:8080", "", "localhost:8080", false},
+		{"[::1]:8080", "", "[::1]:8080", false},
+		{"0.0.0.0:8080", "", "", true},
+		{"10.0.0.1:8080", "secret", "10.0.0.1:8080", false},
+		{"8080", "", "", true},
+	}
+
+	for _, c := range tc {
+		listen, err := ListenAddress(c.listen, c.token)
+		if (err != nil) != c.err || listen != c.expected {
+			t.Errorf("Expected %s to return '%s' (error: %t), got '%s', error: %v", c.listen, c.expected, c.err, listen, err)
+		}
+	}
+}
+
+// TestSubmitJobInvalid tests invalid job requests are rejected.
+func TestSubmitJobInvalid(t *testing.T) {
+
+	s := testServer(make(chan butler.Msg, 1))
+
+	requests := []JobRequest{
+		{Action: "configure"},
+		{Action: "reboot", Serials: []string{"FOOBAR"}},
+		{Action: "configure", Serials: []string{"FOOBAR"}, Ips: []string{"10.0.0.2"}},
+		{Action: "execute", Serials: []string{"FOOBAR"}, Command: "blade-reseat"},
+		{Action: "audit", Locations: []string{"ams2", "lhr4"}},
+	}
+
+	for _, r := range requests {
+		code, body := request(t, s, "POST", "/api/v1/jobs", r)
+		if code != http.StatusBadRequest {
+			t.Fatalf("Expected status %d for request %+v, got %d: %s", http.StatusBadRequest, r, code, body)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/butler/command"
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+	"github.com/bmc-toolbox/bmcbutler/pkg/report"
+)
+
+// Job states.
+const (
+	JobQueued  = "queued"  //assets are being retrieved from the inventory.
+	JobRunning = "running" //assets have been passed to the butlers.
+	JobDone    = "done"    //a result has been received for each asset passed to the butlers.
+)
+
+// maxJobs is the number of jobs held in memory, the oldest completed jobs are dropped first.
+const maxJobs = 1000
+
+// JobRequest declares the assets to action and the action to be carried out.
+type JobRequest struct {
+	Action    string            `json:"action"` //configure, audit, execute
+	Serials   []string          `json:"serials"`
+	Ips       []string          `json:"ips"`
+	Locations []string          `json:"locations"`
+	Resources []string          `json:"resources"` //resources to apply, defaults to all declared resources
+	Command   string            `json:"command"`   //the command to execute, see bmcbutler execute --help
+	Args      map[string]string `json:"args"`      //arguments to the command
+}
+
+// Job is a request being carried out by the butlers.
+type Job struct {
+	ID        string          `json:"id"`
+	Request   JobRequest      `json:"request"`
+	Status    string          `json:"status"`
+	Created   time.Time       `json:"created"`
+	Finished  *time.Time      `json:"finished,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	Assets    int             `json:"assets"` //number of assets passed to the butlers
+	Succeeded int             `json:"succeeded"`
+	Failed    int             `json:"failed"`
+	Results   []report.Record `json:"results,omitempty"`
+
+	dispatched bool //set once all assets from the inventory were passed to the butlers.
+}
+
+// validate checks the job request is sane,
+// the locations requested are expected to be managed by bmcbutler, unless locations are ignored.
+func (r *JobRequest) validate(c *config.Params) error {
+
+	if len(r.Serials) == 0 && len(r.Ips) == 0 && len(r.Locations) == 0 {
+		return errors.New("expected one of serials, ips, locations to be declared")
+	}
+
+	for _, location := range r.Locations {
+		if !c.IgnoreLocation && !managed(c.Locations, location) {
+			return fmt.Errorf("location %s not managed, expected one of: %s", location, strings.Join(c.Locations, ", "))
+		}
+	}
+
+	if len(r.Serials) > 0 && len(r.Ips) > 0 {
+		return errors.New("serials, ips are mutually exclusive")
+	}
+
+	switch r.Action {
+	case "configure", "audit":
+		if r.Command != "" {
+			return fmt.Errorf("command not expected with action %s", r.Action)
+		}
+	case "execute":
+		cmd, err := command.Get(r.Command)
+		if err != nil {
+			return err
+		}
+
+		return cmd.ValidateArgs(r.Args)
+	default:
+		return fmt.Errorf("unknown action '%s', expected one of configure, audit, execute", r.Action)
+	}
+
+	return nil
+}
+
+// managed returns true if the location is one of the locations managed.
+func managed(locations []string, location string) bool {
+	for _, l := range locations {
+		if l == location {
+			return true
+		}
+	}
+
+	return false
+}
+
+// jobConfig returns a deep copy of the bmcbutler config with the asset filter params set from the job request.
+func (r *JobRequest) jobConfig(c *config.Params) *config.Params {
+
+	jobConfig := c.Copy()
+	jobConfig.FilterParams = &config.FilterParams{
+		Serials: strings.Join(r.Serials, ","),
+		Ips:     strings.Join(r.Ips, ","),
+	}
+
+	if len(r.Serials) == 0 && len(r.Ips) == 0 {
+		jobConfig.FilterParams.All = true
+	}
+
+	if len(r.Locations) > 0 {
+		jobConfig.Locations = r.Locations
+	}
+
+	return jobConfig
+}
+
+// summary returns a copy of the job without the per asset results.
+func (j *Job) summary() Job {
+	s := *j
+	s.Results = nil
+	return s
+}
+
+// detail returns a copy of the job with the per asset results.
+func (j *Job) detail() Job {
+	d := *j
+	d.Results = make([]report.Record, len(j.Results))
+	copy(d.Results, j.Results)
+	return d
+}
+
+// add adds the result of an asset to the job.
+func (j *Job) add(r report.Record) {
+	j.Results = append(j.Results, r)
+	if r.Success {
+		j.Succeeded++
+	} else {
+		j.Failed++
+	}
+
+	j.updateStatus()
+}
+
+// updateStatus marks the job done once a result was received for each asset passed to the butlers.
+func (j *Job) updateStatus() {
+	if !j.dispatched || len(j.Results) < j.Assets {
+		return
+	}
+
+	now := time.Now()
+	j.Status = JobDone
+	j.Finished = &now
+}
+
+func newJobID() string {
+	b := make([]byte, 8)
+	_, err := rand.Read(b)
+	if err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+
+	return hex.EncodeToString(b)
+}
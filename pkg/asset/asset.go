@@ -27,8 +27,12 @@ type Asset struct {
 	Model     string
 	Type      string //server or chassis
 	Location  string
+	Chassis   string            //The parent chassis of a blade as declared in the inventory, blades in a chassis share its limits.
 	Setup     bool              //If setup is set, butlers will setup the asset.
 	Configure bool              //If setup is set, butlers will configure the asset.
 	Execute   bool              //If execute is set, butlers will execute given command(s) on the asset.
+	Audit     bool              //If audit is set, butlers will compare the asset configuration with the declared config.
+	Rotate    bool              //If rotate is set, butlers will rotate the BMC user password.
 	Extra     map[string]string //any extra params needed to be set in a asset.
+	Error     string            //set if the asset could not be retrieved from the inventory, the asset is not actioned.
 }
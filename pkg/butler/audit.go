@@ -0,0 +1,110 @@
+package butler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/asset"
+	"github.com/bmc-toolbox/bmcbutler/pkg/butler/configure"
+	"github.com/bmc-toolbox/bmcbutler/pkg/metrics"
+	"github.com/bmc-toolbox/bmcbutler/pkg/resource"
+	"github.com/bmc-toolbox/bmclib/devices"
+	"github.com/bmc-toolbox/bmclogin"
+)
+
+// auditAsset sets up the bmc connection,
+// gets any Asset config templated data rendered,
+// compares the current asset configuration with the rendered configuration.
+// The asset configuration is never modified.
+func (b *Butler) auditAsset(ctx context.Context, config *resource.Templates, resources []string, asset *asset.Asset) (result configure.AuditResult, err error) {
+
+	log := b.Log
+	component := "auditAsset"
+
+	// the asset vendor, model are known once the asset is logged into.
+	defer func(start time.Time) {
+		metrics.MeasureRuntimeWithLabels([]string{"butler", "audit_runtime"}, start, labels(asset))
+	}(time.Now())
+
+	log.WithFields(logrus.Fields{
+		"component": component,
+		"Serial":    asset.Serial,
+		"IPAddress": asset.IPAddresses,
+	}).Debug("Connecting to asset.")
+
+	bmcConn := bmclogin.Params{
+		IpAddresses:     asset.IPAddresses,
+		Credentials:     b.Creds.Credentials(asset),
+		CheckCredential: true,
+		Retries:         1,
+	}
+
+	//connect to the bmc/chassis bmc
+	client, loginInfo, err := b.login(ctx, &bmcConn)
+	if err != nil {
+		return result, err
+	}
+
+	asset.IPAddress = loginInfo.ActiveIpAddress
+	b.Creds.Worked(asset, loginInfo.WorkingCredentials)
+
+	switch client.(type) {
+	case devices.Bmc:
+		bmc := client.(devices.Bmc)
+		defer bmc.Close(context.TODO())
+
+		asset.Type = "server"
+		asset.Model = bmc.HardwareType()
+		asset.Vendor = bmc.Vendor()
+		asset.Serial, _ = bmc.Serial()
+
+		resourceInstance := resource.Resource{Log: log, Asset: asset, Secrets: b.Secrets}
+		renderedConfig, _, err := resourceInstance.LoadTemplates(config)
+		if err != nil {
+			metrics.IncrCounterWithLabels([]string{"butler", "render_fail"}, 1, labels(asset))
+			return result, fmt.Errorf("unable to render configuration: %s", err)
+		}
+
+		if renderedConfig == nil {
+			return result, errors.New("No BMC configuration to be audited")
+		}
+
+		// the current configuration is read from the BMC Redfish API.
+		redfish := configure.NewRedfish(asset.IPAddress, loginInfo.WorkingCredentials, renderedConfig.Redfish, log)
+
+		c := configure.NewBmcConfigurator(bmc, asset, resources, &renderedConfig.ResourcesConfig, redfish, b.Config, nil, nil, log)
+		return c.Audit(ctx), nil
+	case devices.Cmc:
+		chassis := client.(devices.Cmc)
+		defer chassis.Close()
+
+		asset.Type = "chassis"
+		asset.Model = chassis.HardwareType()
+		asset.Vendor = chassis.Vendor()
+		asset.Serial, _ = chassis.Serial()
+
+		resourceInstance := resource.Resource{Log: log, Asset: asset, Secrets: b.Secrets}
+		renderedConfig, _, err := resourceInstance.LoadTemplates(config)
+		if err != nil {
+			metrics.IncrCounterWithLabels([]string{"butler", "render_fail"}, 1, labels(asset))
+			return result, fmt.Errorf("unable to render configuration: %s", err)
+		}
+
+		if renderedConfig == nil {
+			return result, errors.New("No BMC configuration to be audited")
+		}
+
+		c := configure.NewCmcConfigurator(chassis, asset, resources, &renderedConfig.ResourcesConfig, b.Config, nil, log)
+		return c.Audit(ctx), nil
+	default:
+		log.WithFields(logrus.Fields{
+			"component": component,
+			"Asset":     fmt.Sprintf("%+v", asset),
+		}).Warn("Unknown device type.")
+		return result, errors.New("Unknown asset type")
+	}
+}
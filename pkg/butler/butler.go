@@ -15,78 +15,199 @@
 package butler
 
 import (
+	"context"
+	"fmt"
 	"sync"
-	"time"
+	"sync/atomic"
 
+	"github.com/bmc-toolbox/bmclogin"
 	"github.com/gammazero/workerpool"
 	"github.com/sirupsen/logrus"
 
 	"github.com/bmc-toolbox/bmcbutler/pkg/asset"
+	"github.com/bmc-toolbox/bmcbutler/pkg/butler/command"
+	"github.com/bmc-toolbox/bmcbutler/pkg/checkpoint"
 	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+	"github.com/bmc-toolbox/bmcbutler/pkg/credentials"
+	"github.com/bmc-toolbox/bmcbutler/pkg/report"
+	"github.com/bmc-toolbox/bmcbutler/pkg/resource"
+	"github.com/bmc-toolbox/bmcbutler/pkg/retry"
 	"github.com/bmc-toolbox/bmcbutler/pkg/secrets"
+	"github.com/bmc-toolbox/bmcbutler/pkg/signer"
+	"github.com/bmc-toolbox/bmcbutler/pkg/state"
 )
 
 // Msg (butler messages) are passed over the butlerChan
 // they declare assets for butlers to carry actions on.
 type Msg struct {
-	Asset        asset.Asset //Asset to be configured
-	AssetConfig  []byte      //The BMC configuration read in from configuration.yml
-	AssetSetup   []byte      //The One time setup configuration read from setup.yml
-	AssetExecute string      //Commands to be executed on the BMC
+	Asset        asset.Asset         //Asset to be configured
+	AssetConfig  *resource.Templates //The BMC configuration templates read in from the bmcCfgDir
+	AssetSetup   []byte              //The One time setup configuration read from setup.yml
+	AssetExecute string              //Commands to be executed on the BMC
+	ExecuteArgs  command.Args        //Arguments to the command to be executed
+	Resources    []string            //Resources to be applied, overrides the resources declared in the config
+	JobID        string              //The ID of the API job the asset is part of
+	Attempt      int                 //The times the asset was actioned before, set when the asset is re-queued
 }
 
+// Counts holds the number of assets handled by butlers,
+// and the outcome of configure actions as tracked by the configure_success, configure_fail metrics,
+// re-queued assets are counted once actioned for the last time.
+type Counts struct {
+	Handled          int64
+	ConfigureSuccess int64
+	ConfigureFail    int64
+}
+
+// queueFactor is the number of assets queued per butler.
+const queueFactor = 10
+
 // Butler struct holds attributes required to spawn butlers.
 type Butler struct {
+	counts     Counts         //first field for 64-bit alignment of the atomic counters.
 	Config     *config.Params //bmcbutler config, cli params
 	ButlerChan <-chan Msg
 	Log        *logrus.Logger
-	StopChan   <-chan struct{}
 	SyncWG     *sync.WaitGroup
 	WorkerPool *workerpool.WorkerPool
-	interrupt  bool
-	Secrets    *secrets.Store
+	Secrets    secrets.Provider
+	Report     report.Recorder       //When set, a record of each asset actioned is added.
+	State      *state.Store          //When set, resources unchanged since they were last applied are skipped.
+	Signer     signer.Signer         //CSRs generated on BMCs are signed with this signer.
+	Vault      *secrets.Client       //Rotated passwords are written to vault.
+	Creds      *credentials.Resolver //Resolves the credentials to login to each asset with.
+	Checkpoint *checkpoint.Recorder  //When set, assets actioned are recorded in the run checkpoint.
+	limiter    *limiter
 }
 
-// Runner spawns a pool of butlers, waits until they are done.
-func (b *Butler) Runner() {
+// Runner spawns a pool of butlers, waits until they are done,
+// once the context is done, assets being actioned are abandoned and queued assets are reported as not actioned.
+//
+// Assets received are queued until a butler is available and the asset is within the limits
+// declared for its location, vendor and parent chassis, assets within the limits are actioned in the order received.
+// Once the queue is full, no more assets are received until an asset is actioned.
+// Assets whose action failed are re-queued once no assets are queued or being actioned, i.e at the end of the run.
+// nolint: gocyclo
+func (b *Butler) Runner(ctx context.Context) {
 
 	log := b.Log
 	component := "Runner"
 
 	defer b.SyncWG.Done()
 
+	b.limiter = newLimiter(b.Config.Limits)
 	b.WorkerPool = workerpool.New(b.Config.ButlersToSpawn)
+
+	// assets beyond the limits are queued while other assets are received,
+	// the queue is bounded so the inventory is not read in ahead of the butlers.
+	maxQueued := b.Config.ButlersToSpawn * queueFactor
+
+	type done struct {
+		keys    map[string]int
+		msg     Msg
+		requeue bool
+	}
+
+	doneChan := make(chan done)
+	butlerChan := b.ButlerChan
+	var queue, requeued []Msg
+	running := 0
+
 loop:
 	for {
+		// dispatch queued assets that are within the limits.
+		for idx := 0; idx < len(queue) && running < b.Config.ButlersToSpawn; {
+			keys, ok := b.limiter.acquire(&queue[idx].Asset)
+			if !ok {
+				idx++
+				continue
+			}
+
+			msg := queue[idx]
+			queue = append(queue[:idx], queue[idx+1:]...)
+			running++
+
+			b.WorkerPool.Submit(func() {
+				requeue := b.msgHandler(ctx, msg)
+
+				select {
+				case doneChan <- done{keys: keys, msg: msg, requeue: requeue}:
+				case <-ctx.Done():
+				}
+			})
+		}
+
+		// failed assets are actioned again once the butlers are idle.
+		if len(queue) == 0 && running == 0 && len(requeued) > 0 {
+			log.WithFields(logrus.Fields{
+				"component": component,
+				"Requeued":  len(requeued),
+			}).Info("Re-queued assets whose action failed.")
+
+			queue, requeued = requeued, nil
+			continue
+		}
+
+		if butlerChan == nil && len(queue) == 0 && running == 0 {
+			break loop
+		}
+
+		// stop receiving assets once the queue is full.
+		receive := butlerChan
+		if len(queue) >= maxQueued {
+			receive = nil
+
+			log.WithFields(logrus.Fields{
+				"component": component,
+				"Queued":    len(queue),
+				"Running":   running,
+				"butlers":   b.Config.ButlersToSpawn,
+			}).Trace("Queue full, waiting for assets to be actioned.")
+		}
+
 		select {
-		case msg, ok := <-b.ButlerChan:
+		case msg, ok := <-receive:
 			if !ok {
 				log.WithFields(logrus.Fields{
 					"component": component,
 				}).Trace("Butler channel closed.")
-				break loop
+				butlerChan = nil
+				continue
 			}
 
-			for b.WorkerPool.WaitingQueueSize() > b.Config.ButlersToSpawn {
-				log.WithFields(logrus.Fields{
-					"component":          component,
-					"Waiting queue size": b.WorkerPool.WaitingQueueSize(),
-					"butlers":            b.Config.ButlersToSpawn,
-				}).Trace("Waiting for workerpool queue size to drop below butler count")
-				time.Sleep(10 * time.Second)
-			}
+			queue = append(queue, msg)
+		case d := <-doneChan:
+			running--
+			b.limiter.release(d.keys)
 
-			b.WorkerPool.Submit(func() { b.msgHandler(msg) })
-		case <-b.StopChan:
-			b.interrupt = true
+			if d.requeue {
+				d.msg.Attempt++
+				requeued = append(requeued, d.msg)
+			}
+		case <-ctx.Done():
 			log.WithFields(logrus.Fields{
-				"component":          component,
-				"Waiting queue size": b.WorkerPool.WaitingQueueSize(),
+				"component": component,
+				"Queued":    len(queue),
 			}).Debug("Interrupt received.")
 
-			// wait for currently running routines, pending tasks are abandoned.
-			b.WorkerPool.Stop()
-			break loop
+			// wait for the assets passed to butlers, at most a task per butler is submitted to the pool,
+			// tasks not yet running return once they see the context done, queued assets are abandoned.
+			b.WorkerPool.StopWait()
+			b.abandon(append(queue, requeued...), ctx.Err())
+
+			// assets already sent over the butler channel are reported abandoned too.
+			for {
+				select {
+				case msg, ok := <-butlerChan:
+					if ok {
+						b.abandon([]Msg{msg}, ctx.Err())
+						continue
+					}
+				default:
+				}
+
+				break loop
+			}
 		}
 
 	}
@@ -99,3 +220,84 @@ loop:
 	}).Debug("All butlers exited.")
 
 }
+
+// login logs in to the asset, failed logins are retried as declared by the login retry policy.
+func (b *Butler) login(ctx context.Context, bmcConn *bmclogin.Params) (client interface{}, loginInfo bmclogin.LoginInfo, err error) {
+
+	var policy *config.RetryPolicy
+	if b.Config.Retry != nil {
+		policy = b.Config.Retry.Login
+	}
+
+	attempts, err := retry.Do(ctx, policy, func() (err error) {
+		client, loginInfo, err = b.loginAttempt(ctx, bmcConn)
+		return err
+	})
+
+	if attempts > 1 {
+		b.Log.WithFields(logrus.Fields{
+			"component": "login",
+			"IPAddress": bmcConn.IpAddresses,
+			"Attempts":  attempts,
+			"Error":     err,
+		}).Debug("Login retried.")
+	}
+
+	return client, loginInfo, err
+}
+
+// loginAttempt waits for the connections rate limit, and logs in to the asset,
+// the login is abandoned once the login timeout elapses or the context is done.
+func (b *Butler) loginAttempt(ctx context.Context, bmcConn *bmclogin.Params) (interface{}, bmclogin.LoginInfo, error) {
+
+	if b.Config.Timeouts != nil && b.Config.Timeouts.Login > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.Config.Timeouts.Login)
+		defer cancel()
+	}
+
+	if b.limiter != nil && !b.limiter.wait(ctx.Done()) {
+		return nil, bmclogin.LoginInfo{}, fmt.Errorf("login: %w", ctx.Err())
+	}
+
+	// the login is not passed a stop channel, since bmclogin marks itself interrupted for all later logins
+	// once a stop channel is closed, the login is abandoned instead.
+	type login struct {
+		client interface{}
+		info   bmclogin.LoginInfo
+		err    error
+	}
+
+	done := make(chan login, 1)
+	go func() {
+		client, info, err := bmcConn.Login()
+		done <- login{client: client, info: info, err: err}
+	}()
+
+	select {
+	case l := <-done:
+		if l.err != nil && ctx.Err() != nil {
+			return nil, l.info, fmt.Errorf("login: %w", ctx.Err())
+		}
+
+		return l.client, l.info, l.err
+	case <-ctx.Done():
+		// the connection made by an abandoned login is closed once the login returns.
+		go func() {
+			if l := <-done; l.err == nil {
+				closeConn(l.client)
+			}
+		}()
+
+		return nil, bmclogin.LoginInfo{}, fmt.Errorf("login: %w", ctx.Err())
+	}
+}
+
+// Counts returns the number of assets handled and the outcome of configure actions so far.
+func (b *Butler) Counts() Counts {
+	return Counts{
+		Handled:          atomic.LoadInt64(&b.counts.Handled),
+		ConfigureSuccess: atomic.LoadInt64(&b.counts.ConfigureSuccess),
+		ConfigureFail:    atomic.LoadInt64(&b.counts.ConfigureFail),
+	}
+}
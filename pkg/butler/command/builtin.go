@@ -0,0 +1,134 @@
+package command
+
+import (
+	"github.com/bmc-toolbox/bmclib/devices"
+)
+
+// bladePosition returns the blade position from the position argument,
+// or looks up the position of the blade given its serial.
+func bladePosition(chassis devices.Cmc, args Args) (int, error) {
+	if serial, exists := args["serial"]; exists && serial != "" {
+		return chassis.FindBladePosition(serial)
+	}
+
+	return args.Int("position")
+}
+
+// bladeCommand returns a Cmc func that runs the given blade action on the blade position.
+func bladeCommand(action func(devices.Cmc, int) (bool, error)) func(devices.Cmc, Args) (bool, error) {
+	return func(chassis devices.Cmc, args Args) (bool, error) {
+		position, err := bladePosition(chassis, args)
+		if err != nil {
+			return false, err
+		}
+
+		return action(chassis, position)
+	}
+}
+
+func init() {
+
+	Register(&Command{
+		Name:        "bmc-reset",
+		Description: "Reset the BMC.",
+		Bmc:         func(bmc devices.Bmc, _ Args) (bool, error) { return bmc.PowerCycleBmc() },
+	})
+
+	Register(&Command{
+		Name:        "powercycle",
+		Description: "Power cycle the server.",
+		Bmc:         func(bmc devices.Bmc, _ Args) (bool, error) { return bmc.PowerCycle() },
+	})
+
+	Register(&Command{
+		Name:        "poweron",
+		Description: "Power on the server.",
+		Bmc:         func(bmc devices.Bmc, _ Args) (bool, error) { return bmc.PowerOn() },
+	})
+
+	Register(&Command{
+		Name:        "poweroff",
+		Description: "Power off the server.",
+		Bmc:         func(bmc devices.Bmc, _ Args) (bool, error) { return bmc.PowerOff() },
+	})
+
+	Register(&Command{
+		Name:        "pxeonce",
+		Description: "Set the server to PXE boot on the next boot.",
+		Bmc:         func(bmc devices.Bmc, _ Args) (bool, error) { return bmc.PxeOnce() },
+	})
+
+	Register(&Command{
+		Name:        "firmware-update",
+		Description: "Update the BMC/chassis firmware, args: source=<url to the firmware directory>,file=<firmware file>.",
+		Args:        []string{"source", "file"},
+		Bmc: func(bmc devices.Bmc, args Args) (bool, error) {
+			return bmc.UpdateFirmware(args["source"], args["file"])
+		},
+		Cmc: func(chassis devices.Cmc, args Args) (bool, error) {
+			return chassis.UpdateFirmware(args["source"], args["file"])
+		},
+	})
+
+	// chassis wide power commands act on every blade in the chassis,
+	// they are named explicitly so a chassis isn't powered off by a server command.
+	Register(&Command{
+		Name:        "chassis-powercycle",
+		Description: "Power cycle the chassis, and all blades in it.",
+		Cmc:         func(chassis devices.Cmc, _ Args) (bool, error) { return chassis.PowerCycle() },
+	})
+
+	Register(&Command{
+		Name:        "chassis-poweron",
+		Description: "Power on the chassis.",
+		Cmc:         func(chassis devices.Cmc, _ Args) (bool, error) { return chassis.PowerOn() },
+	})
+
+	Register(&Command{
+		Name:        "chassis-poweroff",
+		Description: "Power off the chassis, and all blades in it.",
+		Cmc:         func(chassis devices.Cmc, _ Args) (bool, error) { return chassis.PowerOff() },
+	})
+
+	Register(&Command{
+		Name:        "blade-powercycle",
+		Description: "Power cycle a blade in the chassis, args: position=<blade position> or serial=<blade serial>.",
+		Args:        []string{"position"},
+		Cmc:         bladeCommand(devices.Cmc.PowerCycleBlade),
+	})
+
+	Register(&Command{
+		Name:        "blade-poweron",
+		Description: "Power on a blade in the chassis, args: position=<blade position> or serial=<blade serial>.",
+		Args:        []string{"position"},
+		Cmc:         bladeCommand(devices.Cmc.PowerOnBlade),
+	})
+
+	Register(&Command{
+		Name:        "blade-poweroff",
+		Description: "Power off a blade in the chassis, args: position=<blade position> or serial=<blade serial>.",
+		Args:        []string{"position"},
+		Cmc:         bladeCommand(devices.Cmc.PowerOffBlade),
+	})
+
+	Register(&Command{
+		Name:        "blade-reseat",
+		Description: "Reseat a blade in the chassis, args: position=<blade position> or serial=<blade serial>.",
+		Args:        []string{"position"},
+		Cmc:         bladeCommand(devices.Cmc.ReseatBlade),
+	})
+
+	Register(&Command{
+		Name:        "blade-pxeonce",
+		Description: "Set a blade in the chassis to PXE boot on the next boot, args: position=<blade position> or serial=<blade serial>.",
+		Args:        []string{"position"},
+		Cmc:         bladeCommand(devices.Cmc.PxeOnceBlade),
+	})
+
+	Register(&Command{
+		Name:        "blade-bmc-reset",
+		Description: "Reset the BMC of a blade in the chassis, args: position=<blade position> or serial=<blade serial>.",
+		Args:        []string{"position"},
+		Cmc:         bladeCommand(devices.Cmc.PowerCycleBmcBlade),
+	})
+}
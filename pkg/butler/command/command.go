@@ -0,0 +1,159 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bmc-toolbox/bmclib/devices"
+)
+
+// ErrUnsupported is returned when a command can't be executed on the given device type.
+var ErrUnsupported = errors.New("command not supported on device type")
+
+// Args holds the arguments a command is invoked with,
+// e.g --args position=3,source=ftp://fw.example.com/idrac
+type Args map[string]string
+
+// ParseArgs parses a comma separated list of key=value pairs.
+func ParseArgs(s string) (Args, error) {
+
+	args := make(Args)
+	if s == "" {
+		return args, nil
+	}
+
+	for _, kv := range strings.Split(s, ",") {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return args, fmt.Errorf("invalid command argument '%s', expected key=value", kv)
+		}
+
+		args[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+
+	return args, nil
+}
+
+// String returns the value of a required argument.
+func (a Args) String(key string) (string, error) {
+	v, exists := a[key]
+	if !exists || v == "" {
+		return "", fmt.Errorf("expected command argument '%s' missing", key)
+	}
+
+	return v, nil
+}
+
+// Int returns the value of a required argument as an int.
+func (a Args) Int(key string) (int, error) {
+	v, err := a.String(key)
+	if err != nil {
+		return 0, err
+	}
+
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("command argument '%s' expected to be a number: %s", key, err)
+	}
+
+	return i, nil
+}
+
+// Command is an action that can be executed on a BMC and/or chassis,
+// a nil Bmc or Cmc func indicates the command is not supported on that device type.
+type Command struct {
+	Name        string
+	Description string
+	Args        []string // required arguments
+	Bmc         func(bmc devices.Bmc, args Args) (bool, error)
+	Cmc         func(chassis devices.Cmc, args Args) (bool, error)
+}
+
+// ValidateArgs checks the required arguments for the command are present,
+// and that no argument is given with an empty value.
+func (c *Command) ValidateArgs(args Args) error {
+	for key, value := range args {
+		if value == "" {
+			return fmt.Errorf("command argument '%s' given with no value", key)
+		}
+	}
+
+	for _, a := range c.Args {
+		// blade commands accept either the blade position or serial
+		if a == "position" {
+			if _, exists := args["serial"]; exists {
+				continue
+			}
+		}
+
+		if _, err := args.String(a); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ExecuteBmc executes the command on a BMC.
+func (c *Command) ExecuteBmc(bmc devices.Bmc, args Args) (bool, error) {
+	if c.Bmc == nil {
+		return false, ErrUnsupported
+	}
+
+	return c.Bmc(bmc, args)
+}
+
+// ExecuteCmc executes the command on a chassis.
+func (c *Command) ExecuteCmc(chassis devices.Cmc, args Args) (bool, error) {
+	if c.Cmc == nil {
+		return false, ErrUnsupported
+	}
+
+	return c.Cmc(chassis, args)
+}
+
+var registry = make(map[string]*Command)
+
+// Register adds a command to the registry, registering a command name twice panics.
+func Register(c *Command) {
+	if _, exists := registry[c.Name]; exists {
+		panic(fmt.Sprintf("command %s registered twice", c.Name))
+	}
+
+	registry[c.Name] = c
+}
+
+// Get returns the command registered with the given name.
+func Get(name string) (*Command, error) {
+	c, exists := registry[name]
+	if !exists {
+		return nil, fmt.Errorf("Unknown command: %s, valid commands: %s", name, strings.Join(Names(), ", "))
+	}
+
+	return c, nil
+}
+
+// Names returns a sorted list of registered command names.
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// List returns the registered commands sorted by name.
+func List() []*Command {
+	commands := make([]*Command, 0, len(registry))
+	for _, name := range Names() {
+		commands = append(commands, registry[name])
+	}
+
+	return commands
+}
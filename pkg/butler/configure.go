@@ -10,16 +10,16 @@ import (
 
 	"github.com/bmc-toolbox/bmcbutler/pkg/asset"
 	"github.com/bmc-toolbox/bmcbutler/pkg/butler/configure"
+	"github.com/bmc-toolbox/bmcbutler/pkg/metrics"
 	"github.com/bmc-toolbox/bmcbutler/pkg/resource"
 	"github.com/bmc-toolbox/bmclib/devices"
 	"github.com/bmc-toolbox/bmclogin"
-	metrics "github.com/bmc-toolbox/gin-go-metrics"
 )
 
 // applyConfig setups up the bmc connection
 // gets any Asset config templated data rendered
 // applies the asset configuration using bmclib
-func (b *Butler) configureAsset(config []byte, asset *asset.Asset) (err error) {
+func (b *Butler) configureAsset(ctx context.Context, config *resource.Templates, resources []string, asset *asset.Asset) (result configure.Result, err error) {
 
 	log := b.Log
 	component := "configureAsset"
@@ -29,10 +29,13 @@ func (b *Butler) configureAsset(config []byte, asset *asset.Asset) (err error) {
 			"component": component,
 			"Asset":     fmt.Sprintf("%+v", asset),
 		}).Info("Dry run, asset configuration will be skipped.")
-		return nil
+		return result, nil
 	}
 
-	defer metrics.MeasureRuntime([]string{"butler", "configure_runtime"}, time.Now())
+	// the asset vendor, model are known once the asset is logged into.
+	defer func(start time.Time) {
+		metrics.MeasureRuntimeWithLabels([]string{"butler", "configure_runtime"}, start, labels(asset))
+	}(time.Now())
 
 	b.Log.WithFields(logrus.Fields{
 		"component": component,
@@ -42,19 +45,19 @@ func (b *Butler) configureAsset(config []byte, asset *asset.Asset) (err error) {
 
 	bmcConn := bmclogin.Params{
 		IpAddresses:     asset.IPAddresses,
-		Credentials:     b.Config.Credentials,
+		Credentials:     b.Creds.Credentials(asset),
 		CheckCredential: true,
 		Retries:         1,
-		StopChan:        b.StopChan,
 	}
 
 	//connect to the bmc/chassis bmc
-	client, loginInfo, err := bmcConn.Login()
+	client, loginInfo, err := b.login(ctx, &bmcConn)
 	if err != nil {
-		return err
+		return result, err
 	}
 
 	asset.IPAddress = loginInfo.ActiveIpAddress
+	b.Creds.Worked(asset, loginInfo.WorkingCredentials)
 
 	switch client.(type) {
 	case devices.Bmc:
@@ -69,17 +72,42 @@ func (b *Butler) configureAsset(config []byte, asset *asset.Asset) (err error) {
 
 		//Setup a resource instance
 		//Get any templated values in the asset config rendered
-		resourceInstance := resource.Resource{Log: log, Asset: asset, Secrets: b.Secrets}
+		// unknown keys are rejected unless validation is skipped.
+		resourceInstance := resource.Resource{Log: log, Asset: asset, Secrets: b.Secrets, Strict: !b.Config.SkipValidate}
+
+		//rendered config is a *resource.Config type,
+		//the layers that apply to the asset are merged once the vendor, model are known.
+		renderedConfig, _, err := resourceInstance.LoadTemplates(config)
+		if err != nil {
+			bmc.Close(context.TODO())
+			metrics.IncrCounterWithLabels([]string{"butler", "render_fail"}, 1, labels(asset))
+			return result, fmt.Errorf("unable to render configuration: %s", err)
+		}
 
-		//rendered config is a *cfgresources.ResourcesConfig type
-		renderedConfig := resourceInstance.LoadConfigResources(config)
 		if renderedConfig == nil {
-			return errors.New("No BMC configuration to be applied")
+			bmc.Close(context.TODO())
+			return result, errors.New("No BMC configuration to be applied")
+		}
+
+		if !b.Config.SkipValidate {
+			err = renderedConfig.Validate()
+		}
+
+		if err != nil {
+			bmc.Close(context.TODO())
+			metrics.IncrCounterWithLabels([]string{"butler", "validate_fail"}, 1, labels(asset))
+			return result, fmt.Errorf("invalid configuration: %s", err)
+		}
+
+		//redfish targets are applied with the credentials the BMC was logged into with.
+		var redfish *configure.Redfish
+		if len(renderedConfig.Redfish) > 0 {
+			redfish = configure.NewRedfish(asset.IPAddress, loginInfo.WorkingCredentials, renderedConfig.Redfish, log)
 		}
 
 		// Apply configuration
-		c := configure.NewBmcConfigurator(bmc, asset, b.Config.Resources, renderedConfig, b.Config, b.StopChan, log)
-		c.Apply()
+		c := configure.NewBmcConfigurator(bmc, asset, resources, &renderedConfig.ResourcesConfig, redfish, b.Config, b.State, b.Signer, log)
+		result = c.Apply(ctx)
 
 		bmc.Close(context.TODO())
 	case devices.Cmc:
@@ -94,29 +122,59 @@ func (b *Butler) configureAsset(config []byte, asset *asset.Asset) (err error) {
 
 		//Setup a resource instance
 		//Get any templated values in the asset config rendered
-		resourceInstance := resource.Resource{Log: log, Asset: asset, Secrets: b.Secrets}
+		// unknown keys are rejected unless validation is skipped.
+		resourceInstance := resource.Resource{Log: log, Asset: asset, Secrets: b.Secrets, Strict: !b.Config.SkipValidate}
+
+		renderedConfig, _, err := resourceInstance.LoadTemplates(config)
+		if err != nil {
+			chassis.Close()
+			metrics.IncrCounterWithLabels([]string{"butler", "render_fail"}, 1, labels(asset))
+			return result, fmt.Errorf("unable to render configuration: %s", err)
+		}
 
-		renderedConfig := resourceInstance.LoadConfigResources(config)
 		if renderedConfig == nil {
-			return errors.New("No BMC configuration to be applied")
+			chassis.Close()
+			return result, errors.New("No BMC configuration to be applied")
+		}
+
+		if !b.Config.SkipValidate {
+			err = renderedConfig.Validate()
+		}
+
+		if err != nil {
+			chassis.Close()
+			metrics.IncrCounterWithLabels([]string{"butler", "validate_fail"}, 1, labels(asset))
+			return result, fmt.Errorf("invalid configuration: %s", err)
 		}
 
 		if renderedConfig.SetupChassis != nil {
 			s := configure.NewCmcSetup(
 				chassis,
 				asset,
-				b.Config.Resources,
+				resources,
 				renderedConfig.SetupChassis,
 				b.Config,
-				b.StopChan,
 				b.Log,
 			)
-			s.Apply()
+			result = s.Apply(ctx)
 		}
 
 		// Apply configuration
-		c := configure.NewCmcConfigurator(chassis, asset, b.Config.Resources, renderedConfig, b.StopChan, log)
-		c.Apply()
+		c := configure.NewCmcConfigurator(chassis, asset, resources, &renderedConfig.ResourcesConfig, b.Config, b.State, log)
+		r := c.Apply(ctx)
+
+		result.Applied = append(result.Applied, r.Applied...)
+		result.Failed = append(result.Failed, r.Failed...)
+		result.Skipped = append(result.Skipped, r.Skipped...)
+		result.Errors = append(result.Errors, r.Errors...)
+		result.TimedOut = result.TimedOut || r.TimedOut
+		for resource, attempts := range r.Retried {
+			if result.Retried == nil {
+				result.Retried = make(map[string]int)
+			}
+
+			result.Retried[resource] = attempts
+		}
 
 		chassis.Close()
 	default:
@@ -124,8 +182,8 @@ func (b *Butler) configureAsset(config []byte, asset *asset.Asset) (err error) {
 			"component": component,
 			"Asset":     fmt.Sprintf("%+v", asset),
 		}).Warn("Unknown device type.")
-		return errors.New("Unknown asset type")
+		return result, errors.New("Unknown asset type")
 	}
 
-	return err
+	return result, err
 }
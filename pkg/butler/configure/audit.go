@@ -0,0 +1,468 @@
+package configure
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/bmc-toolbox/bmclib/cfgresources"
+	"github.com/sirupsen/logrus"
+)
+
+// The reader interfaces below are implemented by devices
+// that are able to report the current state of a configuration resource,
+// resources on devices that don't implement a reader are not audited by default,
+// and are reported failed if their audit is requested.
+
+// SyslogReader returns the current syslog configuration of a BMC.
+type SyslogReader interface {
+	CurrentSyslog() (*cfgresources.Syslog, error)
+}
+
+// NtpReader returns the current NTP configuration of a BMC.
+type NtpReader interface {
+	CurrentNtp() (*cfgresources.Ntp, error)
+}
+
+// LdapReader returns the current LDAP configuration of a BMC.
+type LdapReader interface {
+	CurrentLdap() (*cfgresources.Ldap, error)
+}
+
+// UserReader returns the current user accounts on a BMC.
+type UserReader interface {
+	CurrentUsers() ([]*cfgresources.User, error)
+}
+
+// NetworkReader returns the current network configuration of a BMC.
+type NetworkReader interface {
+	CurrentNetwork() (*cfgresources.Network, error)
+}
+
+// FieldsReader is implemented by readers that report only some of the fields of a resource,
+// the fields not listed are not compared.
+type FieldsReader interface {
+	Fields(resource string) []string
+}
+
+var (
+	// ErrAuditUnsupported is returned when the current state of a resource can't be read from a device.
+	ErrAuditUnsupported = errors.New("reading the current configuration is not supported for this resource and device, it can't be audited")
+
+	// auditResources are the resources audited when --resources is not given,
+	// those the device has no reader for are left out.
+	auditResources = []string{"user", "syslog", "ntp", "ldap", "network"}
+)
+
+// Drift is a difference between the declared and the current configuration of a resource.
+type Drift struct {
+	Resource string
+	Field    string
+	Want     string
+	Have     string
+}
+
+func (d Drift) String() string {
+	return fmt.Sprintf("%s: have '%s', want '%s'", d.Field, d.Have, d.Want)
+}
+
+// AuditResult holds the outcome of an audit on an asset.
+type AuditResult struct {
+	Compliant  []string
+	Drifted    []string
+	Failed     []string
+	NotAudited []string //resources whose current configuration can't be read, neither compliant nor failed
+	Drift      []Drift
+	Errors     []string //the errors of resources that could not be audited
+	TimedOut   bool     //set when a resource or the asset timed out
+}
+
+// readable returns the resources the device implements a reader for.
+func readable(device interface{}, resources []string) (r []string) {
+
+	for _, resource := range resources {
+		var ok bool
+		switch resource {
+		case "user":
+			_, ok = device.(UserReader)
+		case "syslog":
+			_, ok = device.(SyslogReader)
+		case "ntp":
+			_, ok = device.(NtpReader)
+		case "ldap":
+			_, ok = device.(LdapReader)
+		case "network":
+			_, ok = device.(NetworkReader)
+		}
+
+		if ok {
+			r = append(r, resource)
+		}
+	}
+
+	return r
+}
+
+// auditResource reads the current state of the resource from the device,
+// and returns the differences with the declared configuration.
+// nolint: gocyclo
+func auditResource(device interface{}, resource string, config *cfgresources.ResourcesConfig) (declared bool, drift []Drift, err error) {
+
+	var fields []string
+	if reader, ok := device.(FieldsReader); ok {
+		fields = reader.Fields(resource)
+	}
+
+	switch resource {
+	case "user":
+		if config.User == nil {
+			return false, drift, nil
+		}
+
+		reader, ok := device.(UserReader)
+		if !ok {
+			return true, drift, ErrAuditUnsupported
+		}
+
+		current, err := reader.CurrentUsers()
+		if err != nil {
+			return true, drift, err
+		}
+
+		return true, diffUsers(config.User, current, fields), nil
+	case "syslog":
+		if config.Syslog == nil {
+			return false, drift, nil
+		}
+
+		reader, ok := device.(SyslogReader)
+		if !ok {
+			return true, drift, ErrAuditUnsupported
+		}
+
+		current, err := reader.CurrentSyslog()
+		if err != nil {
+			return true, drift, err
+		}
+
+		return true, diff(resource, "", config.Syslog, current, fields), nil
+	case "ntp":
+		if config.Ntp == nil {
+			return false, drift, nil
+		}
+
+		reader, ok := device.(NtpReader)
+		if !ok {
+			return true, drift, ErrAuditUnsupported
+		}
+
+		current, err := reader.CurrentNtp()
+		if err != nil {
+			return true, drift, err
+		}
+
+		return true, diff(resource, "", config.Ntp, current, fields), nil
+	case "ldap":
+		if config.Ldap == nil {
+			return false, drift, nil
+		}
+
+		reader, ok := device.(LdapReader)
+		if !ok {
+			return true, drift, ErrAuditUnsupported
+		}
+
+		current, err := reader.CurrentLdap()
+		if err != nil {
+			return true, drift, err
+		}
+
+		return true, diff(resource, "", config.Ldap, current, fields), nil
+	case "network":
+		if config.Network == nil {
+			return false, drift, nil
+		}
+
+		reader, ok := device.(NetworkReader)
+		if !ok {
+			return true, drift, ErrAuditUnsupported
+		}
+
+		current, err := reader.CurrentNetwork()
+		if err != nil {
+			return true, drift, err
+		}
+
+		return true, diff(resource, "", config.Network, current, fields), nil
+	default:
+		return false, drift, fmt.Errorf("unknown resource: %s", resource)
+	}
+}
+
+// diff compares the fields of two structs of the same type, only the given fields if not empty,
+// fields are named by their yaml tags, password fields are never compared.
+func diff(resource string, prefix string, want interface{}, have interface{}, fields []string) (drift []Drift) {
+
+	wantV := reflect.Indirect(reflect.ValueOf(want))
+	haveV := reflect.Indirect(reflect.ValueOf(have))
+
+	if !haveV.IsValid() {
+		haveV = reflect.New(wantV.Type()).Elem()
+	}
+
+	for i := 0; i < wantV.NumField(); i++ {
+		field := wantV.Type().Field(i)
+
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "" {
+			name = field.Name
+		}
+
+		if strings.EqualFold(name, "password") || (len(fields) > 0 && !contains(fields, name)) {
+			continue
+		}
+
+		// pointer fields are compared by the values they point to.
+		w := indirect(wantV.Field(i))
+		h := indirect(haveV.Field(i))
+		if !reflect.DeepEqual(w, h) {
+			drift = append(drift, Drift{Resource: resource, Field: prefix + name, Want: fmt.Sprintf("%v", w), Have: fmt.Sprintf("%v", h)})
+		}
+	}
+
+	return drift
+}
+
+// indirect returns the value the field points to, nil for nil pointers.
+func indirect(v reflect.Value) interface{} {
+
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	return v.Interface()
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// diffUsers compares declared user accounts with the accounts present on the BMC,
+// accounts are matched by name.
+func diffUsers(want []*cfgresources.User, have []*cfgresources.User, fields []string) (drift []Drift) {
+
+	for _, w := range want {
+		var current *cfgresources.User
+		for _, h := range have {
+			if h.Name == w.Name {
+				current = h
+				break
+			}
+		}
+
+		// an absent account is only drift if it was declared to be enabled.
+		if current == nil {
+			if w.Enable {
+				drift = append(drift, Drift{Resource: "user", Field: w.Name, Want: "present", Have: "absent"})
+			}
+			continue
+		}
+
+		drift = append(drift, diff("user", w.Name+".", w, current, fields)...)
+	}
+
+	return drift
+}
+
+// audit runs auditResource for each of the resources and logs the outcome.
+func audit(ctx context.Context, device interface{}, resources []string, config *cfgresources.ResourcesConfig, timeout time.Duration, fields logrus.Fields, logger *logrus.Logger) (result AuditResult) {
+
+	for _, resource := range resources {
+
+		f := logrus.Fields{"resource": resource}
+		for k, v := range fields {
+			f[k] = v
+		}
+
+		var declared bool
+		var drift []Drift
+		err := ctx.Err()
+		if err == nil {
+			err = run(ctx, timeout, func() (err error) {
+				declared, drift, err = auditResource(device, resource, config)
+				return err
+			})
+		}
+
+		// the asset or resource timed out or an interrupt was received,
+		// the resource is abandoned and no further resources are audited.
+		if Interrupted(err) {
+			result.Failed = append(result.Failed, resource)
+			result.Errors = append(result.Errors, resource+": "+err.Error())
+			result.TimedOut = result.TimedOut || TimedOut(err)
+			f["Error"] = err
+			logger.WithFields(f).Warn("Resource audit abandoned, remaining resources not audited.")
+			break
+		}
+
+		if !declared && err == nil {
+			continue
+		}
+
+		switch {
+		case err == ErrAuditUnsupported:
+			result.Failed = append(result.Failed, resource)
+			result.Errors = append(result.Errors, resource+": "+err.Error())
+			logger.WithFields(f).Warn("Resource can't be audited, reading its current configuration is not supported by device.")
+		case err != nil:
+			result.Failed = append(result.Failed, resource)
+			result.Errors = append(result.Errors, resource+": "+err.Error())
+			f["Error"] = err
+			logger.WithFields(f).Warn("Resource audit returned errors.")
+		case len(drift) > 0:
+			result.Drifted = append(result.Drifted, resource)
+			result.Drift = append(result.Drift, drift...)
+
+			diffs := make([]string, 0, len(drift))
+			for _, d := range drift {
+				diffs = append(diffs, d.String())
+			}
+
+			f["diff"] = strings.Join(diffs, "; ")
+			logger.WithFields(f).Info("Resource configuration drifted.")
+		default:
+			result.Compliant = append(result.Compliant, resource)
+			logger.WithFields(f).Debug("Resource configuration matches.")
+		}
+	}
+
+	return result
+}
+
+// Audit compares the current BMC configuration with the declared configuration,
+// no changes are made to the BMC, resources are no longer audited once the context is done.
+func (b *Bmc) Audit(ctx context.Context) AuditResult {
+
+	// bmclib devices don't report their current configuration,
+	// it is read from the BMC Redfish API, resources Redfish doesn't expose fail to be audited if requested.
+	var reader interface{}
+	if b.redfish != nil {
+		reader = b.redfish
+	}
+
+	resources := append([]string{"https_cert"}, readable(reader, auditResources)...)
+	if len(b.resources) > 0 {
+		resources = b.resources
+	}
+
+	b.ip = b.asset.IPAddress
+
+	fields := logrus.Fields{
+		"Vendor":    b.vendor,
+		"Model":     b.model,
+		"Serial":    b.serial,
+		"IPAddress": b.ip,
+	}
+
+	// https_cert is audited using the same validation carried out before a cert is replaced.
+	var auditCert bool
+	filtered := make([]string, 0)
+	for _, r := range resources {
+		if r == "https_cert" {
+			auditCert = true
+			continue
+		}
+		filtered = append(filtered, r)
+	}
+
+	timeout := resourceTimeout(b.butlerConfig)
+	result := audit(ctx, reader, filtered, b.config, timeout, fields, b.logger)
+
+	if auditCert && b.config.HTTPSCert != nil && b.config.HTTPSCert.Attributes != nil && ctx.Err() == nil {
+		var certs []*x509.Certificate
+		err := run(ctx, timeout, func() (err error) {
+			certs, _, err = b.bmc.CurrentHTTPSCert()
+			return err
+		})
+
+		if err != nil {
+			result.TimedOut = result.TimedOut || TimedOut(err)
+			result.Failed = append(result.Failed, "https_cert")
+			result.Errors = append(result.Errors, "https_cert: "+err.Error())
+			b.logger.WithFields(fields).WithFields(logrus.Fields{
+				"resource": "https_cert",
+				"Error":    err,
+			}).Warn("Resource audit returned errors.")
+		} else if reason, valid := b.validateCert(certs, b.config.HTTPSCert); !valid {
+			result.Drifted = append(result.Drifted, "https_cert")
+			result.Drift = append(result.Drift, Drift{Resource: "https_cert", Field: "certificate", Want: "valid", Have: reason})
+		} else {
+			result.Compliant = append(result.Compliant, "https_cert")
+		}
+	}
+
+	logAuditResult(b.logger, fields, result)
+
+	return result
+}
+
+// Audit reports the chassis resources as not audited,
+// chassis don't report their current configuration, nor is it read from any other API,
+// chassis are not audited until a reader is implemented.
+func (b *Cmc) Audit(ctx context.Context) AuditResult {
+
+	resources := auditResources
+	if len(b.resources) > 0 {
+		resources = b.resources
+	}
+
+	b.ip = b.asset.IPAddress
+
+	fields := logrus.Fields{
+		"Vendor":    b.vendor,
+		"Model":     b.model,
+		"Serial":    b.serial,
+		"IPAddress": b.ip,
+	}
+
+	result := AuditResult{NotAudited: resources}
+	b.logger.WithFields(fields).WithFields(logrus.Fields{
+		"not_audited": strings.Join(resources, ", "),
+	}).Warn("Chassis configuration can't be read, chassis not audited.")
+
+	return result
+}
+
+func logAuditResult(logger *logrus.Logger, fields logrus.Fields, result AuditResult) {
+
+	f := logrus.Fields{
+		"compliant":   strings.Join(result.Compliant, ", "),
+		"drifted":     strings.Join(result.Drifted, ", "),
+		"failed":      strings.Join(result.Failed, ", "),
+		"not_audited": strings.Join(result.NotAudited, ", "),
+	}
+
+	for k, v := range fields {
+		f[k] = v
+	}
+
+	if len(result.Drifted) > 0 || len(result.Failed) > 0 {
+		logger.WithFields(f).Warn("BMC configuration does not match declared configuration.")
+		return
+	}
+
+	logger.WithFields(f).Info("BMC configuration matches declared configuration.")
+}
@@ -0,0 +1,126 @@
+package configure
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/bmc-toolbox/bmclib/cfgresources"
+	"github.com/sirupsen/logrus"
+)
+
+// fakeDevice implements SyslogReader and UserReader.
+type fakeDevice struct {
+	syslog *cfgresources.Syslog
+	users  []*cfgresources.User
+}
+
+func (f *fakeDevice) CurrentSyslog() (*cfgresources.Syslog, error) {
+	return f.syslog, nil
+}
+
+func (f *fakeDevice) CurrentUsers() ([]*cfgresources.User, error) {
+	return f.users, nil
+}
+
+// TestAudit tests resources are reported as compliant, drifted,
+// or failed when the current configuration can't be read from the device.
+func TestAudit(t *testing.T) {
+
+	device := &fakeDevice{
+		syslog: &cfgresources.Syslog{Server: "syslog.example.com", Port: 514, Enable: true},
+		users: []*cfgresources.User{
+			{Name: "Administrator", Password: "unknown", Role: "user", Enable: true},
+		},
+	}
+
+	config := &cfgresources.ResourcesConfig{
+		Syslog: &cfgresources.Syslog{Server: "syslog.example.com", Port: 514, Enable: true},
+		User: []*cfgresources.User{
+			{Name: "Administrator", Password: "hunter2", Role: "admin", Enable: true},
+			{Name: "Ops", Password: "foobar", Role: "user", Enable: true},
+		},
+		Ntp: &cfgresources.Ntp{Enable: true, Server1: "ntp0.example.com"},
+	}
+
+	result := audit(context.Background(), device, auditResources, config, 0, logrus.Fields{}, logrus.New())
+
+	if len(result.Compliant) != 1 || result.Compliant[0] != "syslog" {
+		t.Fatalf("Expected syslog to be compliant, got %v", result.Compliant)
+	}
+
+	if len(result.Failed) != 1 || result.Failed[0] != "ntp" {
+		t.Fatalf("Expected ntp to fail to be audited, got %v", result.Failed)
+	}
+
+	if len(result.Errors) != 1 || result.Errors[0] != "ntp: "+ErrAuditUnsupported.Error() {
+		t.Fatalf("Expected the ntp audit error, got %v", result.Errors)
+	}
+
+	if len(result.Drifted) != 1 || result.Drifted[0] != "user" {
+		t.Fatalf("Expected user to be drifted, got %v", result.Drifted)
+	}
+
+	// The password is never compared, the role and the missing account are.
+	expected := map[string]Drift{
+		"Administrator.role": {Resource: "user", Field: "Administrator.role", Want: "admin", Have: "user"},
+		"Ops":                {Resource: "user", Field: "Ops", Want: "present", Have: "absent"},
+	}
+
+	if len(result.Drift) != len(expected) {
+		t.Fatalf("Expected %d drifted fields, got %v", len(expected), result.Drift)
+	}
+
+	for _, d := range result.Drift {
+		if expected[d.Field] != d {
+			t.Fatalf("Unexpected drift: %+v", d)
+		}
+	}
+}
+
+// TestDiff tests pointer fields are compared by the values they point to,
+// and only the fields listed are compared.
+func TestDiff(t *testing.T) {
+
+	type settings struct {
+		Name    string `yaml:"name"`
+		Port    *int   `yaml:"port"`
+		Servers []string
+	}
+
+	port, samePort := 514, 514
+	want := &settings{Name: "a", Port: &port, Servers: []string{"ntp0"}}
+	have := &settings{Name: "a", Port: &samePort, Servers: []string{"ntp0"}}
+
+	if drift := diff("test", "", want, have, nil); len(drift) != 0 {
+		t.Fatalf("Expected no drift, got %v", drift)
+	}
+
+	have.Port = nil
+	have.Name = "b"
+	drift := diff("test", "", want, have, nil)
+	if len(drift) != 2 || drift[1] != (Drift{Resource: "test", Field: "port", Want: "514", Have: "<nil>"}) {
+		t.Fatalf("Expected name and port to drift, got %v", drift)
+	}
+
+	drift = diff("test", "", want, have, []string{"name"})
+	if len(drift) != 1 || drift[0].Field != "name" {
+		t.Fatalf("Expected just name to drift, got %v", drift)
+	}
+}
+
+// TestReadable tests resources the device has no reader for are left out of the default audit.
+func TestReadable(t *testing.T) {
+
+	if r := readable(&fakeDevice{}, auditResources); !reflect.DeepEqual(r, []string{"user", "syslog"}) {
+		t.Fatalf("Expected user, syslog to be readable, got %v", r)
+	}
+
+	if r := readable(&Redfish{}, auditResources); !reflect.DeepEqual(r, []string{"user", "ntp", "ldap", "network"}) {
+		t.Fatalf("Expected syslog to not be readable from Redfish, got %v", r)
+	}
+
+	if r := readable(nil, auditResources); len(r) != 0 {
+		t.Fatalf("Expected no resources readable without a reader, got %v", r)
+	}
+}
@@ -1,8 +1,6 @@
 package configure
 
 import (
-	"bytes"
-	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -11,9 +9,7 @@ import (
 	"encoding/pem"
 	"fmt"
 	"net"
-	"os/exec"
 	"strings"
-	"syscall"
 	"time"
 
 	"github.com/bmc-toolbox/bmclib/cfgresources"
@@ -121,59 +117,22 @@ func (b *Bmc) certificateSetup() (bool, error) {
 // signCSR signs the given csr with the configured signer
 func (b *Bmc) signCSR(csr []byte, commonName string) ([]byte, error) {
 
-	config := b.butlerConfig.CertSigner
-
-	var cmd string
-	var args []string
-	var env = make(map[string]string)
-
-	// if we're in trace logging, pass the debugging env var to the signer.
-	if b.butlerConfig.Trace {
-		env["DEBUG_SIGNER"] = "1"
-	}
-
-	// based on configuration, setup cmd, args, env vars
-	switch config.Client {
-	case "fakeSigner":
-		cmd = config.FakeSigner.Bin
-		args = config.FakeSigner.Args
-		env["PASSPHRASE"] = config.FakeSigner.Passphrase
-
-	case "lemurSigner":
-		cmd = config.LemurSigner.Bin
-		env["KEY"] = config.LemurSigner.Key
-		env["ENDPOINT"] = config.LemurSigner.Endpoint
-		a := []string{
-			"--valid-years", config.LemurSigner.ValidityYears,
-			"--authority", config.LemurSigner.Authority,
-			"--owner", config.LemurSigner.Owner,
-			"--common-name", commonName,
-		}
-
-		args = append(args, a...)
-	default:
-		return []byte{}, fmt.Errorf("Unknown cert signer declared in butler config")
-	}
-
-	if cmd == "" {
-		return []byte{}, fmt.Errorf("No signer binary declared in butler config")
+	if b.signer == nil {
+		return []byte{}, fmt.Errorf("No cert signer setup")
 	}
 
 	b.logger.WithFields(logrus.Fields{
-		"component": "signCSR",
-		"signer":    config.Client,
-		"cmd":       cmd,
-		//"env":       env,
-		"args": strings.Join(args, " "),
+		"component":  "signCSR",
+		"signer":     b.butlerConfig.CertSigner.Client,
+		"commonName": commonName,
 	}).Trace("Invoked cert signer.")
 
-	// sign the CSR with the configured signer.
-	stdOut, stdErr, exitCode := execCmd(cmd, env, args, csr)
-	if exitCode != 0 {
-		return []byte{}, fmt.Errorf("Error signing CSR: %s", stdErr)
+	crt, err := b.signer.Sign(csr, b.config.HTTPSCert.Attributes)
+	if err != nil {
+		return []byte{}, fmt.Errorf("Error signing CSR: %s", err)
 	}
 
-	return []byte(stdOut), nil
+	return crt, nil
 }
 
 // Validate a x509 cert attributes with declared configuration
@@ -277,64 +236,6 @@ func match(field []string, config string) bool {
 	return false
 }
 
-// run command with args
-func execCmd(c string, env map[string]string, args []string, stdIn []byte) (stdOut string, stdErr string, exitCode int) {
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
-	cmd := exec.CommandContext(ctx, c, args...)
-
-	// setup the stdin/stdout buffers
-	var outBuf, errBuf bytes.Buffer
-
-	// if there are env variables declared in the checks config,
-	// set them up in the command environment.
-	for k, v := range env {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
-	}
-
-	// setup output/input redirects
-	cmd.Stdout = &outBuf
-	cmd.Stderr = &errBuf
-
-	//feed in given stdin data
-	cmd.Stdin = bytes.NewBuffer(stdIn)
-
-	// To ignore SIGINTs received by the parent process,
-	// this is to allow watson to gracefully handle ongoing goroutines,
-	// this causes the commands to be spawned in its own process group.
-	cmd.SysProcAttr = &syscall.SysProcAttr{
-		Setpgid: true,
-	}
-
-	// run command
-	err := cmd.Run()
-	stdOut = outBuf.String()
-	stdErr = errBuf.String()
-
-	// check if cmd.Run returned an error
-	if err != nil {
-		// check if we have an exit error
-		exitError, ok := err.(*exec.ExitError)
-		if !ok {
-			// if we do not have an exit error, we return 1
-			exitCode = 1
-			if len(stdErr) == 0 {
-				stdErr = err.Error()
-			}
-		} else {
-			ws := exitError.Sys().(syscall.WaitStatus)
-			exitCode = ws.ExitStatus()
-		}
-	} else {
-		ws := cmd.ProcessState.Sys().(syscall.WaitStatus)
-		exitCode = ws.ExitStatus()
-	}
-
-	return stdOut, stdErr, exitCode
-}
-
 func generateCsr(c *cfgresources.HTTPSCertAttributes) (csr, privateKey []byte, err error) {
 
 	// https://oidref.com/1.2.840.113549.1.9.1
@@ -1,9 +1,13 @@
 package configure
 
 import (
+	"context"
+	"fmt"
 	"strings"
 
 	"github.com/bmc-toolbox/bmcbutler/pkg/asset"
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+	"github.com/bmc-toolbox/bmcbutler/pkg/state"
 	"github.com/bmc-toolbox/bmclib/cfgresources"
 	"github.com/bmc-toolbox/bmclib/devices"
 	"github.com/sirupsen/logrus"
@@ -11,17 +15,18 @@ import (
 
 // Cmc struct declares attributes required to apply configuration.
 type Cmc struct {
-	asset     *asset.Asset
-	bmc       devices.Cmc
-	resources []string
-	configure devices.Configure
-	config    *cfgresources.ResourcesConfig
-	logger    *logrus.Logger
-	ip        string
-	serial    string
-	vendor    string
-	model     string
-	stopChan  <-chan struct{}
+	asset        *asset.Asset
+	bmc          devices.Cmc
+	resources    []string
+	configure    devices.Configure
+	config       *cfgresources.ResourcesConfig
+	butlerConfig *config.Params
+	state        *state.Store
+	logger       *logrus.Logger
+	ip           string
+	serial       string
+	vendor       string
+	model        string
 }
 
 // NewCmcConfigurator returns a new configure struct to apply configuration.
@@ -29,7 +34,8 @@ func NewCmcConfigurator(bmc devices.Cmc,
 	asset *asset.Asset,
 	resources []string,
 	config *cfgresources.ResourcesConfig,
-	stopChan <-chan struct{},
+	butlerConfig *config.Params,
+	state *state.Store,
 	logger *logrus.Logger) *Cmc {
 
 	return &Cmc{
@@ -41,22 +47,21 @@ func NewCmcConfigurator(bmc devices.Cmc,
 		resources: resources,
 		// devices.Cmc is type asserted to apply configuration,
 		// this is possible since devices.Bmc embeds the Configure interface.
-		configure: bmc.(devices.Configure),
-		config:    config,
-		logger:    logger,
-		stopChan:  stopChan,
-		ip:        asset.IPAddress,
-		serial:    asset.Serial,
-		vendor:    asset.Vendor,
-		model:     asset.Model,
+		configure:    bmc.(devices.Configure),
+		config:       config,
+		butlerConfig: butlerConfig,
+		state:        state,
+		logger:       logger,
+		ip:           asset.IPAddress,
+		serial:       asset.Serial,
+		vendor:       asset.Vendor,
+		model:        asset.Model,
 	}
 }
 
-// Apply applies configuration.
-func (b *Cmc) Apply() { //nolint: gocyclo
-
-	var interrupt bool
-	go func() { <-b.stopChan; interrupt = true }()
+// Apply applies configuration,
+// resources are no longer applied once the context is done, i.e the asset timed out or an interrupt was received.
+func (b *Cmc) Apply(ctx context.Context) (result Result) { //nolint: gocyclo
 
 	// slice of configuration resources to be applied.
 	var resources []string
@@ -70,7 +75,7 @@ func (b *Cmc) Apply() { //nolint: gocyclo
 
 	b.ip = b.asset.IPAddress
 
-	var failed, success []string
+	var failed, success, skipped []string
 
 	b.logger.WithFields(logrus.Fields{
 		"Vendor":    b.vendor,
@@ -84,54 +89,58 @@ func (b *Cmc) Apply() { //nolint: gocyclo
 
 		var err error
 
-		// check if an interrupt was received.
-		if interrupt == true {
+		// check if the asset timed out or an interrupt was received.
+		if ctx.Err() != nil {
+			result.stopped(ctx.Err())
 			b.logger.WithFields(logrus.Fields{
 				"Vendor":    b.vendor,
 				"Model":     b.model,
 				"Serial":    b.serial,
 				"IPAddress": b.ip,
-			}).Debug("Received interrupt.")
+				"Error":     ctx.Err(),
+			}).Debug("Asset timed out or interrupt received, remaining resources not applied.")
 			break
 		}
 
-		switch resource {
-		case "user":
-			if b.config.User != nil {
-				err = b.configure.User(b.config.User)
-			}
-		case "syslog":
-			if b.config.Syslog != nil {
-				err = b.configure.Syslog(b.config.Syslog)
-			}
-		case "ntp":
-			if b.config.Ntp != nil {
-				err = b.configure.Ntp(b.config.Ntp)
-			}
-		case "ldap":
-			if b.config.Ldap != nil {
-				err = b.configure.Ldap(b.config.Ldap)
-			}
-		case "ldap_group":
-			if b.config.LdapGroup != nil && b.config.Ldap != nil {
-				err = b.configure.LdapGroup(b.config.LdapGroup, b.config.Ldap)
-			}
-		case "license":
-			if b.config.License != nil {
-				err = b.configure.SetLicense(b.config.License)
-			}
-		case "network":
-			if b.config.Network != nil {
-				_, err = b.configure.Network(b.config.Network)
-			}
-		default:
+		// skip resources unchanged since they were last applied.
+		skip, hash := unchanged(b.state, b.butlerConfig, b.serial, resource, b.config)
+		if skip {
+			skipped = append(skipped, resource)
 			b.logger.WithFields(logrus.Fields{
-				"resource": resource,
-			}).Warn("Unknown resource.")
+				"resource":  resource,
+				"Vendor":    b.vendor,
+				"Model":     b.model,
+				"Serial":    b.serial,
+				"IPAddress": b.ip,
+			}).Debug("Resource unchanged since last applied, skipped.")
+			continue
+		}
+
+		err = result.attempt(ctx, b.butlerConfig, resource, func() error {
+			return b.applyResource(resource)
+		})
+
+		// the resource timed out or an interrupt was received,
+		// the resource is abandoned and no further resources are applied.
+		if Interrupted(err) {
+			failed = append(failed, resource)
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %s", resource, err))
+			result.TimedOut = result.TimedOut || TimedOut(err)
+			recordState(b.state, b.serial, resource, hash, err, b.logger)
+			b.logger.WithFields(logrus.Fields{
+				"resource":  resource,
+				"Vendor":    b.vendor,
+				"Model":     b.model,
+				"Serial":    b.serial,
+				"IPAddress": b.ip,
+				"Error":     err,
+			}).Warn("Resource configuration abandoned, remaining resources not applied.")
+			break
 		}
 
 		if err != nil {
 			failed = append(failed, resource)
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %s", resource, err))
 			b.logger.WithFields(logrus.Fields{
 				"resource":  resource,
 				"Vendor":    b.vendor,
@@ -144,6 +153,8 @@ func (b *Cmc) Apply() { //nolint: gocyclo
 			success = append(success, resource)
 		}
 
+		recordState(b.state, b.serial, resource, hash, err, b.logger)
+
 		b.logger.WithFields(logrus.Fields{
 			"resource":  resource,
 			"Vendor":    b.vendor,
@@ -154,6 +165,10 @@ func (b *Cmc) Apply() { //nolint: gocyclo
 
 	}
 
+	result.Applied = success
+	result.Failed = failed
+	result.Skipped = skipped
+
 	if len(failed) > 0 {
 		b.logger.WithFields(logrus.Fields{
 			"Vendor":    b.vendor,
@@ -164,7 +179,7 @@ func (b *Cmc) Apply() { //nolint: gocyclo
 			"applied":   strings.Join(success, ", "),
 			"failed":    strings.Join(failed, ", "),
 		}).Warn("One or more resources failed to apply.")
-		return
+		return result
 	}
 
 	b.logger.WithFields(logrus.Fields{
@@ -174,5 +189,49 @@ func (b *Cmc) Apply() { //nolint: gocyclo
 		"IPAddress": b.ip,
 		"success":   true,
 		"applied":   strings.Join(success, ", "),
+		"skipped":   strings.Join(skipped, ", "),
 	}).Info("BMC configuration actions successful.")
+
+	return result
+}
+
+// applyResource applies the declared configuration of the resource.
+func (b *Cmc) applyResource(resource string) (err error) {
+
+	switch resource {
+	case "user":
+		if b.config.User != nil {
+			err = b.configure.User(b.config.User)
+		}
+	case "syslog":
+		if b.config.Syslog != nil {
+			err = b.configure.Syslog(b.config.Syslog)
+		}
+	case "ntp":
+		if b.config.Ntp != nil {
+			err = b.configure.Ntp(b.config.Ntp)
+		}
+	case "ldap":
+		if b.config.Ldap != nil {
+			err = b.configure.Ldap(b.config.Ldap)
+		}
+	case "ldap_group":
+		if b.config.LdapGroup != nil && b.config.Ldap != nil {
+			err = b.configure.LdapGroup(b.config.LdapGroup, b.config.Ldap)
+		}
+	case "license":
+		if b.config.License != nil {
+			err = b.configure.SetLicense(b.config.License)
+		}
+	case "network":
+		if b.config.Network != nil {
+			_, err = b.configure.Network(b.config.Network)
+		}
+	default:
+		b.logger.WithFields(logrus.Fields{
+			"resource": resource,
+		}).Warn("Unknown resource.")
+	}
+
+	return err
 }
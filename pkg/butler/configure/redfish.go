@@ -0,0 +1,272 @@
+package configure
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/resource"
+)
+
+// Redfish applies the Redfish targets declared in the redfish resource,
+// each target is read from the BMC Redfish API and only the properties that differ are PATCHed.
+type Redfish struct {
+	baseURL  string
+	username string
+	password string
+	targets  []*resource.RedfishTarget
+	client   *http.Client
+	logger   *logrus.Logger
+}
+
+// NewRedfish returns a Redfish struct to apply the Redfish targets on the BMC,
+// credentials are the credentials the BMC was logged into with.
+func NewRedfish(ip string, credentials map[string]string, targets []*resource.RedfishTarget, logger *logrus.Logger) *Redfish {
+
+	var username, password string
+	for username, password = range credentials {
+		break
+	}
+
+	return &Redfish{
+		baseURL:  "https://" + ip,
+		username: username,
+		password: password,
+		targets:  targets,
+		logger:   logger,
+		client: &http.Client{
+			Timeout: 60 * time.Second,
+			Transport: &http.Transport{
+				// BMCs ship with self signed certs.
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, // nolint: gosec
+			},
+		},
+	}
+}
+
+// request makes a Redfish API request, the response body is unmarshalled into out if not nil.
+func (r *Redfish) request(method string, path string, header map[string]string, body interface{}, out *map[string]interface{}) (http.Header, error) {
+
+	var payload []byte
+	if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequest(method, r.baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+
+	req.SetBasicAuth(r.username, r.password)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("%s %s returned status code %d: %s", method, path, resp.StatusCode, respBody)
+	}
+
+	if out != nil {
+		err = json.Unmarshal(respBody, out)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s unable to unmarshal response: %s", method, path, err)
+		}
+	}
+
+	return resp.Header, nil
+}
+
+// jsonValue returns the value as it would be unmarshalled from JSON,
+// yaml maps have interface{} keys and numbers are ints, JSON maps have string keys and numbers are float64.
+func jsonValue(v interface{}) interface{} {
+
+	switch value := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			m[fmt.Sprintf("%v", k)] = jsonValue(v)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			m[k] = jsonValue(v)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(value))
+		for idx, v := range value {
+			l[idx] = jsonValue(v)
+		}
+		return l
+	case int:
+		return float64(value)
+	case int64:
+		return float64(value)
+	case uint64:
+		return float64(value)
+	case float32:
+		return float64(value)
+	default:
+		return value
+	}
+}
+
+// redfishDiff returns the properties in want that differ from have,
+// nested objects are compared property by property.
+func redfishDiff(want map[string]interface{}, have map[string]interface{}) map[string]interface{} {
+
+	diff := make(map[string]interface{})
+	for key, w := range want {
+		h, exists := have[key]
+
+		wm, wIsMap := w.(map[string]interface{})
+		hm, hIsMap := h.(map[string]interface{})
+
+		switch {
+		case !exists:
+			diff[key] = w
+		case wIsMap && hIsMap:
+			if d := redfishDiff(wm, hm); len(d) > 0 {
+				diff[key] = d
+			}
+		case !reflect.DeepEqual(w, h):
+			diff[key] = w
+		}
+	}
+
+	return diff
+}
+
+// diffKeys returns a sorted list of the property paths in the diff.
+func diffKeys(diff map[string]interface{}, prefix string) []string {
+
+	keys := make([]string, 0)
+	for key, value := range diff {
+		if m, ok := value.(map[string]interface{}); ok {
+			keys = append(keys, diffKeys(m, prefix+key+".")...)
+			continue
+		}
+
+		keys = append(keys, prefix+key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// applyTarget reads the target from the BMC and PATCHes the properties that differ,
+// the properties changed are returned.
+func (r *Redfish) applyTarget(target *resource.RedfishTarget) (changed []string, err error) {
+
+	if target.Path == "" {
+		return changed, fmt.Errorf("redfish target declared with no path")
+	}
+
+	want, _ := jsonValue(target.Properties).(map[string]interface{})
+
+	var have map[string]interface{}
+	header, err := r.request("GET", target.Path, nil, nil, &have)
+	if err != nil {
+		return changed, err
+	}
+
+	diff := redfishDiff(want, have)
+	if len(diff) == 0 {
+		return changed, nil
+	}
+
+	patchPath := target.Path
+	if target.PatchPath != "" && target.PatchPath != target.Path {
+		patchPath = target.PatchPath
+
+		// settings pending on the settings object are applied on the next reset,
+		// they aren't PATCHed again.
+		var pending map[string]interface{}
+		header, err = r.request("GET", patchPath, nil, nil, &pending)
+		if err != nil {
+			return changed, err
+		}
+
+		diff = redfishDiff(diff, pending)
+		if len(diff) == 0 {
+			return changed, nil
+		}
+	}
+
+	// some BMCs require the ETag of the resource to be PATCHed.
+	var patchHeader map[string]string
+	if etag := header.Get("ETag"); etag != "" {
+		patchHeader = map[string]string{"If-Match": etag}
+	}
+
+	_, err = r.request("PATCH", patchPath, patchHeader, diff, nil)
+	if err != nil {
+		return changed, err
+	}
+
+	return diffKeys(diff, ""), nil
+}
+
+// Apply applies each of the Redfish targets,
+// targets are applied even if a previous target failed, the errors are returned combined.
+func (r *Redfish) Apply() error {
+
+	var errs []string
+	for _, target := range r.targets {
+		changed, err := r.applyTarget(target)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", target.Path, err))
+			continue
+		}
+
+		if len(changed) == 0 {
+			r.logger.WithFields(logrus.Fields{
+				"Path": target.Path,
+			}).Debug("Redfish target properties as declared, no changes applied.")
+			continue
+		}
+
+		r.logger.WithFields(logrus.Fields{
+			"Path":    target.Path,
+			"Changed": strings.Join(changed, ", "),
+		}).Info("Redfish target properties applied.")
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
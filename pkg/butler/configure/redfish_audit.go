@@ -0,0 +1,214 @@
+package configure
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/bmc-toolbox/bmclib/cfgresources"
+)
+
+// The Redfish readers below read the current configuration of a BMC through the standard Redfish schemas,
+// the schemas don't hold every field bmclib configures, only the fields listed by Fields are compared.
+
+// redfishFields are the fields of each resource read from the Redfish API.
+var redfishFields = map[string][]string{
+	"user":    {"name", "role", "enable"},
+	"ntp":     {"enable", "server1", "server2", "server3"},
+	"ldap":    {"enable", "server", "baseDn", "userAttribute", "groupAttribute"},
+	"network": {"hostname", "sshEnable", "sshPort", "ipmiEnable", "ipmiPort"},
+}
+
+// redfishRoles maps the Redfish predefined roles to the roles declared in the user resource.
+var redfishRoles = map[string]string{
+	"Administrator": "admin",
+	"Operator":      "operator",
+	"ReadOnly":      "user",
+}
+
+// Fields returns the fields of the resource read from the Redfish API.
+func (r *Redfish) Fields(resource string) []string {
+	return redfishFields[resource]
+}
+
+// property returns the property at the path in the Redfish object, nil if not present.
+func property(object map[string]interface{}, path ...string) interface{} {
+
+	var value interface{} = object
+	for _, key := range path {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = m[key]
+	}
+
+	return value
+}
+
+func stringProperty(object map[string]interface{}, path ...string) string {
+	s, _ := property(object, path...).(string)
+	return s
+}
+
+func boolProperty(object map[string]interface{}, path ...string) bool {
+	b, _ := property(object, path...).(bool)
+	return b
+}
+
+func intProperty(object map[string]interface{}, path ...string) int {
+	n, _ := property(object, path...).(float64)
+	return int(n)
+}
+
+func stringsProperty(object map[string]interface{}, path ...string) (l []string) {
+
+	values, _ := property(object, path...).([]interface{})
+	for _, v := range values {
+		if s, ok := v.(string); ok && s != "" {
+			l = append(l, s)
+		}
+	}
+
+	return l
+}
+
+// members returns the @odata.id of each of the members of the Redfish collection.
+func (r *Redfish) members(path string) (ids []string, err error) {
+
+	var collection map[string]interface{}
+	_, err = r.request("GET", path, nil, nil, &collection)
+	if err != nil {
+		return ids, err
+	}
+
+	members, _ := property(collection, "Members").([]interface{})
+	for _, member := range members {
+		m, _ := member.(map[string]interface{})
+		if id := stringProperty(m, "@odata.id"); id != "" {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+// networkProtocol returns the NetworkProtocol object of the BMC manager.
+func (r *Redfish) networkProtocol() (protocol map[string]interface{}, err error) {
+
+	managers, err := r.members("/redfish/v1/Managers")
+	if err != nil {
+		return protocol, err
+	}
+
+	if len(managers) == 0 {
+		return protocol, fmt.Errorf("no managers listed in the Redfish API")
+	}
+
+	_, err = r.request("GET", managers[0]+"/NetworkProtocol", nil, nil, &protocol)
+	return protocol, err
+}
+
+// CurrentUsers returns the user accounts on the BMC, account slots with no user name are ignored.
+func (r *Redfish) CurrentUsers() (users []*cfgresources.User, err error) {
+
+	accounts, err := r.members("/redfish/v1/AccountService/Accounts")
+	if err != nil {
+		return users, err
+	}
+
+	for _, path := range accounts {
+		var account map[string]interface{}
+		_, err = r.request("GET", path, nil, nil, &account)
+		if err != nil {
+			return users, err
+		}
+
+		name := stringProperty(account, "UserName")
+		if name == "" {
+			continue
+		}
+
+		roleID := stringProperty(account, "RoleId")
+		role, known := redfishRoles[roleID]
+		if !known {
+			role = strings.ToLower(roleID)
+		}
+
+		users = append(users, &cfgresources.User{
+			Name:   name,
+			Role:   role,
+			Enable: boolProperty(account, "Enabled"),
+		})
+	}
+
+	return users, nil
+}
+
+// CurrentNtp returns the NTP configuration of the BMC.
+func (r *Redfish) CurrentNtp() (*cfgresources.Ntp, error) {
+
+	protocol, err := r.networkProtocol()
+	if err != nil {
+		return nil, err
+	}
+
+	servers := append(stringsProperty(protocol, "NTP", "NTPServers"), "", "", "")
+
+	return &cfgresources.Ntp{
+		Enable:  boolProperty(protocol, "NTP", "ProtocolEnabled"),
+		Server1: servers[0],
+		Server2: servers[1],
+		Server3: servers[2],
+	}, nil
+}
+
+// CurrentNetwork returns the network services configuration of the BMC.
+func (r *Redfish) CurrentNetwork() (*cfgresources.Network, error) {
+
+	protocol, err := r.networkProtocol()
+	if err != nil {
+		return nil, err
+	}
+
+	return &cfgresources.Network{
+		Hostname:   stringProperty(protocol, "HostName"),
+		SSHEnable:  boolProperty(protocol, "SSH", "ProtocolEnabled"),
+		SSHPort:    intProperty(protocol, "SSH", "Port"),
+		IpmiEnable: boolProperty(protocol, "IPMI", "ProtocolEnabled"),
+		IpmiPort:   intProperty(protocol, "IPMI", "Port"),
+	}, nil
+}
+
+// CurrentLdap returns the LDAP configuration of the BMC account service.
+func (r *Redfish) CurrentLdap() (*cfgresources.Ldap, error) {
+
+	var service map[string]interface{}
+	_, err := r.request("GET", "/redfish/v1/AccountService", nil, nil, &service)
+	if err != nil {
+		return nil, err
+	}
+
+	ldap := &cfgresources.Ldap{
+		Enable:         boolProperty(service, "LDAP", "ServiceEnabled"),
+		UserAttribute:  stringProperty(service, "LDAP", "LDAPService", "SearchSettings", "UsernameAttribute"),
+		GroupAttribute: stringProperty(service, "LDAP", "LDAPService", "SearchSettings", "GroupsAttribute"),
+	}
+
+	if baseDns := stringsProperty(service, "LDAP", "LDAPService", "SearchSettings", "BaseDistinguishedNames"); len(baseDns) > 0 {
+		ldap.BaseDn = baseDns[0]
+	}
+
+	// service addresses are listed as a host, host:port or a ldap(s):// URI.
+	if addresses := stringsProperty(service, "LDAP", "ServiceAddresses"); len(addresses) > 0 {
+		ldap.Server = addresses[0]
+		if u, err := url.Parse(addresses[0]); err == nil && u.Host != "" {
+			ldap.Server = u.Hostname()
+		} else if host, _, err := net.SplitHostPort(addresses[0]); err == nil {
+			ldap.Server = host
+		}
+	}
+
+	return ldap, nil
+}
@@ -0,0 +1,65 @@
+package configure
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bmc-toolbox/bmclib/cfgresources"
+	"github.com/sirupsen/logrus"
+)
+
+// TestRedfishAudit tests the current configuration is read from the Redfish API,
+// only the fields Redfish reports are compared, and syslog fails to be audited.
+func TestRedfishAudit(t *testing.T) {
+
+	responses := map[string]string{
+		"/redfish/v1/Managers":                   `{"Members": [{"@odata.id": "/redfish/v1/Managers/1"}]}`,
+		"/redfish/v1/Managers/1/NetworkProtocol": `{"HostName": "bmc0", "SSH": {"ProtocolEnabled": true, "Port": 22}, "IPMI": {"ProtocolEnabled": false, "Port": 623}, "NTP": {"ProtocolEnabled": true, "NTPServers": ["ntp0.example.com", ""]}}`,
+		"/redfish/v1/AccountService":             `{"LDAP": {"ServiceEnabled": true, "ServiceAddresses": ["ldaps://ldap.example.com:636"], "LDAPService": {"SearchSettings": {"BaseDistinguishedNames": ["dc=example,dc=com"], "UsernameAttribute": "uid", "GroupsAttribute": "memberOf"}}}}`,
+		"/redfish/v1/AccountService/Accounts":    `{"Members": [{"@odata.id": "/redfish/v1/AccountService/Accounts/1"}, {"@odata.id": "/redfish/v1/AccountService/Accounts/2"}]}`,
+		"/redfish/v1/AccountService/Accounts/1":  `{"UserName": "Administrator", "RoleId": "Administrator", "Enabled": true}`,
+		"/redfish/v1/AccountService/Accounts/2":  `{"UserName": "", "RoleId": "ReadOnly", "Enabled": false}`,
+	}
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, exists := responses[r.URL.Path]
+		if !exists || r.Method != "GET" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write([]byte(response))
+	}))
+	defer server.Close()
+
+	ip := strings.TrimPrefix(server.URL, "https://")
+	r := NewRedfish(ip, map[string]string{"Administrator": "secret"}, nil, logrus.New())
+
+	config := &cfgresources.ResourcesConfig{
+		User: []*cfgresources.User{
+			{Name: "Administrator", Password: "secret", Role: "admin", Enable: true},
+		},
+		Syslog:  &cfgresources.Syslog{Server: "syslog.example.com", Port: 514, Enable: true},
+		Ntp:     &cfgresources.Ntp{Enable: true, Server1: "ntp0.example.com", Timezone: "UTC"},
+		Ldap:    &cfgresources.Ldap{Enable: true, Server: "ldap.example.com", Port: 636, BaseDn: "dc=example,dc=com", UserAttribute: "uid", GroupAttribute: "memberOf", Role: "admin"},
+		Network: &cfgresources.Network{Hostname: "bmc0", SSHEnable: true, SSHPort: 22, IpmiEnable: true, IpmiPort: 623, SolEnable: true},
+	}
+
+	result := audit(context.Background(), r, auditResources, config, 0, logrus.Fields{}, logrus.New())
+
+	if strings.Join(result.Compliant, ",") != "user,ntp,ldap" {
+		t.Fatalf("Expected user, ntp and ldap to be compliant, got %v", result.Compliant)
+	}
+
+	if len(result.Failed) != 1 || result.Failed[0] != "syslog" {
+		t.Fatalf("Expected syslog to fail to be audited, got %v", result.Failed)
+	}
+
+	expected := Drift{Resource: "network", Field: "ipmiEnable", Want: "true", Have: "false"}
+	if len(result.Drift) != 1 || result.Drift[0] != expected {
+		t.Fatalf("Expected just ipmiEnable to drift, got %v", result.Drift)
+	}
+}
@@ -0,0 +1,96 @@
+package configure
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	yaml "gopkg.in/yaml.v2"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/resource"
+)
+
+// TestRedfishApply tests only the properties that differ are PATCHed,
+// and properties pending on the settings object are not PATCHed again.
+func TestRedfishApply(t *testing.T) {
+
+	pending := map[string]interface{}{}
+	var patches []map[string]interface{}
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		if user != "Administrator" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/redfish/v1/Systems/1/Bios":
+			_, _ = w.Write([]byte(`{"Attributes": {"BootMode": "Bios", "ProcVirtualization": "Enabled", "SerialPort": 2}}`))
+		case r.Method == "GET" && r.URL.Path == "/redfish/v1/Systems/1/Bios/Settings":
+			w.Header().Set("ETag", `"1234"`)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"Attributes": pending})
+		case r.Method == "PATCH" && r.URL.Path == "/redfish/v1/Systems/1/Bios/Settings":
+			if r.Header.Get("If-Match") != `"1234"` {
+				t.Errorf("Expected PATCH with the settings object ETag")
+			}
+
+			var patch map[string]interface{}
+			_ = json.NewDecoder(r.Body).Decode(&patch)
+			patches = append(patches, patch)
+			pending = patch["Attributes"].(map[string]interface{})
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	targetsYaml := []byte(`
+- path: /redfish/v1/Systems/1/Bios
+  patchPath: /redfish/v1/Systems/1/Bios/Settings
+  properties:
+    Attributes:
+      BootMode: Uefi
+      ProcVirtualization: Enabled
+      SerialPort: 2
+`)
+
+	var targets []*resource.RedfishTarget
+	err := yaml.Unmarshal(targetsYaml, &targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ip := strings.TrimPrefix(server.URL, "https://")
+	r := NewRedfish(ip, map[string]string{"Administrator": "secret"}, targets, logrus.New())
+
+	err = r.Apply()
+	if err != nil {
+		t.Fatalf("Expected redfish targets to be applied, got error: %s", err)
+	}
+
+	if len(patches) != 1 {
+		t.Fatalf("Expected a single PATCH, got %d", len(patches))
+	}
+
+	attributes := patches[0]["Attributes"].(map[string]interface{})
+	if len(attributes) != 1 || attributes["BootMode"] != "Uefi" {
+		t.Fatalf("Expected only the BootMode attribute to be PATCHed, got %+v", attributes)
+	}
+
+	// the BootMode change is pending on the settings object until the next reset.
+	err = r.Apply()
+	if err != nil || len(patches) != 1 {
+		t.Fatalf("Expected pending settings not to be PATCHed again, patches: %d, error: %v", len(patches), err)
+	}
+
+	r = NewRedfish(ip, map[string]string{"Administrator": "wrong"}, targets, logrus.New())
+	err = r.Apply()
+	if err == nil || !strings.Contains(err.Error(), "/redfish/v1/Systems/1/Bios") {
+		t.Fatalf("Expected error on unauthorized request, got %v", err)
+	}
+}
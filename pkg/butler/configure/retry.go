@@ -0,0 +1,33 @@
+package configure
+
+import (
+	"context"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+	"github.com/bmc-toolbox/bmcbutler/pkg/retry"
+)
+
+// attempt runs the resource action with the resource timeout,
+// failures the resource retry policy declares retryable are retried,
+// the attempts made on resources that were retried are recorded in the result.
+func (r *Result) attempt(ctx context.Context, c *config.Params, resource string, action func() error) error {
+
+	var policy *config.RetryPolicy
+	if c != nil && c.Retry != nil {
+		policy = c.Retry.Resource
+	}
+
+	attempts, err := retry.Do(ctx, policy, func() error {
+		return run(ctx, resourceTimeout(c), action)
+	})
+
+	if attempts > 1 {
+		if r.Retried == nil {
+			r.Retried = make(map[string]int)
+		}
+
+		r.Retried[resource] = attempts
+	}
+
+	return err
+}
@@ -2,10 +2,13 @@ package configure
 
 import (
 	"context"
+	"fmt"
 	"strings"
 
 	"github.com/bmc-toolbox/bmcbutler/pkg/asset"
 	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+	"github.com/bmc-toolbox/bmcbutler/pkg/signer"
+	"github.com/bmc-toolbox/bmcbutler/pkg/state"
 	"github.com/bmc-toolbox/bmclib/cfgresources"
 	"github.com/bmc-toolbox/bmclib/devices"
 	"github.com/sirupsen/logrus"
@@ -18,13 +21,25 @@ type Bmc struct {
 	resources    []string
 	configure    devices.Configure
 	config       *cfgresources.ResourcesConfig
+	redfish      *Redfish
 	butlerConfig *config.Params
+	state        *state.Store
+	signer       signer.Signer
 	logger       *logrus.Logger
 	ip           string
 	serial       string
 	vendor       string
 	model        string
-	stopChan     <-chan struct{}
+}
+
+// Result is returned by the Apply methods, it lists the outcome of the resources applied.
+type Result struct {
+	Applied  []string
+	Failed   []string
+	Skipped  []string //resources unchanged since they were last applied
+	Errors   []string
+	TimedOut bool           //set when a resource or the asset timed out
+	Retried  map[string]int //the attempts made on resources that were retried
 }
 
 // NewBmcConfigurator returns a new configure struct to apply configuration.
@@ -32,8 +47,10 @@ func NewBmcConfigurator(bmc devices.Bmc,
 	asset *asset.Asset,
 	resources []string,
 	config *cfgresources.ResourcesConfig,
+	redfish *Redfish,
 	butlerConfig *config.Params,
-	stopChan <-chan struct{},
+	state *state.Store,
+	signer signer.Signer,
 	logger *logrus.Logger) *Bmc {
 
 	return &Bmc{
@@ -47,9 +64,11 @@ func NewBmcConfigurator(bmc devices.Bmc,
 		// if --resources was passed, only these resources will be applied
 		resources:    resources,
 		config:       config,
+		redfish:      redfish,
 		butlerConfig: butlerConfig,
+		state:        state,
+		signer:       signer,
 		logger:       logger,
-		stopChan:     stopChan,
 		ip:           asset.IPAddress,
 		serial:       asset.Serial,
 		vendor:       asset.Vendor,
@@ -57,13 +76,11 @@ func NewBmcConfigurator(bmc devices.Bmc,
 	}
 }
 
-// Apply applies configuration.
+// Apply applies configuration,
+// resources are no longer applied once the context is done, i.e the asset timed out or an interrupt was received.
 // nolint: gocyclo
-func (b *Bmc) Apply() {
-
-	var interrupt bool
+func (b *Bmc) Apply(ctx context.Context) (result Result) {
 
-	go func() { <-b.stopChan; interrupt = true }()
 	// slice of configuration resources to be applied.
 	var resources []string
 
@@ -72,11 +89,16 @@ func (b *Bmc) Apply() {
 		resources = b.resources
 	} else {
 		resources = b.configure.Resources()
+
+		// the redfish resource is applied by bmcbutler and not listed by bmclib.
+		if b.redfish != nil {
+			resources = append(resources, "redfish")
+		}
 	}
 
 	b.ip = b.asset.IPAddress
 
-	var failed, success []string
+	var failed, success, skipped []string
 
 	// reset causes are appended here
 	var resetCause []string
@@ -94,66 +116,59 @@ func (b *Bmc) Apply() {
 		var err error
 		var reset bool
 
-		// check if an interrupt was received.
-		if interrupt == true {
+		// check if the asset timed out or an interrupt was received.
+		if ctx.Err() != nil {
+			result.stopped(ctx.Err())
 			b.logger.WithFields(logrus.Fields{
 				"Vendor":    b.vendor,
 				"Model":     b.model,
 				"Serial":    b.serial,
 				"IPAddress": b.ip,
-			}).Debug("Received interrupt.")
+				"Error":     ctx.Err(),
+			}).Debug("Asset timed out or interrupt received, remaining resources not applied.")
 			break
 		}
 
-		switch resource {
-		case "user":
-			if b.config.User != nil {
-				err = b.configure.User(b.config.User)
-			}
-		case "syslog":
-			if b.config.Syslog != nil {
-				err = b.configure.Syslog(b.config.Syslog)
-			}
-		case "ntp":
-			if b.config.Ntp != nil {
-				err = b.configure.Ntp(b.config.Ntp)
-			}
-		case "ldap":
-			if b.config.Ldap != nil {
-				err = b.configure.Ldap(b.config.Ldap)
-			}
-		case "ldap_group":
-			if b.config.LdapGroup != nil && b.config.Ldap != nil {
-				err = b.configure.LdapGroup(b.config.LdapGroup, b.config.Ldap)
-			}
-		case "license":
-			if b.config.License != nil {
-				err = b.configure.SetLicense(b.config.License)
-			}
-		case "network":
-			if b.config.Network != nil {
-				reset, err = b.configure.Network(b.config.Network)
-			}
-		case "bios":
-			if b.config.Bios != nil {
-				err = b.configure.Bios(b.config.Bios)
-			}
-		case "https_cert":
-			if b.config.HTTPSCert != nil {
-				reset, err = b.certificateSetup()
-			}
-		case "power":
-			if b.config.Power != nil {
-				err = b.configure.Power(b.config.Power)
-			}
-		default:
+		// skip resources unchanged since they were last applied.
+		skip, hash := unchanged(b.state, b.butlerConfig, b.serial, resource, b.config)
+		if skip {
+			skipped = append(skipped, resource)
+			b.logger.WithFields(logrus.Fields{
+				"resource":  resource,
+				"Vendor":    b.vendor,
+				"Model":     b.model,
+				"Serial":    b.serial,
+				"IPAddress": b.ip,
+			}).Debug("Resource unchanged since last applied, skipped.")
+			continue
+		}
+
+		err = result.attempt(ctx, b.butlerConfig, resource, func() (err error) {
+			reset, err = b.applyResource(resource)
+			return err
+		})
+
+		// the resource timed out or an interrupt was received,
+		// the resource is abandoned and no further resources are applied.
+		if Interrupted(err) {
+			failed = append(failed, resource)
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %s", resource, err))
+			result.TimedOut = result.TimedOut || TimedOut(err)
+			recordState(b.state, b.serial, resource, hash, err, b.logger)
 			b.logger.WithFields(logrus.Fields{
-				"resource": resource,
-			}).Warn("Unknown resource.")
+				"resource":  resource,
+				"Vendor":    b.vendor,
+				"Model":     b.model,
+				"Serial":    b.serial,
+				"IPAddress": b.ip,
+				"Error":     err,
+			}).Warn("Resource configuration abandoned, remaining resources not applied.")
+			break
 		}
 
 		if err != nil {
 			failed = append(failed, resource)
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %s", resource, err))
 			b.logger.WithFields(logrus.Fields{
 				"resource":  resource,
 				"Vendor":    b.vendor,
@@ -166,6 +181,8 @@ func (b *Bmc) Apply() {
 			success = append(success, resource)
 		}
 
+		recordState(b.state, b.serial, resource, hash, err, b.logger)
+
 		if reset {
 			resetCause = append(resetCause, resource)
 		}
@@ -180,8 +197,8 @@ func (b *Bmc) Apply() {
 
 	}
 
-	//// Reset BMC if needed.
-	if len(resetCause) > 0 {
+	//// Reset BMC if needed, unless the asset timed out or an interrupt was received.
+	if len(resetCause) > 0 && ctx.Err() == nil {
 
 		b.logger.WithFields(logrus.Fields{
 			"Vendor":    b.vendor,
@@ -195,8 +212,14 @@ func (b *Bmc) Apply() {
 		b.bmc.Close(context.TODO())
 
 		//// reset BMC using SSH.
-		_, err := b.bmc.PowerCycleBmc()
+		err := run(ctx, resourceTimeout(b.butlerConfig), func() error {
+			_, err := b.bmc.PowerCycleBmc()
+			return err
+		})
+
+		result.TimedOut = result.TimedOut || TimedOut(err)
 		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("bmc reset: %s", err))
 			b.logger.WithFields(logrus.Fields{
 				"Vendor":    b.vendor,
 				"Model":     b.model,
@@ -208,6 +231,10 @@ func (b *Bmc) Apply() {
 		}
 	}
 
+	result.Applied = success
+	result.Failed = failed
+	result.Skipped = skipped
+
 	if len(failed) > 0 {
 		b.logger.WithFields(logrus.Fields{
 			"Vendor":    b.vendor,
@@ -218,7 +245,7 @@ func (b *Bmc) Apply() {
 			"applied":   strings.Join(success, ", "),
 			"failed":    strings.Join(failed, ", "),
 		}).Warn("One or more resources failed to apply.")
-		return
+		return result
 	}
 
 	b.logger.WithFields(logrus.Fields{
@@ -228,6 +255,67 @@ func (b *Bmc) Apply() {
 		"IPAddress": b.ip,
 		"success":   true,
 		"applied":   strings.Join(success, ", "),
+		"skipped":   strings.Join(skipped, ", "),
 	}).Info("BMC configuration actions successful.")
 
+	return result
+}
+
+// applyResource applies the declared configuration of the resource,
+// returns true if the BMC is to be reset for the configuration to take effect.
+// nolint: gocyclo
+func (b *Bmc) applyResource(resource string) (reset bool, err error) {
+
+	switch resource {
+	case "user":
+		if b.config.User != nil {
+			err = b.configure.User(b.config.User)
+		}
+	case "syslog":
+		if b.config.Syslog != nil {
+			err = b.configure.Syslog(b.config.Syslog)
+		}
+	case "ntp":
+		if b.config.Ntp != nil {
+			err = b.configure.Ntp(b.config.Ntp)
+		}
+	case "ldap":
+		if b.config.Ldap != nil {
+			err = b.configure.Ldap(b.config.Ldap)
+		}
+	case "ldap_group":
+		if b.config.LdapGroup != nil && b.config.Ldap != nil {
+			err = b.configure.LdapGroup(b.config.LdapGroup, b.config.Ldap)
+		}
+	case "license":
+		if b.config.License != nil {
+			err = b.configure.SetLicense(b.config.License)
+		}
+	case "network":
+		if b.config.Network != nil {
+			reset, err = b.configure.Network(b.config.Network)
+		}
+	case "bios":
+		if b.config.Bios != nil {
+			err = b.configure.Bios(b.config.Bios)
+		}
+	case "https_cert":
+		if b.config.HTTPSCert != nil {
+			reset, err = b.certificateSetup()
+		}
+	case "power":
+		if b.config.Power != nil {
+			err = b.configure.Power(b.config.Power)
+		}
+	case "redfish":
+		if b.redfish != nil {
+			err = b.redfish.Apply()
+		}
+	default:
+		b.logger.WithFields(logrus.Fields{
+			"resource": resource,
+		}).Warn("Unknown resource.")
+	}
+
+	return reset, err
 }
@@ -1,6 +1,7 @@
 package configure
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
@@ -27,7 +28,6 @@ type CmcSetup struct {
 	serial       string
 	vendor       string
 	model        string
-	stopChan     <-chan struct{}
 }
 
 // NewCmcSetup returns a new  struct to apply configuration.
@@ -37,7 +37,6 @@ func NewCmcSetup(
 	resources []string,
 	config *cfgresources.SetupChassis,
 	butlerConfig *config.Params,
-	stopChan <-chan struct{},
 	logger *logrus.Logger) *CmcSetup {
 
 	return &CmcSetup{
@@ -53,21 +52,18 @@ func NewCmcSetup(
 		resources: resources,
 		config:    config,
 		log:       logger,
-		stopChan:  stopChan,
 	}
 }
 
-// Apply applies one time setup configuration.
-func (b *CmcSetup) Apply() { //nolint: gocyclo
+// Apply applies one time setup configuration,
+// resources are no longer applied once the context is done, i.e the asset timed out or an interrupt was received.
+func (b *CmcSetup) Apply(ctx context.Context) (result Result) { //nolint: gocyclo
 
 	//defer b.metricsEmitter.MeasureRuntime(
 	//	[]string{"butler", "setupChassis_runtime"},
 	//	time.Now(),
 	//)
 
-	var interrupt bool
-	go func() { <-b.stopChan; interrupt = true }()
-
 	// slice of configuration resources to be applied.
 	var resources []string
 
@@ -101,19 +97,23 @@ func (b *CmcSetup) Apply() { //nolint: gocyclo
 
 		var err error
 
-		// check if an interrupt was received.
-		if interrupt == true {
+		// check if the asset timed out or an interrupt was received.
+		if ctx.Err() != nil {
+			setupActionSuccess = false
+			result.stopped(ctx.Err())
 			b.log.WithFields(logrus.Fields{
 				"Vendor":    b.vendor,
 				"Model":     b.model,
 				"Serial":    b.serial,
 				"IPAddress": b.ip,
-			}).Debug("Received interrupt.")
+				"Error":     ctx.Err(),
+			}).Debug("Asset timed out or interrupt received, remaining resources not applied.")
 			break
 		}
 
-		err = b.ensurePoweredUp()
+		err = run(ctx, resourceTimeout(b.butlerConfig), b.ensurePoweredUp)
 		if err != nil {
+			result.TimedOut = TimedOut(err)
 			b.log.WithFields(logrus.Fields{
 				"resource":  resource,
 				"Vendor":    b.vendor,
@@ -122,7 +122,10 @@ func (b *CmcSetup) Apply() { //nolint: gocyclo
 				"IPAddress": b.ip,
 				"Error":     err,
 			}).Warn("Chassis power status")
-			return
+			result.Applied = success
+			result.Failed = append(failed, resource)
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %s", resource, err))
+			return result
 		}
 
 		b.log.WithFields(logrus.Fields{
@@ -133,40 +136,32 @@ func (b *CmcSetup) Apply() { //nolint: gocyclo
 			"IPAddress": b.ip,
 		}).Debug("Chassis is powered on, continuing setup.")
 
-		switch resource {
-		case "setipmioverlan":
-			if b.config.IpmiOverLan != nil {
-				err = b.setIpmiOverLan()
-			}
-		case "flexaddress":
-			if b.config.FlexAddress != nil {
-				err = b.setFlexAddressState()
-			}
-		case "dynamicpower":
-			if b.config.DynamicPower != nil {
-				err = b.setDynamicPower()
-			}
-		case "bladespower":
-			if b.config.BladesPower != nil {
-				err = b.setBladesPower()
-			}
-		case "add_blade_bmc_admins":
-			if len(b.config.AddBladeBmcAdmins) > 0 {
-				err = b.addBladeBmcAdmins()
-			}
-		case "remove_blade_bmc_users":
-			if len(b.config.RemoveBladeBmcUsers) > 0 {
-				err = b.removeBladeBmcUsers()
-			}
-		default:
+		err = result.attempt(ctx, b.butlerConfig, resource, func() error {
+			return b.applyResource(resource)
+		})
+
+		// the resource timed out or an interrupt was received,
+		// the resource is abandoned and no further resources are applied.
+		if Interrupted(err) {
+			setupActionSuccess = false
+			failed = append(failed, resource)
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %s", resource, err))
+			result.TimedOut = TimedOut(err)
 			b.log.WithFields(logrus.Fields{
-				"resource": resource,
-			}).Warn("Unknown setup resource.")
+				"resource":  resource,
+				"Vendor":    b.vendor,
+				"Model":     b.model,
+				"Serial":    b.serial,
+				"IPAddress": b.ip,
+				"Error":     err,
+			}).Warn("Setup resource abandoned, remaining resources not applied.")
+			break
 		}
 
 		if err != nil {
 			setupActionSuccess = false
 			failed = append(failed, resource)
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %s", resource, err))
 			b.log.WithFields(logrus.Fields{
 				"resource":  resource,
 				"Vendor":    b.vendor,
@@ -190,6 +185,9 @@ func (b *CmcSetup) Apply() { //nolint: gocyclo
 
 	}
 
+	result.Applied = success
+	result.Failed = failed
+
 	//if chassis setup is done successfully invoke post action.
 	if setupActionSuccess {
 		b.Post()
@@ -204,19 +202,62 @@ func (b *CmcSetup) Apply() { //nolint: gocyclo
 		"unsuccessful": strings.Join(failed, ", "),
 	}).Info("Chassis setup actions done.")
 
+	return result
+}
+
+// applyResource applies the declared setup configuration of the resource.
+func (b *CmcSetup) applyResource(resource string) (err error) {
+
+	switch resource {
+	case "setipmioverlan":
+		if b.config.IpmiOverLan != nil {
+			err = b.setIpmiOverLan()
+		}
+	case "flexaddress":
+		if b.config.FlexAddress != nil {
+			err = b.setFlexAddressState()
+		}
+	case "dynamicpower":
+		if b.config.DynamicPower != nil {
+			err = b.setDynamicPower()
+		}
+	case "bladespower":
+		if b.config.BladesPower != nil {
+			err = b.setBladesPower()
+		}
+	case "add_blade_bmc_admins":
+		if len(b.config.AddBladeBmcAdmins) > 0 {
+			err = b.addBladeBmcAdmins()
+		}
+	case "remove_blade_bmc_users":
+		if len(b.config.RemoveBladeBmcUsers) > 0 {
+			err = b.removeBladeBmcUsers()
+		}
+	default:
+		b.log.WithFields(logrus.Fields{
+			"resource": resource,
+		}).Warn("Unknown setup resource.")
+	}
+
+	return err
 }
 
 // Post method is when a chassis was setup successfully.
 func (b *CmcSetup) Post() {
 
-	enc := inventory.Enc{
-		Config: b.butlerConfig,
-		Log:    b.log,
+	source, err := inventory.New(b.butlerConfig, b.log)
+	if err != nil {
+		b.log.WithFields(logrus.Fields{
+			"Serial": b.serial,
+			"Error":  err,
+		}).Warn("Unable to set chassis installed in inventory.")
+		return
 	}
 
-	enc.SetChassisInstalled(b.asset.Serial)
-
-	return
+	//only some inventory sources track the chassis install state.
+	if installer, ok := source.(inventory.ChassisInstaller); ok {
+		installer.SetChassisInstalled(b.asset.Serial)
+	}
 }
 
 // ensurePoweredUp method checks if a chassis is powered off
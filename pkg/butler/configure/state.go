@@ -0,0 +1,123 @@
+package configure
+
+import (
+	"time"
+
+	"github.com/bmc-toolbox/bmclib/cfgresources"
+	"github.com/sirupsen/logrus"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+	"github.com/bmc-toolbox/bmcbutler/pkg/state"
+)
+
+// resourceConfig returns the rendered configuration for the resource,
+// nil if no configuration was declared for the resource.
+// nolint: gocyclo
+func resourceConfig(config *cfgresources.ResourcesConfig, resource string) []interface{} {
+
+	switch resource {
+	case "user":
+		if config.User != nil {
+			return []interface{}{config.User}
+		}
+	case "syslog":
+		if config.Syslog != nil {
+			return []interface{}{config.Syslog}
+		}
+	case "ntp":
+		if config.Ntp != nil {
+			return []interface{}{config.Ntp}
+		}
+	case "ldap":
+		if config.Ldap != nil {
+			return []interface{}{config.Ldap}
+		}
+	case "ldap_group":
+		if config.LdapGroup != nil && config.Ldap != nil {
+			return []interface{}{config.LdapGroup, config.Ldap}
+		}
+	case "license":
+		if config.License != nil {
+			return []interface{}{config.License}
+		}
+	case "network":
+		if config.Network != nil {
+			return []interface{}{config.Network}
+		}
+	case "bios":
+		if config.Bios != nil {
+			return []interface{}{config.Bios}
+		}
+	case "power":
+		if config.Power != nil {
+			return []interface{}{config.Power}
+		}
+	}
+
+	// https_cert is never skipped, since whether the cert needs to be renewed
+	// depends on the current cert on the BMC and not the declared config.
+	return nil
+}
+
+// unchanged returns true if the rendered resource configuration is unchanged
+// since it was last applied successfully on the asset, along with the hash of the configuration.
+// The returned hash is empty if the resource apply is not to be recorded in the state store.
+func unchanged(store *state.Store, butlerConfig *config.Params, serial string, resource string, config *cfgresources.ResourcesConfig) (bool, string) {
+
+	if store == nil || serial == "" {
+		return false, ""
+	}
+
+	rc := resourceConfig(config, resource)
+	if rc == nil {
+		return false, ""
+	}
+
+	hash, err := state.Hash(serial, rc...)
+	if err != nil {
+		return false, ""
+	}
+
+	if butlerConfig.Force {
+		return false, hash
+	}
+
+	var maxAge time.Duration
+	if butlerConfig.State != nil {
+		maxAge = butlerConfig.State.MaxAge
+	}
+
+	// serve runs correct drift on the BMC, an unchanged resource is skipped only until maxAge elapses.
+	if butlerConfig.Reconcile && maxAge == 0 {
+		return false, hash
+	}
+
+	skip, err := store.Unchanged(serial, resource, hash, maxAge)
+	if err != nil {
+		return false, hash
+	}
+
+	return skip, hash
+}
+
+// recordState records the outcome of the resource apply on the asset in the state store.
+func recordState(store *state.Store, serial string, resource string, hash string, applyErr error, logger *logrus.Logger) {
+
+	if store == nil || hash == "" {
+		return
+	}
+
+	entry := state.Entry{Hash: hash, AppliedAt: time.Now(), Success: applyErr == nil}
+	if applyErr != nil {
+		entry.Error = applyErr.Error()
+	}
+
+	err := store.Put(serial, resource, entry)
+	if err != nil {
+		logger.WithFields(logrus.Fields{
+			"resource": resource,
+			"Serial":   serial,
+			"Error":    err,
+		}).Warn("Unable to record resource apply in state store.")
+	}
+}
@@ -0,0 +1,97 @@
+package configure
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bmc-toolbox/bmclib/cfgresources"
+	"github.com/sirupsen/logrus"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+	"github.com/bmc-toolbox/bmcbutler/pkg/state"
+)
+
+// TestUnchanged tests resources are skipped only when applied successfully with the same config,
+// and never when --force is passed, the resource is not declared or is https_cert,
+// or in serve runs with no maxAge declared.
+func TestUnchanged(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "bmcbutler-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := state.Open(filepath.Join(dir, "state.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	butlerConfig := &config.Params{}
+	resources := &cfgresources.ResourcesConfig{
+		Syslog:    &cfgresources.Syslog{Server: "syslog.example.com", Port: 514, Enable: true},
+		Ntp:       &cfgresources.Ntp{Enable: true, Server1: "ntp0.example.com"},
+		HTTPSCert: &cfgresources.HTTPSCert{},
+	}
+
+	logger := logrus.New()
+	for _, resource := range []string{"syslog", "ntp"} {
+		skip, hash := unchanged(store, butlerConfig, "FOOBAR", resource, resources)
+		if skip || hash == "" {
+			t.Fatalf("Expected %s never applied to be applied and recorded, got skip: %t, hash: %s", resource, skip, hash)
+		}
+
+		var applyErr error
+		if resource == "ntp" {
+			applyErr = errors.New("timeout")
+		}
+
+		recordState(store, "FOOBAR", resource, hash, applyErr, logger)
+	}
+
+	cases := []struct {
+		resource string
+		force    bool
+		expected bool
+	}{
+		{"syslog", false, true},
+		{"syslog", true, false},
+		{"ntp", false, false},
+		{"ldap", false, false},
+		{"https_cert", false, false},
+	}
+
+	for _, c := range cases {
+		butlerConfig.Force = c.force
+		skip, _ := unchanged(store, butlerConfig, "FOOBAR", c.resource, resources)
+		if skip != c.expected {
+			t.Errorf("Expected %s (force: %t) skip to be %t, got %t", c.resource, c.force, c.expected, skip)
+		}
+	}
+
+	// serve runs re-apply unchanged resources to correct drift, unless within maxAge.
+	butlerConfig.Reconcile = true
+	skip, _ := unchanged(store, butlerConfig, "FOOBAR", "syslog", resources)
+	if skip {
+		t.Error("Expected unchanged syslog to be re-applied in serve runs with no maxAge declared.")
+	}
+
+	butlerConfig.State = &config.State{MaxAge: time.Hour}
+	skip, _ = unchanged(store, butlerConfig, "FOOBAR", "syslog", resources)
+	if !skip {
+		t.Error("Expected unchanged syslog to be skipped in serve runs within maxAge.")
+	}
+
+	// a changed config is applied.
+	butlerConfig.Force = false
+	resources.Syslog.Port = 1514
+	skip, _ = unchanged(store, butlerConfig, "FOOBAR", "syslog", resources)
+	if skip {
+		t.Error("Expected syslog with changed config to be applied.")
+	}
+}
@@ -0,0 +1,79 @@
+package configure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+)
+
+// resourceTimeout returns the time a resource is given to be applied or audited, zero if no timeout was declared.
+func resourceTimeout(c *config.Params) time.Duration {
+
+	if c == nil || c.Timeouts == nil {
+		return 0
+	}
+
+	return c.Timeouts.Resource
+}
+
+// run runs the resource action until it returns, the resource timeout elapses or the context is done,
+// bmclib calls can't be cancelled, so an action that does not return in time is abandoned,
+// it returns once the connection to the asset is closed.
+//
+// The action must not write to variables read after run returns an error,
+// since an abandoned action may still be running.
+func run(ctx context.Context, timeout time.Duration, action func() error) error {
+
+	resourceCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		resourceCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- action() }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-resourceCtx.Done():
+		return contextError(ctx, resourceCtx, timeout)
+	}
+}
+
+// Run runs an action on the asset other than a resource apply, e.g a command executed,
+// until it returns, the asset timeout elapses or the context is done, the action is abandoned as resources are.
+func Run(ctx context.Context, action func() error) error {
+	return run(ctx, 0, action)
+}
+
+// contextError returns the reason the context is done,
+// the resource timeout is reported as such, rather than the asset timeout or the interrupt.
+func contextError(ctx context.Context, resourceCtx context.Context, timeout time.Duration) error {
+
+	if ctx.Err() == nil && resourceCtx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %s: %w", timeout, context.DeadlineExceeded)
+	}
+
+	return ctx.Err()
+}
+
+// stopped records the reason no further resources were applied.
+func (r *Result) stopped(err error) {
+	r.Errors = append(r.Errors, fmt.Sprintf("remaining resources not applied: %s", err))
+	r.TimedOut = r.TimedOut || TimedOut(err)
+}
+
+// Interrupted returns true if the error was returned since a timeout elapsed or an interrupt was received.
+func Interrupted(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled)
+}
+
+// TimedOut returns true if the error was returned since a timeout elapsed.
+func TimedOut(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded)
+}
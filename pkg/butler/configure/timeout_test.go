@@ -0,0 +1,53 @@
+package configure
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestRun tests resource actions and commands are abandoned once the timeout elapses or the context is done,
+// and the reason is reported.
+func TestRun(t *testing.T) {
+
+	errApply := errors.New("apply failed")
+	err := run(context.Background(), time.Second, func() error { return errApply })
+	if err != errApply {
+		t.Errorf("Expected the action error, got %v", err)
+	}
+
+	hung := make(chan struct{})
+	defer close(hung)
+
+	err = run(context.Background(), 10*time.Millisecond, func() error { <-hung; return nil })
+	if !TimedOut(err) || err.Error() != "timed out after 10ms: context deadline exceeded" {
+		t.Errorf("Expected the resource to time out, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() { time.Sleep(10 * time.Millisecond); cancel() }()
+
+	err = run(ctx, time.Minute, func() error { <-hung; return nil })
+	if !Interrupted(err) || TimedOut(err) {
+		t.Errorf("Expected the resource to be interrupted, got %v", err)
+	}
+
+	// the asset timeout is reported as is.
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err = run(ctx, time.Minute, func() error { <-hung; return nil })
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expected the asset timeout, got %v", err)
+	}
+
+	// commands executed are abandoned once the asset timeout elapses.
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err = Run(ctx, func() error { <-hung; return nil })
+	if !TimedOut(err) {
+		t.Errorf("Expected the command to time out, got %v", err)
+	}
+}
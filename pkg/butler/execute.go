@@ -11,75 +11,86 @@ import (
 	"github.com/bmc-toolbox/bmclogin"
 
 	"github.com/bmc-toolbox/bmcbutler/pkg/asset"
+	"github.com/bmc-toolbox/bmcbutler/pkg/butler/command"
+	"github.com/bmc-toolbox/bmcbutler/pkg/butler/configure"
 )
 
-// applyConfig setups up the bmc connection
-// gets any config templated data rendered
-// applies the configuration using bmclib
-func (b *Butler) executeCommand(command string, asset *asset.Asset) (err error) {
+// executeCommand sets up the bmc connection,
+// looks up the command in the command registry
+// and executes it on the bmc/chassis.
+func (b *Butler) executeCommand(ctx context.Context, name string, args command.Args, asset *asset.Asset) (err error) {
 
 	component := "executeCommand"
 	log := b.Log
 
+	cmd, err := command.Get(name)
+	if err != nil {
+		return err
+	}
+
+	err = cmd.ValidateArgs(args)
+	if err != nil {
+		return err
+	}
+
 	if b.Config.DryRun {
 		log.WithFields(logrus.Fields{
 			"component": component,
 			"Asset":     fmt.Sprintf("%+v", asset),
+			"Command":   name,
 		}).Info("Dry run, won't execute cmd on asset.")
 		return nil
 	}
 
 	bmcConn := bmclogin.Params{
 		IpAddresses:     asset.IPAddresses,
-		Credentials:     b.Config.Credentials,
-		CheckCredential: false,
+		Credentials:     b.Creds.Credentials(asset),
+		CheckCredential: b.Creds.CheckCredential(),
 		Retries:         1,
 	}
 
 	//connect to the bmc/chassis bmc
-	client, loginInfo, err := bmcConn.Login()
+	client, loginInfo, err := b.login(ctx, &bmcConn)
 	if err != nil {
 		return err
 	}
 
 	asset.IPAddress = loginInfo.ActiveIpAddress
+	b.Creds.Worked(asset, loginInfo.WorkingCredentials)
+
+	// commands are abandoned once the asset timeout elapses or an interrupt is received,
+	// the abandoned command returns once the connection is closed.
+	notSuccessful := fmt.Errorf("command %s was not successful", name)
 
 	switch client.(type) {
 	case devices.Bmc:
 		bmc := client.(devices.Bmc)
-		success, err := b.executeCommandBmc(bmc, command)
-		if err != nil || success != true {
-			log.WithFields(logrus.Fields{
-				"component":          component,
-				"Serial":             asset.Serial,
-				"AssetType":          asset.Type,
-				"Vendor":             asset.Vendor, //at this point the vendor may or may not be known.
-				"Location":           asset.Location,
-				"Command":            command,
-				"Command successful": success,
-				"Error":              err,
-			}).Warn("Command execute returned error.")
-		} else {
-			log.WithFields(logrus.Fields{
-				"component":          component,
-				"Serial":             asset.Serial,
-				"AssetType":          asset.Type,
-				"Vendor":             asset.Vendor,
-				"Location":           asset.Location,
-				"Command":            command,
-				"Command successful": success,
-			}).Debug("Command executed.")
-
-		}
-		bmc.Close(context.TODO())
+		defer bmc.Close(context.TODO())
+
+		asset.Type = "server"
+		asset.Vendor = bmc.Vendor()
+
+		err = configure.Run(ctx, func() error {
+			success, err := cmd.ExecuteBmc(bmc, args)
+			if err == nil && !success {
+				return notSuccessful
+			}
+			return err
+		})
 	case devices.Cmc:
 		chassis := client.(devices.Cmc)
-		//b.executeCommandChassis(chassis, command)
-		log.WithFields(logrus.Fields{
-			"component": component,
-			"Asset":     fmt.Sprintf("%+v", asset),
-		}).Info("Command executed.")
-		chassis.Close()
+		defer chassis.Close()
+
+		asset.Type = "chassis"
+		asset.Vendor = chassis.Vendor()
+
+		err = configure.Run(ctx, func() error {
+			success, err := cmd.ExecuteCmc(chassis, args)
+			if err == nil && !success {
+				return notSuccessful
+			}
+			return err
+		})
 	default:
 		log.WithFields(logrus.Fields{
 			"component": component,
@@ -88,32 +99,30 @@ func (b *Butler) executeCommand(command string, asset *asset.Asset) (err error)
 		return errors.New("Unknown asset type")
 	}
 
-	return err
-}
-
-func (b *Butler) executeCommandBmc(bmc devices.Bmc, command string) (success bool, err error) {
-
-	switch command {
-	case "bmc-reset":
-		success, err := bmc.PowerCycleBmc()
-		return success, err
-	case "powercycle":
-		success, err := bmc.PowerCycle()
-		return success, err
-	default:
-		return success, fmt.Errorf("Unknown command: %s", command)
+	success := err == nil
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"component":          component,
+			"Serial":             asset.Serial,
+			"AssetType":          asset.Type,
+			"Vendor":             asset.Vendor,
+			"Location":           asset.Location,
+			"Command":            name,
+			"Command successful": success,
+			"Error":              err,
+		}).Warn("Command execute returned error.")
+		return err
 	}
 
+	log.WithFields(logrus.Fields{
+		"component":          component,
+		"Serial":             asset.Serial,
+		"AssetType":          asset.Type,
+		"Vendor":             asset.Vendor,
+		"Location":           asset.Location,
+		"Command":            name,
+		"Command successful": success,
+	}).Info("Command executed.")
+
+	return nil
 }
-
-//func (b *Butler) executeCommandChassis(chassis devices.Cmc, command []byte) (err error) {
-//
-//	switch string(command) {
-//	case "Chassis reset":
-//		chassis.PowerCycleBmc()
-//	default:
-//		return errors.New(fmt.Sprintf("Unknown command: %s", command))
-//	}
-//
-//	return err
-//}
@@ -0,0 +1,137 @@
+package butler
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/asset"
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+)
+
+// limiter tracks the assets being actioned per location, vendor and parent chassis,
+// and limits the rate at which BMC connections are made.
+type limiter struct {
+	config *config.Limits
+	rate   *rate.Limiter
+	active map[string]int
+	mutex  sync.Mutex
+}
+
+// newLimiter returns a limiter for the limits declared, limits may be nil.
+func newLimiter(c *config.Limits) *limiter {
+
+	if c == nil {
+		c = &config.Limits{}
+	}
+
+	l := &limiter{config: c, active: make(map[string]int)}
+	if c.ConnectionsPerSecond > 0 {
+		burst := c.Burst
+		if burst < 1 {
+			burst = 1
+		}
+
+		l.rate = rate.NewLimiter(rate.Limit(c.ConnectionsPerSecond), burst)
+	}
+
+	return l
+}
+
+// keys returns the limit keys of the asset, along with the limit for each key,
+// keys with no limit are left out.
+func (l *limiter) keys(a *asset.Asset) map[string]int {
+
+	keys := make(map[string]int)
+
+	if a.Location != "" {
+		location := strings.ToLower(a.Location)
+		limit, exists := l.config.Locations[location]
+		if !exists {
+			limit = l.config.PerLocation
+		}
+
+		if limit > 0 {
+			keys["location/"+location] = limit
+		}
+	}
+
+	if a.Vendor != "" {
+		vendor := strings.ToLower(a.Vendor)
+		limit, exists := l.config.Vendors[vendor]
+		if !exists {
+			limit = l.config.PerVendor
+		}
+
+		if limit > 0 {
+			keys["vendor/"+vendor] = limit
+		}
+	}
+
+	if a.Chassis != "" && l.config.PerChassis > 0 {
+		keys["chassis/"+a.Chassis] = l.config.PerChassis
+	}
+
+	return keys
+}
+
+// acquire returns true and counts the asset as being actioned if it is within the limits,
+// the keys acquired are returned, to be released once the asset has been actioned.
+func (l *limiter) acquire(a *asset.Asset) (map[string]int, bool) {
+
+	keys := l.keys(a)
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for key, limit := range keys {
+		if l.active[key] >= limit {
+			return nil, false
+		}
+	}
+
+	for key := range keys {
+		l.active[key]++
+	}
+
+	return keys, true
+}
+
+// release releases the keys acquired for an asset.
+func (l *limiter) release(keys map[string]int) {
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for key := range keys {
+		l.active[key]--
+		if l.active[key] <= 0 {
+			delete(l.active, key)
+		}
+	}
+}
+
+// wait blocks until a connection can be made within the connections rate limit,
+// returns false if the stop channel was closed while waiting.
+func (l *limiter) wait(stopChan <-chan struct{}) bool {
+
+	if l.rate == nil {
+		return true
+	}
+
+	r := l.rate.Reserve()
+	delay := r.Delay()
+	if delay == 0 {
+		return true
+	}
+
+	select {
+	case <-time.After(delay):
+		return true
+	case <-stopChan:
+		r.Cancel()
+		return false
+	}
+}
@@ -0,0 +1,272 @@
+package butler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/asset"
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+	"github.com/bmc-toolbox/bmcbutler/pkg/report"
+)
+
+// TestLimiter tests assets are actioned within the location, vendor and chassis limits.
+func TestLimiter(t *testing.T) {
+
+	l := newLimiter(&config.Limits{
+		PerLocation: 3,
+		Locations:   map[string]int{"lhr4": 1},
+		Vendors:     map[string]int{"hp": 2},
+		PerChassis:  2,
+	})
+
+	blade := func(serial string, chassis string) *asset.Asset {
+		return &asset.Asset{Serial: serial, Location: "AMS2", Vendor: "dell", Chassis: chassis}
+	}
+
+	first, ok := l.acquire(blade("blade1", "chassis1"))
+	if !ok {
+		t.Fatal("Expected the first blade to be within the limits")
+	}
+
+	_, ok = l.acquire(blade("blade2", "chassis1"))
+	if !ok {
+		t.Fatal("Expected the second blade in the chassis to be within the limits")
+	}
+
+	_, ok = l.acquire(blade("blade3", "chassis1"))
+	if ok {
+		t.Error("Expected a third blade in the chassis to exceed the chassis limit")
+	}
+
+	_, ok = l.acquire(blade("blade4", "chassis2"))
+	if !ok {
+		t.Error("Expected a blade in another chassis to be within the limits")
+	}
+
+	// the location limit of 3 is reached, regardless of the chassis.
+	_, ok = l.acquire(blade("blade5", "chassis3"))
+	if ok {
+		t.Error("Expected a fourth asset in the location to exceed the location limit")
+	}
+
+	l.release(first)
+	_, ok = l.acquire(blade("blade3", "chassis1"))
+	if !ok {
+		t.Error("Expected the blade to be within the limits once a blade in the chassis was actioned")
+	}
+
+	// the per location limit is overridden for lhr4.
+	_, ok = l.acquire(&asset.Asset{Location: "lhr4"})
+	if !ok {
+		t.Fatal("Expected the first asset in lhr4 to be within the limits")
+	}
+
+	_, ok = l.acquire(&asset.Asset{Location: "lhr4"})
+	if ok {
+		t.Error("Expected a second asset in lhr4 to exceed the location limit")
+	}
+
+	// vendors are limited only when declared, or a per vendor limit is set.
+	for i := 0; i < 2; i++ {
+		_, ok = l.acquire(&asset.Asset{Vendor: "HP"})
+		if !ok {
+			t.Fatal("Expected hp assets within the vendor limit")
+		}
+	}
+
+	_, ok = l.acquire(&asset.Asset{Vendor: "hp"})
+	if ok {
+		t.Error("Expected a third hp asset to exceed the vendor limit")
+	}
+
+	_, ok = l.acquire(&asset.Asset{})
+	if !ok {
+		t.Error("Expected an asset with no location, vendor, chassis to be within the limits")
+	}
+}
+
+// TestLimiterWait tests connections are made within the connections rate limit,
+// and waits are abandoned when the stop channel is closed.
+func TestLimiterWait(t *testing.T) {
+
+	l := newLimiter(&config.Limits{ConnectionsPerSecond: 20, Burst: 1})
+	stopChan := make(chan struct{})
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if !l.wait(stopChan) {
+			t.Fatal("Expected wait to return true")
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("Expected 3 connections at 20/s to take at least 100ms, took %s", elapsed)
+	}
+
+	l = newLimiter(&config.Limits{ConnectionsPerSecond: 0.01, Burst: 1})
+	l.wait(stopChan)
+	close(stopChan)
+
+	if l.wait(stopChan) {
+		t.Error("Expected wait to return false once the stop channel was closed")
+	}
+
+	if !newLimiter(nil).wait(stopChan) {
+		t.Error("Expected no wait without a connections rate limit")
+	}
+}
+
+// TestRunner tests all assets received are actioned, including those queued beyond the limits.
+func TestRunner(t *testing.T) {
+
+	butlerChan := make(chan Msg)
+	var wg sync.WaitGroup
+
+	b := &Butler{
+		Config:     &config.Params{ButlersToSpawn: 2, Limits: &config.Limits{PerChassis: 1}},
+		ButlerChan: butlerChan,
+		Log:        logrus.New(),
+		SyncWG:     &wg,
+	}
+
+	wg.Add(1)
+	go b.Runner(context.Background())
+
+	// assets not retrieved from the inventory are handled without being logged into.
+	for i := 0; i < 50; i++ {
+		butlerChan <- Msg{Asset: asset.Asset{Serial: fmt.Sprintf("blade%d", i), Chassis: "chassis1", Error: "not found"}}
+	}
+
+	close(butlerChan)
+	wg.Wait()
+
+	if b.Counts().Handled != 50 {
+		t.Errorf("Expected 50 assets handled, got %d", b.Counts().Handled)
+	}
+}
+
+// TestRunnerRequeue tests assets whose action failed are re-queued, and reported once with the attempts made.
+func TestRunnerRequeue(t *testing.T) {
+
+	butlerChan := make(chan Msg)
+	collector := report.NewCollector()
+	var wg sync.WaitGroup
+
+	b := &Butler{
+		Config:     &config.Params{ButlersToSpawn: 2, IgnoreLocation: true, Retry: &config.Retry{Requeue: 2}},
+		ButlerChan: butlerChan,
+		Log:        logrus.New(),
+		SyncWG:     &wg,
+		Report:     collector,
+	}
+
+	wg.Add(1)
+	go b.Runner(context.Background())
+
+	// rotating credentials on assets with no serial fails before the asset is logged into.
+	for i := 0; i < 5; i++ {
+		butlerChan <- Msg{Asset: asset.Asset{IPAddresses: []string{fmt.Sprintf("127.0.0.%d", i)}, Rotate: true}}
+	}
+
+	close(butlerChan)
+	wg.Wait()
+
+	if b.Counts().Handled != 5 {
+		t.Errorf("Expected 5 assets handled, got %d", b.Counts().Handled)
+	}
+
+	records := collector.Records()
+	if len(records) != 5 {
+		t.Fatalf("Expected a record per asset, got %d", len(records))
+	}
+
+	for _, r := range records {
+		if r.Attempts != 3 || r.Success {
+			t.Errorf("Expected the asset %s to fail after 3 attempts, got %d attempts", r.IPAddress, r.Attempts)
+		}
+	}
+}
+
+// TestRunnerExecuteNotRequeued tests commands that failed are not executed again,
+// a failed command may have partly applied.
+func TestRunnerExecuteNotRequeued(t *testing.T) {
+
+	butlerChan := make(chan Msg)
+	collector := report.NewCollector()
+	var wg sync.WaitGroup
+
+	b := &Butler{
+		Config:     &config.Params{ButlersToSpawn: 2, IgnoreLocation: true, Retry: &config.Retry{Requeue: 2}},
+		ButlerChan: butlerChan,
+		Log:        logrus.New(),
+		SyncWG:     &wg,
+		Report:     collector,
+	}
+
+	wg.Add(1)
+	go b.Runner(context.Background())
+
+	// the unknown command fails before the asset is logged into.
+	for i := 0; i < 5; i++ {
+		butlerChan <- Msg{
+			Asset:        asset.Asset{Serial: fmt.Sprintf("server%d", i), IPAddresses: []string{"127.0.0.1"}, Execute: true},
+			AssetExecute: "unknown",
+		}
+	}
+
+	close(butlerChan)
+	wg.Wait()
+
+	records := collector.Records()
+	if len(records) != 5 {
+		t.Fatalf("Expected a record per asset, got %d", len(records))
+	}
+
+	for _, r := range records {
+		if r.Attempts != 1 || r.Success {
+			t.Errorf("Expected the command on %s to fail once and not be executed again, got %d attempts", r.Serial, r.Attempts)
+		}
+	}
+}
+
+// TestRunnerAbandoned tests assets received once the context is done are reported as not actioned.
+func TestRunnerAbandoned(t *testing.T) {
+
+	butlerChan := make(chan Msg, 5)
+	collector := report.NewCollector()
+	var wg sync.WaitGroup
+
+	b := &Butler{
+		Config:     &config.Params{ButlersToSpawn: 2, IgnoreLocation: true},
+		ButlerChan: butlerChan,
+		Log:        logrus.New(),
+		SyncWG:     &wg,
+		Report:     collector,
+	}
+
+	for i := 0; i < 5; i++ {
+		butlerChan <- Msg{Asset: asset.Asset{Serial: fmt.Sprintf("server%d", i), IPAddresses: []string{"127.0.0.1"}, Configure: true}, JobID: "job1"}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	wg.Add(1)
+	b.Runner(ctx)
+
+	records := collector.Records()
+	if len(records) != 5 {
+		t.Fatalf("Expected a record per asset received, got %d", len(records))
+	}
+
+	for _, r := range records {
+		if r.Success || r.JobID != "job1" || r.Errors[0] != "not actioned: context canceled" {
+			t.Errorf("Expected the asset %s to be reported as not actioned, got %+v", r.Serial, r)
+		}
+	}
+}
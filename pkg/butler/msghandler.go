@@ -1,9 +1,23 @@
 package butler
 
 import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"time"
+
 	"github.com/sirupsen/logrus"
 
-	metrics "github.com/bmc-toolbox/gin-go-metrics"
+	"github.com/bmc-toolbox/bmcbutler/pkg/asset"
+	"github.com/bmc-toolbox/bmcbutler/pkg/butler/configure"
+	"github.com/bmc-toolbox/bmcbutler/pkg/metrics"
+	"github.com/bmc-toolbox/bmcbutler/pkg/report"
+)
+
+var (
+	errNoIP              = errors.New("asset has no IP address")
+	errLocationUnmanaged = errors.New("asset location not managed by this butler")
 )
 
 func (b *Butler) myLocation(location string) bool {
@@ -16,19 +30,198 @@ func (b *Butler) myLocation(location string) bool {
 	return false
 }
 
-// msgHandler invokes the appropriate action based on msg attributes.
+// action returns the action requested on the asset.
+func action(a *asset.Asset) string {
+	switch {
+	case a.Execute:
+		return "execute"
+	case a.Audit:
+		return "audit"
+	case a.Rotate:
+		return "rotate"
+	case a.Configure:
+		return "configure"
+	default:
+		return ""
+	}
+}
+
+// labels returns the metric labels for the asset,
+// the vendor and model are known only after the asset was logged into.
+func labels(a *asset.Asset) metrics.Labels {
+	return metrics.Labels{Vendor: a.Vendor, Model: a.Model, Location: a.Location}
+}
+
+// resourceMetrics emits a metric for each resource applied, failed, skipped on the asset.
+func resourceMetrics(a *asset.Asset, record *report.Record) {
+
+	outcomes := []struct {
+		key       string
+		resources []string
+	}{
+		{"resource_applied", record.Applied},
+		{"resource_failed", record.Failed},
+		{"resource_skipped", record.Skipped},
+	}
+
+	for _, o := range outcomes {
+		for _, resource := range o.resources {
+			l := labels(a)
+			l.Resource = resource
+			metrics.IncrCounterWithLabels([]string{"butler", o.key}, 1, l)
+		}
+	}
+}
+
+// resources returns the resources to be applied on the asset,
+// resources declared in the msg override the resources declared in the config.
+func (b *Butler) resources(msg *Msg) []string {
+	if len(msg.Resources) > 0 {
+		return msg.Resources
+	}
+
+	return b.Config.Resources
+}
+
+// addRecord adds the record for the asset to the run report,
+// records with no action set are not reported.
+func (b *Butler) addRecord(record report.Record, a *asset.Asset, start time.Time) {
+
+	if b.Report == nil || record.Action == "" {
+		return
+	}
+
+	record.Serial = a.Serial
+	record.IPAddress = a.IPAddress
+	if record.IPAddress == "" {
+		record.IPAddress = strings.Join(a.IPAddresses, ",")
+	}
+	record.Vendor = a.Vendor
+	record.Model = a.Model
+	record.Type = a.Type
+	record.Location = a.Location
+	record.Duration = report.Since(start)
+	record.Success = len(record.Failed) == 0 && len(record.Errors) == 0
+
+	b.Report.Add(record)
+}
+
+// abandon reports the assets that were not actioned since the context is done,
+// so a record is added for each asset received, e.g for API jobs to be marked done.
+func (b *Butler) abandon(msgs []Msg, err error) {
+
+	for idx := range msgs {
+		record := report.Record{
+			Action:   action(&msgs[idx].Asset),
+			JobID:    msgs[idx].JobID,
+			Attempts: msgs[idx].Attempt,
+			Errors:   []string{"not actioned: " + err.Error()},
+		}
+
+		b.addRecord(record, &msgs[idx].Asset, time.Now())
+	}
+}
+
+// checkpoint records the outcome of the action on the asset in the run checkpoint,
+// assets whose action was abandoned on an interrupt are left pending, to be actioned when the run is resumed.
+func (b *Butler) checkpoint(ctx context.Context, a *asset.Asset, record *report.Record) {
+
+	if b.Checkpoint == nil {
+		return
+	}
+
+	success := len(record.Failed) == 0 && len(record.Errors) == 0
+	if !success && ctx.Err() != nil {
+		return
+	}
+
+	b.Checkpoint.Done(a.Serial, success)
+}
+
+// requeue returns true if the asset is to be actioned again once its action failed,
+// assets are re-queued up to the declared requeue times, unless an interrupt was received.
+// Commands executed are never re-queued, a failed command may have partly applied.
+func (b *Butler) requeue(ctx context.Context, msg *Msg) bool {
+
+	if b.Config.Retry == nil || ctx.Err() != nil || msg.Asset.Execute {
+		return false
+	}
+
+	return msg.Attempt < b.Config.Retry.Requeue
+}
+
+// msgHandler invokes the appropriate action based on msg attributes,
+// the action is abandoned once the asset timeout elapses or the context is done,
+// returns true if the action failed and the asset is to be re-queued.
 // nolint: gocyclo
-func (b *Butler) msgHandler(msg Msg) {
+func (b *Butler) msgHandler(ctx context.Context, msg Msg) (requeue bool) {
 
 	// if an interrupt was received, return.
-	if b.interrupt {
+	if ctx.Err() != nil {
+		b.abandon([]Msg{msg}, ctx.Err())
 		return
 	}
 
 	log := b.Log
 	component := "msgHandler"
 
-	metrics.IncrCounter([]string{"butler", "asset_recvd"}, 1)
+	metrics.IncrCounterWithLabels([]string{"butler", "asset_recvd"}, 1, labels(&msg.Asset))
+
+	start := time.Now()
+	record := report.Record{Action: action(&msg.Asset), JobID: msg.JobID, Attempts: msg.Attempt + 1}
+
+	// failed is set if the action failed, final if the asset is not to be re-queued even if it failed,
+	// counter is the configure outcome counter to be incremented.
+	var failed, final bool
+	var counter *int64
+
+	defer func(parent context.Context) {
+		if record.TimedOut {
+			metrics.IncrCounterWithLabels([]string{"butler", "asset_timeout"}, 1, labels(&msg.Asset))
+		}
+
+		// re-queued assets are reported and counted once actioned for the last time.
+		requeue = failed && !final && b.requeue(parent, &msg)
+		if requeue {
+			log.WithFields(logrus.Fields{
+				"component": component,
+				"Serial":    msg.Asset.Serial,
+				"Attempts":  record.Attempts,
+			}).Debug("Asset action failed, asset re-queued.")
+			return
+		}
+
+		b.addRecord(record, &msg.Asset, start)
+		b.checkpoint(parent, &msg.Asset, &record)
+		atomic.AddInt64(&b.counts.Handled, 1)
+		if counter != nil {
+			atomic.AddInt64(counter, 1)
+		}
+	}(ctx)
+
+	if b.Config.Timeouts != nil && b.Config.Timeouts.Asset > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.Config.Timeouts.Asset)
+		defer cancel()
+	}
+
+	//if asset could not be retrieved from the inventory, we can't do anything about it
+	if msg.Asset.Error != "" {
+		log.WithFields(logrus.Fields{
+			"component": component,
+			"Serial":    msg.Asset.Serial,
+			"IPAddress": msg.Asset.IPAddresses,
+			"Error":     msg.Asset.Error,
+		}).Warn("Asset could not be retrieved from the inventory, skipped.")
+
+		metrics.IncrCounterWithLabels([]string{"butler", "asset_recvd_inventory_error"}, 1, labels(&msg.Asset))
+		record.Errors = append(record.Errors, "inventory: "+msg.Asset.Error)
+		if msg.Asset.Configure {
+			counter = &b.counts.ConfigureFail
+		}
+
+		return
+	}
 
 	//if asset has no IPAddress, we can't do anything about it
 	if len(msg.Asset.IPAddresses) == 0 {
@@ -38,7 +231,8 @@ func (b *Butler) msgHandler(msg Msg) {
 			"AssetType": msg.Asset.Type,
 		}).Debug("Asset was received by butler without any IP(s) info, skipped.")
 
-		metrics.IncrCounter([]string{"butler", "asset_recvd_noip"}, 1)
+		metrics.IncrCounterWithLabels([]string{"butler", "asset_recvd_noip"}, 1, labels(&msg.Asset))
+		record.Errors = append(record.Errors, errNoIP.Error())
 		return
 	}
 
@@ -52,15 +246,26 @@ func (b *Butler) msgHandler(msg Msg) {
 				"AssetLocation": msg.Asset.Location,
 			}).Warn("Butler wont manage asset based on its current location.")
 
-			metrics.IncrCounter([]string{"butler", "asset_recvd_location_unmanaged"}, 1)
+			metrics.IncrCounterWithLabels([]string{"butler", "asset_recvd_location_unmanaged"}, 1, labels(&msg.Asset))
+
+			// assets requested by a job are always reported back.
+			if msg.JobID == "" {
+				record.Action = ""
+			}
+
+			record.Errors = append(record.Errors, errLocationUnmanaged.Error())
 			return
 		}
 	}
 
 	switch {
 	case msg.Asset.Execute == true:
-		err := b.executeCommand(msg.AssetExecute, &msg.Asset)
+		err := b.executeCommand(ctx, msg.AssetExecute, msg.ExecuteArgs, &msg.Asset)
 		if err != nil {
+			record.Failed = []string{msg.AssetExecute}
+			record.Errors = append(record.Errors, err.Error())
+			record.TimedOut = configure.TimedOut(err)
+			failed = true
 			log.WithFields(logrus.Fields{
 				"component": component,
 				"Serial":    msg.Asset.Serial,
@@ -69,15 +274,95 @@ func (b *Butler) msgHandler(msg Msg) {
 				"Location":  msg.Asset.Location,
 				"Error":     err,
 			}).Warn("Unable Execute command(s) on asset.")
-			metrics.IncrCounter([]string{"butler", "execute_fail"}, 1)
+			metrics.IncrCounterWithLabels([]string{"butler", "execute_fail"}, 1, labels(&msg.Asset))
+			return
+		}
+
+		record.Applied = []string{msg.AssetExecute}
+		metrics.IncrCounterWithLabels([]string{"butler", "execute_success"}, 1, labels(&msg.Asset))
+		return
+	case msg.Asset.Audit == true:
+		result, err := b.auditAsset(ctx, msg.AssetConfig, b.resources(&msg), &msg.Asset)
+		if err != nil {
+			record.Errors = append(record.Errors, err.Error())
+			record.TimedOut = configure.TimedOut(err)
+			failed = true
+			log.WithFields(logrus.Fields{
+				"component": component,
+				"Serial":    msg.Asset.Serial,
+				"AssetType": msg.Asset.Type,
+				"Vendor":    msg.Asset.Vendor, //at this point the vendor may or may not be known.
+				"Location":  msg.Asset.Location,
+				"Error":     err,
+			}).Warn("Audit action returned error.")
+
+			metrics.IncrCounterWithLabels([]string{"butler", "audit_fail"}, 1, labels(&msg.Asset))
+			return
+		}
+
+		record.Applied = result.Compliant
+		record.Failed = append(result.Drifted, result.Failed...)
+		record.Skipped = result.NotAudited
+		record.TimedOut = result.TimedOut
+		failed = len(result.Failed) > 0 || result.TimedOut
+		record.Errors = append(record.Errors, result.Errors...)
+		for _, d := range result.Drift {
+			record.Errors = append(record.Errors, d.Resource+": "+d.String())
+		}
+
+		if len(result.Drifted) > 0 {
+			metrics.IncrCounterWithLabels([]string{"butler", "audit_drifted"}, 1, labels(&msg.Asset))
+			return
+		}
+
+		// assets with no resources audited e.g chassis, are neither compliant nor failed.
+		if len(result.Compliant) == 0 && len(result.NotAudited) > 0 {
+			metrics.IncrCounterWithLabels([]string{"butler", "audit_not_audited"}, 1, labels(&msg.Asset))
+			return
+		}
+
+		metrics.IncrCounterWithLabels([]string{"butler", "audit_compliant"}, 1, labels(&msg.Asset))
+		return
+	case msg.Asset.Rotate == true:
+		err := b.rotateCredentials(ctx, &msg.Asset)
+		if err != nil {
+			record.Failed = []string{"credentials"}
+			record.Errors = append(record.Errors, err.Error())
+			record.TimedOut = configure.TimedOut(err)
+			failed = true
+
+			// the password on the asset is unknown if the roll back failed, it is not rotated again.
+			var rollbackErr *rollbackError
+			final = errors.As(err, &rollbackErr)
+			log.WithFields(logrus.Fields{
+				"component": component,
+				"Serial":    msg.Asset.Serial,
+				"AssetType": msg.Asset.Type,
+				"Vendor":    msg.Asset.Vendor, //at this point the vendor may or may not be known.
+				"Location":  msg.Asset.Location,
+				"Error":     err,
+			}).Warn("Rotate credentials action returned error.")
+
+			metrics.IncrCounterWithLabels([]string{"butler", "rotate_fail"}, 1, labels(&msg.Asset))
 			return
 		}
 
-		metrics.IncrCounter([]string{"butler", "execute_success"}, 1)
+		record.Applied = []string{"credentials"}
+		metrics.IncrCounterWithLabels([]string{"butler", "rotate_success"}, 1, labels(&msg.Asset))
 		return
 	case msg.Asset.Configure == true:
-		err := b.configureAsset(msg.AssetConfig, &msg.Asset)
+		result, err := b.configureAsset(ctx, msg.AssetConfig, b.resources(&msg), &msg.Asset)
+		record.Applied = result.Applied
+		record.Failed = result.Failed
+		record.Skipped = result.Skipped
+		record.Errors = result.Errors
+		record.TimedOut = result.TimedOut
+		record.Retried = result.Retried
+		resourceMetrics(&msg.Asset, &record)
 		if err != nil {
+			record.Errors = append(record.Errors, err.Error())
+			record.TimedOut = configure.TimedOut(err)
+			failed = true
 			log.WithFields(logrus.Fields{
 				"component": component,
 				"Serial":    msg.Asset.Serial,
@@ -87,11 +372,21 @@ func (b *Butler) msgHandler(msg Msg) {
 				"Error":     err,
 			}).Warn("Configure action returned error.")
 
-			metrics.IncrCounter([]string{"butler", "configure_fail"}, 1)
+			metrics.IncrCounterWithLabels([]string{"butler", "configure_fail"}, 1, labels(&msg.Asset))
+			counter = &b.counts.ConfigureFail
 			return
 		}
 
-		metrics.IncrCounter([]string{"butler", "configure_success"}, 1)
+		// an asset with resources that failed to apply, or that timed out, is a failed configure.
+		if len(result.Failed) > 0 || result.TimedOut {
+			metrics.IncrCounterWithLabels([]string{"butler", "configure_fail"}, 1, labels(&msg.Asset))
+			failed = true
+			counter = &b.counts.ConfigureFail
+			return
+		}
+
+		metrics.IncrCounterWithLabels([]string{"butler", "configure_success"}, 1, labels(&msg.Asset))
+		counter = &b.counts.ConfigureSuccess
 		return
 	default:
 		log.WithFields(logrus.Fields{
@@ -102,4 +397,6 @@ func (b *Butler) msgHandler(msg Msg) {
 			"Location":  msg.Asset.Location,
 		}).Warn("Unknown action request on asset.")
 	} //switch
+
+	return
 }
@@ -0,0 +1,211 @@
+package butler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bmc-toolbox/bmclib/cfgresources"
+	"github.com/bmc-toolbox/bmclib/devices"
+	"github.com/bmc-toolbox/bmclogin"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/asset"
+	"github.com/bmc-toolbox/bmcbutler/pkg/secrets"
+)
+
+var errNoSerial = errors.New("asset has no serial, the rotated password can't be stored")
+
+// rollbackError is returned when the previous password could not be set again,
+// the password on the asset is then unknown, so the asset is not rotated again in the run.
+type rollbackError struct {
+	err         error
+	rollbackErr error
+}
+
+func (e *rollbackError) Error() string {
+	return fmt.Sprintf("%s, roll back to the previous password failed: %s", e.err, e.rollbackErr)
+}
+
+func (e *rollbackError) Unwrap() error {
+	return e.err
+}
+
+// rotateCredentials sets a new generated password for the rotate user on the asset,
+// verifies the new password works, and writes it to the vault path <rotate path>/<serial>,
+// if the password can't be verified or stored, the previous password is set again.
+func (b *Butler) rotateCredentials(ctx context.Context, asset *asset.Asset) error {
+
+	component := "rotateCredentials"
+	log := b.Log
+	cfg := b.Config.Rotate
+
+	if asset.Serial == "" {
+		return errNoSerial
+	}
+
+	if b.Config.DryRun {
+		log.WithFields(logrus.Fields{
+			"component": component,
+			"Asset":     fmt.Sprintf("%+v", asset),
+			"User":      cfg.User,
+		}).Info("Dry run, won't rotate credentials on asset.")
+		return nil
+	}
+
+	bmcConn := bmclogin.Params{
+		IpAddresses:     asset.IPAddresses,
+		Credentials:     b.Creds.Credentials(asset),
+		CheckCredential: true,
+		Retries:         1,
+	}
+
+	client, loginInfo, err := b.login(ctx, &bmcConn)
+	if err != nil {
+		return err
+	}
+
+	asset.IPAddress = loginInfo.ActiveIpAddress
+	b.Creds.Worked(asset, loginInfo.WorkingCredentials)
+
+	// the previous password is required to roll back.
+	previous, exists := loginInfo.WorkingCredentials[cfg.User]
+	if !exists {
+		closeConn(client)
+		return fmt.Errorf("logged in with credentials of a user other than %s, the current password of %s is unknown", cfg.User, cfg.User)
+	}
+
+	password, err := secrets.GeneratePassword(cfg.Length)
+	if err != nil {
+		closeConn(client)
+		return err
+	}
+
+	// the password may have been set even if an error was returned,
+	// the previous password is set again if the new password is not verified and stored.
+	err = b.setPassword(client, asset, password)
+	if err == nil {
+		err = b.verifyPassword(ctx, asset, password)
+	}
+
+	if err == nil {
+		err = b.Vault.WriteKV(path.Join(cfg.Path, asset.Serial), map[string]string{cfg.User: password})
+	}
+
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"component": component,
+			"Serial":    asset.Serial,
+			"IPAddress": asset.IPAddress,
+			"User":      cfg.User,
+			"Error":     err,
+		}).Warn("New password could not be set, verified or stored, rolling back to the previous password.")
+
+		rollbackErr := b.rollbackPassword(asset, password, previous)
+		if rollbackErr != nil {
+			return &rollbackError{err: err, rollbackErr: rollbackErr}
+		}
+
+		return fmt.Errorf("%w, rolled back to the previous password", err)
+	}
+
+	b.Creds.Rotated(asset, map[string]string{cfg.User: password})
+
+	log.WithFields(logrus.Fields{
+		"component": component,
+		"Serial":    asset.Serial,
+		"AssetType": asset.Type,
+		"Vendor":    asset.Vendor,
+		"Location":  asset.Location,
+		"User":      cfg.User,
+	}).Info("Credentials rotated.")
+
+	return nil
+}
+
+// verifyPassword logs in to the asset with the new password on a new connection.
+func (b *Butler) verifyPassword(ctx context.Context, asset *asset.Asset, password string) error {
+
+	bmcConn := bmclogin.Params{
+		IpAddresses:     []string{asset.IPAddress},
+		Credentials:     []map[string]string{{b.Config.Rotate.User: password}},
+		CheckCredential: true,
+		Retries:         1,
+	}
+
+	client, _, err := b.login(ctx, &bmcConn)
+	if err != nil {
+		return fmt.Errorf("login with the new password failed: %w", err)
+	}
+
+	closeConn(client)
+
+	return nil
+}
+
+// rollbackPassword sets the previous password again,
+// logging in with the new password, or the previous password if the new password was not set.
+// The roll back is carried out even if the asset timed out or an interrupt was received,
+// so the asset is not left with a password that was not stored.
+func (b *Butler) rollbackPassword(asset *asset.Asset, password string, previous string) error {
+
+	user := b.Config.Rotate.User
+	bmcConn := bmclogin.Params{
+		IpAddresses:     []string{asset.IPAddress},
+		Credentials:     []map[string]string{{user: password}, {user: previous}},
+		CheckCredential: true,
+		Retries:         1,
+	}
+
+	client, _, err := b.login(context.Background(), &bmcConn)
+	if err != nil {
+		return err
+	}
+
+	return b.setPassword(client, asset, previous)
+}
+
+// setPassword sets the password of the rotate user on the bmc/chassis and closes the connection.
+func (b *Butler) setPassword(client interface{}, asset *asset.Asset, password string) error {
+
+	defer closeConn(client)
+
+	user := []*cfgresources.User{
+		{
+			Name:     b.Config.Rotate.User,
+			Password: password,
+			Role:     b.Config.Rotate.Role,
+			Enable:   true,
+		},
+	}
+
+	switch client.(type) {
+	case devices.Bmc:
+		bmc := client.(devices.Bmc)
+		asset.Type = "server"
+		asset.Vendor = bmc.Vendor()
+
+		return bmc.User(user)
+	case devices.Cmc:
+		chassis := client.(devices.Cmc)
+		asset.Type = "chassis"
+		asset.Vendor = chassis.Vendor()
+
+		return chassis.User(user)
+	default:
+		return errors.New("Unknown asset type")
+	}
+}
+
+// closeConn closes the bmc/chassis connection.
+func closeConn(client interface{}) {
+
+	switch c := client.(type) {
+	case devices.Bmc:
+		c.Close(context.TODO())
+	case devices.Cmc:
+		c.Close()
+	}
+}
@@ -0,0 +1,310 @@
+package checkpoint
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+)
+
+// Checkpoint records the progress of a run, an interrupted run is resumed from its checkpoint.
+type Checkpoint struct {
+	RunID     string    `json:"run_id"`
+	Command   string    `json:"command"`
+	Started   time.Time `json:"started"`
+	Updated   time.Time `json:"updated"`
+	Offset    int       `json:"offset"` //the assets read before the offset were all actioned, a progress hint for the last run.
+	Completed []string  `json:"completed"`
+	Failed    []string  `json:"failed"`
+	Finished  bool      `json:"finished"` //set once all assets in the inventory were actioned.
+}
+
+// Recorder records assets actioned in a run to its checkpoint file,
+// the checkpoint is written at most once per the declared interval, and when closed.
+type Recorder struct {
+	mutex      sync.Mutex
+	file       string
+	interval   time.Duration
+	written    time.Time
+	checkpoint Checkpoint
+	completed  map[string]bool
+	failed     map[string]bool
+	positions  map[string]int //the inventory position of assets read, not yet actioned.
+	done       map[int]bool   //the inventory positions beyond the offset actioned.
+}
+
+// New returns a Recorder for a new run of the given command, the run ID is generated.
+func New(cfg *config.Checkpoint, command string) (*Recorder, error) {
+
+	err := os.MkdirAll(cfg.Dir, 0700)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create checkpoint dir %s: %s", cfg.Dir, err)
+	}
+
+	runID, err := newRunID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	r := newRecorder(cfg, Checkpoint{RunID: runID, Command: command, Started: now, Updated: now})
+
+	return r, r.write()
+}
+
+// Resume returns a Recorder for the run with the given ID, loaded from its checkpoint file.
+func Resume(cfg *config.Checkpoint, runID string) (*Recorder, error) {
+
+	checkpoint, err := Load(cfg.Dir, runID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the offset is counted over the assets read in the resumed run.
+	checkpoint.Finished = false
+	checkpoint.Offset = 0
+	return newRecorder(cfg, checkpoint), nil
+}
+
+// Load reads the checkpoint of the run with the given ID from the checkpoint dir.
+func Load(dir string, runID string) (checkpoint Checkpoint, err error) {
+
+	b, err := ioutil.ReadFile(file(dir, runID))
+	if err != nil {
+		return checkpoint, fmt.Errorf("unable to read checkpoint for run %s: %s", runID, err)
+	}
+
+	err = json.Unmarshal(b, &checkpoint)
+	if err != nil {
+		return checkpoint, fmt.Errorf("unable to parse checkpoint for run %s: %s", runID, err)
+	}
+
+	return checkpoint, nil
+}
+
+func newRecorder(cfg *config.Checkpoint, checkpoint Checkpoint) *Recorder {
+
+	r := &Recorder{
+		file:       file(cfg.Dir, checkpoint.RunID),
+		interval:   cfg.Interval,
+		checkpoint: checkpoint,
+		completed:  make(map[string]bool),
+		failed:     make(map[string]bool),
+		positions:  make(map[string]int),
+		done:       make(map[int]bool),
+	}
+
+	for _, serial := range checkpoint.Completed {
+		r.completed[serial] = true
+	}
+
+	for _, serial := range checkpoint.Failed {
+		r.failed[serial] = true
+	}
+
+	return r
+}
+
+// file returns the checkpoint file for the run.
+func file(dir string, runID string) string {
+	return filepath.Join(dir, runID+".json")
+}
+
+// newRunID returns a run ID made up of the start time and a random suffix.
+func newRunID() (string, error) {
+
+	suffix := make([]byte, 4)
+	_, err := rand.Read(suffix)
+	if err != nil {
+		return "", fmt.Errorf("unable to generate run ID: %s", err)
+	}
+
+	return time.Now().UTC().Format("20060102T150405") + "-" + hex.EncodeToString(suffix), nil
+}
+
+// RunID returns the ID of the run checkpointed.
+func (r *Recorder) RunID() string {
+	return r.checkpoint.RunID
+}
+
+// Offset returns the inventory position before which all assets read were actioned.
+func (r *Recorder) Offset() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.checkpoint.Offset
+}
+
+// Completed returns true if the asset was actioned successfully in the run,
+// assets with no serial are not checkpointed, they are actioned whenever the run is resumed.
+func (r *Recorder) Completed(serial string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return serial != "" && r.completed[serial]
+}
+
+// Actioned returns true if the asset was actioned in the run, whether it succeeded or failed.
+func (r *Recorder) Actioned(serial string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return serial != "" && (r.completed[serial] || r.failed[serial])
+}
+
+// Failed returns the serials of assets that failed in the run.
+func (r *Recorder) Failed() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return keys(r.failed)
+}
+
+// Read records the asset at the given inventory position was read to be actioned.
+func (r *Recorder) Read(serial string, position int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	// assets with no serial are not waited on, they can't be told apart.
+	if serial == "" {
+		r.advance(position)
+		return
+	}
+
+	// an asset listed twice is actioned twice, its earlier position is not waited on.
+	if previous, exists := r.positions[serial]; exists {
+		r.advance(previous)
+	}
+
+	r.positions[serial] = position
+}
+
+// Skip records the asset at the given inventory position was not actioned,
+// since it was actioned before the run was resumed.
+func (r *Recorder) Skip(position int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.advance(position)
+	_ = r.flush(false)
+}
+
+// Done records the outcome of the action on the asset,
+// assets whose action failed are retried when resumed with just the failures.
+func (r *Recorder) Done(serial string, success bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if serial == "" {
+		return
+	}
+
+	if success {
+		r.completed[serial] = true
+		delete(r.failed, serial)
+	} else if !r.completed[serial] {
+		r.failed[serial] = true
+	}
+
+	if position, exists := r.positions[serial]; exists {
+		delete(r.positions, serial)
+		r.advance(position)
+	}
+
+	// a checkpoint that fails to be written is retried on the next asset, the error is returned on Close.
+	_ = r.flush(false)
+}
+
+// advance marks the inventory position actioned, the offset is moved past positions all actioned.
+func (r *Recorder) advance(position int) {
+
+	if position < r.checkpoint.Offset {
+		return
+	}
+
+	r.done[position] = true
+	for r.done[r.checkpoint.Offset] {
+		delete(r.done, r.checkpoint.Offset)
+		r.checkpoint.Offset++
+	}
+}
+
+// flush writes the checkpoint if the interval elapsed since it was last written, or if forced.
+func (r *Recorder) flush(force bool) error {
+
+	if !force && time.Since(r.written) < r.interval {
+		return nil
+	}
+
+	return r.write()
+}
+
+// Close writes the checkpoint, finished is set once all assets in the inventory were actioned.
+func (r *Recorder) Close(finished bool) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.checkpoint.Finished = finished
+	return r.flush(true)
+}
+
+// write writes the checkpoint to a temp file renamed over the checkpoint file,
+// so an interrupt while writing leaves the previous checkpoint in place.
+func (r *Recorder) write() error {
+
+	r.checkpoint.Updated = time.Now()
+	r.checkpoint.Completed = keys(r.completed)
+	r.checkpoint.Failed = keys(r.failed)
+
+	b, err := json.MarshalIndent(r.checkpoint, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(r.file), filepath.Base(r.file)+".tmp")
+	if err != nil {
+		return fmt.Errorf("unable to write checkpoint: %s", err)
+	}
+
+	_, err = tmp.Write(b)
+	if err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("unable to write checkpoint: %s", err)
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("unable to write checkpoint: %s", err)
+	}
+
+	err = os.Rename(tmp.Name(), r.file)
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("unable to write checkpoint: %s", err)
+	}
+
+	r.written = time.Now()
+	return nil
+}
+
+// keys returns the sorted keys of the set.
+func keys(set map[string]bool) []string {
+
+	k := make([]string, 0, len(set))
+	for serial := range set {
+		k = append(k, serial)
+	}
+
+	sort.Strings(k)
+	return k
+}
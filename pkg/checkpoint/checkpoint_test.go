@@ -0,0 +1,107 @@
+package checkpoint
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+)
+
+// TestResume tests the offset, completed and failed assets are recorded,
+// and the completed and failed assets are loaded when the run is resumed.
+func TestResume(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "bmcbutler-checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := &config.Checkpoint{Dir: dir, Interval: time.Hour}
+
+	r, err := New(cfg, "configure")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for position, serial := range []string{"server0", "server1", "server2", "server3"} {
+		r.Read(serial, position)
+	}
+
+	// server1 is still being actioned, the offset is held back at its position.
+	r.Done("server0", true)
+	r.Done("server2", false)
+	r.Done("server3", true)
+
+	if r.Offset() != 1 {
+		t.Errorf("Expected the offset to be held at 1, got %d", r.Offset())
+	}
+
+	err = r.Close(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err = Resume(cfg, r.RunID())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Offset() != 0 {
+		t.Errorf("Expected the offset to be counted over the resumed run, got %d", r.Offset())
+	}
+
+	if !r.Actioned("server2") || r.Actioned("server1") || r.Completed("server2") {
+		t.Error("Expected server2 to be recorded failed and server1 pending")
+	}
+
+	if !reflect.DeepEqual(r.Failed(), []string{"server2"}) {
+		t.Errorf("Expected server2 failed, got %v", r.Failed())
+	}
+
+	// the assets actioned are skipped, in whatever order the inventory returns them.
+	r.Skip(0)
+	r.Skip(1)
+	r.Read("server1", 2)
+	r.Skip(3)
+	r.Done("server1", true)
+
+	if r.Offset() != 4 {
+		t.Errorf("Expected the offset to be 4 once all assets were actioned, got %d", r.Offset())
+	}
+
+	// assets with no serial are not checkpointed.
+	r.Read("", 4)
+	r.Done("", true)
+	if r.Actioned("") || r.Offset() != 5 {
+		t.Errorf("Expected assets with no serial to not be recorded, or waited on, offset %d", r.Offset())
+	}
+
+	// a failed asset retried successfully is completed.
+	r.Done("server2", true)
+	if len(r.Failed()) != 0 {
+		t.Errorf("Expected no failed assets, got %v", r.Failed())
+	}
+
+	err = r.Close(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkpoint, err := Load(dir, r.RunID())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !checkpoint.Finished || len(checkpoint.Completed) != 4 || checkpoint.Command != "configure" {
+		t.Errorf("Expected a finished configure checkpoint with 4 assets completed, got %+v", checkpoint)
+	}
+
+	_, err = Resume(cfg, "unknown")
+	if err == nil {
+		t.Error("Expected an error resuming an unknown run")
+	}
+}
@@ -22,17 +22,35 @@ import (
 type Params struct {
 	ButlersToSpawn   int                 `mapstructure:"butlersToSpawn"`
 	Credentials      []map[string]string `mapstructure:"credentials"`
+	AssetCredentials *AssetCredentials   `mapstructure:"assetCredentials"`
 	CertSigner       *CertSigner         `mapstructure:"cert_signer"`
+	Checkpoint       *Checkpoint         `mapstructure:"checkpoint"`
 	Inventory        *Inventory          `mapstructure:"inventory"`
+	Limits           *Limits             `mapstructure:"limits"`
 	Locations        []string            `mapstructure:"locations"`
 	Metrics          *Metrics            `mapstructure:"metrics"`
+	Report           *Report             `mapstructure:"report"`
+	Retry            *Retry              `mapstructure:"retry"`
+	Rotate           *Rotate             `mapstructure:"rotate"`
+	Secrets          *Secrets            `mapstructure:"secrets"`
+	Serve            *Serve              `mapstructure:"serve"`
+	State            *State              `mapstructure:"state"`
+	Timeouts         *Timeouts           `mapstructure:"timeouts"`
+	Validate         *Validate           `mapstructure:"validate"`
 	FilterParams     *FilterParams
 	CfgFile          string
 	Configure        bool //indicates configure was invoked
+	Audit            bool //indicates audit was invoked
 	DryRun           bool //when set, don't carry out any actions, just log.
 	Execute          bool //indicates execute was invoked
+	RotateCreds      bool //indicates rotate-credentials was invoked
+	Force            bool //when set, resources are applied even if unchanged since the last apply.
+	Reconcile        bool //indicates serve was invoked, unchanged resources are re-applied to correct drift unless within state.maxAge.
 	IgnoreLocation   bool
+	SkipValidate     bool //when set, the BMC configuration is not validated before it is applied.
 	Resources        []string
+	Resume           string //the run ID of the checkpoint to resume.
+	RetryFailed      bool   //when set with Resume, just the assets that failed in the run resumed are actioned.
 	Version          string
 	Debug            bool
 	Trace            bool
@@ -41,11 +59,14 @@ type Params struct {
 }
 
 // Inventory struct holds inventory configuration parameters.
+// the Sources map holds config sections of inventory sources not declared here,
+// which are decoded by the inventory source.
 type Inventory struct {
-	Source string //dora, csv, enc
-	Enc    *Enc   `mapstructure:"enc"`
-	Dora   *Dora  `mapstructure:"dora"`
-	Csv    *Csv   `mapstrucure:"csv"`
+	Source  string                 `mapstructure:"source"` //dora, csv, enc, iplist or any other registered source.
+	Enc     *Enc                   `mapstructure:"enc"`
+	Dora    *Dora                  `mapstructure:"dora"`
+	Csv     *Csv                   `mapstructure:"csv"`
+	Sources map[string]interface{} `mapstructure:",remain"`
 }
 
 // Enc declares config for a ENC as an inventory source
@@ -64,10 +85,51 @@ type Dora struct {
 	URL string `mapstructure:"url"`
 }
 
+// Limits struct declares the number of assets actioned concurrently per location, vendor and parent chassis,
+// and the rate at which BMC connections are made, a zero limit is unlimited.
+type Limits struct {
+	ConnectionsPerSecond float64        `mapstructure:"connectionsPerSecond"`
+	Burst                int            `mapstructure:"burst"`       //connections made at once, defaults to 1.
+	PerLocation          int            `mapstructure:"perLocation"` //assets actioned concurrently in a location.
+	Locations            map[string]int `mapstructure:"locations"`   //overrides perLocation for the location.
+	PerVendor            int            `mapstructure:"perVendor"`   //assets actioned concurrently of a vendor, if known from the inventory.
+	Vendors              map[string]int `mapstructure:"vendors"`     //overrides perVendor for the vendor.
+	PerChassis           int            `mapstructure:"perChassis"`  //blades actioned concurrently in a chassis.
+}
+
+// Timeouts struct declares how long butlers wait on an asset before giving up on it,
+// login covers the connection and login to the asset, resource the apply or audit of each resource,
+// asset the whole action on the asset, a zero timeout is not enforced.
+type Timeouts struct {
+	Login    time.Duration `mapstructure:"login"`
+	Resource time.Duration `mapstructure:"resource"`
+	Asset    time.Duration `mapstructure:"asset"`
+}
+
+// Retry struct declares how transient BMC failures are retried,
+// failed logins and resources are retried as declared by their retry policy,
+// assets whose action failed are re-queued once the butlers are idle, up to requeue times.
+type Retry struct {
+	Login    *RetryPolicy `mapstructure:"login"`
+	Resource *RetryPolicy `mapstructure:"resource"`
+	Requeue  int          `mapstructure:"requeue"`
+}
+
+// RetryPolicy struct declares the attempts made, and the backoff between attempts,
+// the backoff is doubled on each attempt, up to the max backoff.
+// Only errors of the retryable kinds are retried, one of connection, timeout, 5xx, session, login.
+type RetryPolicy struct {
+	Attempts   int           `mapstructure:"attempts"`   //defaults to 1, not retried.
+	Backoff    time.Duration `mapstructure:"backoff"`    //defaults to 5s
+	MaxBackoff time.Duration `mapstructure:"maxBackoff"` //defaults to 1m
+	Retryable  []string      `mapstructure:"retryable"`  //defaults to all kinds but login.
+}
+
 // Metrics struct holds metrics emitter configuration parameters.
 type Metrics struct {
-	Client   string    //The metrics client.
-	Graphite *Graphite `mapstructure:"graphite"`
+	Client     string      //The metrics client.
+	Graphite   *Graphite   `mapstructure:"graphite"`
+	Prometheus *Prometheus `mapstructure:"prometheus"`
 }
 
 // Graphite struct holds attributes for the Graphite metrics emitter
@@ -78,11 +140,86 @@ type Graphite struct {
 	FlushInterval time.Duration `mapstructure:"flushInterval"`
 }
 
-// CertSigner struct
+// Prometheus struct holds attributes for the Prometheus metrics emitter,
+// metrics are exposed on the listen address /metrics endpoint and/or pushed to the pushgateway at exit.
+type Prometheus struct {
+	Prefix      string `mapstructure:"prefix"`
+	Listen      string `mapstructure:"listen"`
+	PushGateway string `mapstructure:"pushgateway"`
+	Job         string `mapstructure:"job"` //the pushgateway job name.
+}
+
+// Report struct declares the files the run report is written to.
+type Report struct {
+	JSON  string `mapstructure:"json"`
+	JUnit string `mapstructure:"junit"`
+}
+
+// AssetCredentials struct declares how BMC credentials are resolved for each asset,
+// the credentials resolved are tried before the declared credentials.
+type AssetCredentials struct {
+	UserExtra     string                         `mapstructure:"userExtra"`     //the asset extra attribute holding the BMC user.
+	PasswordExtra string                         `mapstructure:"passwordExtra"` //the asset extra attribute holding the password or a lookup_secret:: reference.
+	VaultPaths    []string                       `mapstructure:"vaultPaths"`    //vault KV paths templated with {serial}, {location}, {vendor}.
+	Vendors       map[string][]map[string]string `mapstructure:"vendors"`       //credentials by vendor.
+	Exclusive     bool                           `mapstructure:"exclusive"`     //when set, the declared credentials are not tried on assets with credentials resolved.
+}
+
+// Rotate struct declares the BMC user whose password is rotated by rotate-credentials,
+// the rotated password of each asset is written to the Vault KV path <path>/<serial>.
+type Rotate struct {
+	User   string `mapstructure:"user"`
+	Role   string `mapstructure:"role"`   //defaults to admin
+	Length int    `mapstructure:"length"` //defaults to 20
+	Path   string `mapstructure:"path"`
+}
+
+// Serve struct declares the reconcile loop parameters for serve mode.
+type Serve struct {
+	Interval time.Duration `mapstructure:"interval"` //interval between reconcile runs.
+	Listen   string        `mapstructure:"listen"`   //address to serve the HTTP API on, the API is disabled if empty.
+	Token    string        `mapstructure:"token"`    //bearer token API requests are expected to present, or a lookup_secret::<secret> reference.
+}
+
+// State struct declares the local state store of resources applied on assets.
+type State struct {
+	File   string        `mapstructure:"file"`
+	MaxAge time.Duration `mapstructure:"maxAge"` //unchanged resources are re-applied after maxAge, never if zero - on every serve run if zero.
+}
+
+// Checkpoint struct declares the directory run checkpoints are written to,
+// a checkpoint records the progress of a configure run so an interrupted run can be resumed.
+type Checkpoint struct {
+	Dir      string        `mapstructure:"dir"`
+	Interval time.Duration `mapstructure:"interval"` //the checkpoint is written at most once per interval, defaults to 10s.
+}
+
+// Validate struct declares the sample assets the BMC configuration is validated for,
+// the configuration is rendered and validated for each sample asset in each of the locations.
+type Validate struct {
+	Assets []SampleAsset `mapstructure:"assets"`
+}
+
+// SampleAsset declares the attributes of an asset the BMC configuration is validated for.
+type SampleAsset struct {
+	Vendor   string            `mapstructure:"vendor"`
+	Model    string            `mapstructure:"model"`
+	Type     string            `mapstructure:"type"`
+	Location string            `mapstructure:"location"`
+	Serial   string            `mapstructure:"serial"`
+	Extra    map[string]string `mapstructure:"extra"`
+}
+
+// CertSigner struct declares the signer CSRs generated on BMCs are signed with.
 type CertSigner struct {
-	Client      string
-	FakeSigner  *FakeSigner  `mapstructure:"fake"`
-	LemurSigner *LemurSigner `mapstructure:"lemur"`
+	Client        string
+	Timeout       time.Duration  `mapstructure:"timeout"` //time to wait for a CSR to be signed.
+	FakeSigner    *FakeSigner    `mapstructure:"fake"`
+	ExecSigner    *ExecSigner    `mapstructure:"exec"`
+	LemurSigner   *LemurSigner   `mapstructure:"lemur"`
+	LocalCASigner *LocalCASigner `mapstructure:"local_ca"`
+	StepCASigner  *StepCASigner  `mapstructure:"step_ca"`
+	VaultSigner   *VaultSigner   `mapstructure:"vault"`
 }
 
 // FakeSigner struct holds SSL/TLS cert signing attributes.
@@ -93,15 +230,56 @@ type FakeSigner struct {
 	Args       []string `mapstructure:"args"`
 }
 
-// LemurSigner struct holds SSL/TLS cert signing attributes.
+// ExecSigner struct declares an executable that is passed CSRs on STDIN and returns signed certs on STDOUT.
+type ExecSigner struct {
+	Bin  string            `mapstructure:"bin"`
+	Args []string          `mapstructure:"args"`
+	Env  map[string]string `mapstructure:"env"`
+}
+
+// LemurSigner struct holds SSL/TLS cert signing attributes,
+// CSRs are passed to the Lemur API, unless the lemur signer bin is declared.
 type LemurSigner struct {
-	Client        string `mapstructure:"client"`
-	Authority     string `mapstructure:"authority"`
-	ValidityYears string `mapstructure:"validity_years"`
-	Owner         string `mapstructure:"owner_email"`
-	Key           string `mapstructure:"auth_token"`
-	Bin           string `mapstructure:"bin"`
-	Endpoint      string `mapstructure:"endpoint"`
+	Client             string `mapstructure:"client"`
+	Authority          string `mapstructure:"authority"`
+	ValidityYears      string `mapstructure:"validity_years"`
+	Owner              string `mapstructure:"owner_email"`
+	Key                string `mapstructure:"auth_token"`
+	Bin                string `mapstructure:"bin"`
+	Endpoint           string `mapstructure:"endpoint"`
+	Notify             bool   `mapstructure:"notify"`
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"`
+}
+
+// LocalCASigner struct declares the CA cert and key CSRs are signed with,
+// read from the PEM files, or the Vault secrets declared.
+type LocalCASigner struct {
+	CertFile   string        `mapstructure:"cert_file"`
+	KeyFile    string        `mapstructure:"key_file"`
+	CertSecret string        `mapstructure:"cert_secret"`
+	KeySecret  string        `mapstructure:"key_secret"`
+	Validity   time.Duration `mapstructure:"validity"`
+}
+
+// StepCASigner struct declares the step-ca JWK provisioner CSRs are signed with,
+// the provisioner key is read from the PEM file, or the Vault secret declared.
+type StepCASigner struct {
+	URL         string        `mapstructure:"url"`
+	Provisioner string        `mapstructure:"provisioner"`
+	KeyID       string        `mapstructure:"kid"`
+	KeyFile     string        `mapstructure:"key_file"`
+	KeySecret   string        `mapstructure:"key_secret"`
+	RootCA      string        `mapstructure:"root_ca"`
+	Validity    time.Duration `mapstructure:"validity"`
+}
+
+// VaultSigner struct declares the Vault PKI secrets engine role CSRs are signed with,
+// the role is looked up by the cert organizationUnit in Roles, else Role is used.
+type VaultSigner struct {
+	Mount string            `mapstructure:"mount"` //defaults to pki
+	Role  string            `mapstructure:"role"`
+	Roles map[string]string `mapstructure:"roles"`
+	TTL   string            `mapstructure:"ttl"` //when not declared, the role TTL applies.
 }
 
 // FilterParams struct holds various asset filter arguments that may be passed via cli args.
@@ -113,11 +291,58 @@ type FilterParams struct {
 	Ips     string
 }
 
+// Secrets struct declares the secrets providers secrets are looked up from,
+// providers are looked up in the order declared, else in the order env, file, exec, vault.
+type Secrets struct {
+	Providers []string     `mapstructure:"providers"` //env, file, exec, vault
+	Env       *SecretsEnv  `mapstructure:"env"`
+	File      *SecretsFile `mapstructure:"file"`
+	Exec      *SecretsExec `mapstructure:"exec"`
+}
+
+// SecretsEnv declares secrets are looked up from environment variables named <prefix><secret>.
+type SecretsEnv struct {
+	Prefix string `mapstructure:"prefix"`
+}
+
+// SecretsFile declares a YAML file of secrets, SOPS encrypted files are decrypted with the sops bin.
+type SecretsFile struct {
+	Path    string `mapstructure:"path"`
+	SopsBin string `mapstructure:"sopsBin"` //defaults to sops
+}
+
+// SecretsExec declares an executable that is passed the secret name as its last arg,
+// and returns the secret on STDOUT.
+type SecretsExec struct {
+	Bin     string        `mapstructure:"bin"`
+	Args    []string      `mapstructure:"args"`
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
 // Vault struct declares vault config attributes
 type Vault struct {
-	TokenFromFile string `mapstructure:"tokenFromFile"`
-	TokenFromEnv  bool   `mapstructure:"tokenFromEnv"`
-	SecretsPath   string `mapstructure:"secretsPath"`
-	HostAddress   string `mapstructure:"hostAddress"`
-	Token         string `mapstructure:"token"`
+	TokenFromFile string            `mapstructure:"tokenFromFile"`
+	TokenFromEnv  bool              `mapstructure:"tokenFromEnv"`
+	SecretsPath   string            `mapstructure:"secretsPath"`
+	SecretsPaths  map[string]string `mapstructure:"secretsPaths"` //secrets under these paths are looked up as <name>/<secret>.
+	KVVersion     int               `mapstructure:"kvVersion"`    //1 or 2, the version of the KV secrets engine.
+	KVMount       string            `mapstructure:"kvMount"`      //the KV v2 mount, defaults to the first element of the secrets path.
+	HostAddress   string            `mapstructure:"hostAddress"`
+	Token         string            `mapstructure:"token"`
+	Auth          *VaultAuth        `mapstructure:"auth"` //when declared, the token is obtained by logging in with the auth method.
+}
+
+// VaultAuth struct declares the Vault auth method to login with,
+// one of approle, kubernetes, cert.
+type VaultAuth struct {
+	Method                  string `mapstructure:"method"`
+	Mount                   string `mapstructure:"mount"` //defaults to the method name.
+	RoleID                  string `mapstructure:"roleID"`
+	SecretID                string `mapstructure:"secretID"`
+	SecretIDFromFile        string `mapstructure:"secretIDFromFile"`
+	Role                    string `mapstructure:"role"`
+	ServiceAccountTokenFile string `mapstructure:"serviceAccountTokenFile"`
+	ClientCert              string `mapstructure:"clientCert"`
+	ClientKey               string `mapstructure:"clientKey"`
+	CACert                  string `mapstructure:"caCert"`
 }
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+// TestCopy tests changes to a copy of the params are not seen through the params.
+func TestCopy(t *testing.T) {
+
+	p := &Params{
+		Locations:    []string{"ams2"},
+		Credentials:  []map[string]string{{"user": "password"}},
+		FilterParams: &FilterParams{Serials: "FOO"},
+		Limits:       &Limits{Locations: map[string]int{"ams2": 1}},
+		Inventory:    &Inventory{Sources: map[string]interface{}{"netbox": map[string]interface{}{"url": "http://netbox"}}},
+		Timeouts:     &Timeouts{Login: time.Minute},
+	}
+
+	c := p.Copy()
+	c.Locations[0] = "lhr4"
+	c.Credentials[0]["user"] = "changed"
+	c.FilterParams.Serials = "BAR"
+	c.Limits.Locations["ams2"] = 5
+	c.Inventory.Sources["netbox"].(map[string]interface{})["url"] = "http://changed"
+
+	if p.Locations[0] != "ams2" || p.Credentials[0]["user"] != "password" || p.FilterParams.Serials != "FOO" || p.Limits.Locations["ams2"] != 1 {
+		t.Errorf("Expected the params to be unchanged, got %+v", p)
+	}
+
+	if p.Inventory.Sources["netbox"].(map[string]interface{})["url"] != "http://netbox" {
+		t.Error("Expected the inventory source config to be unchanged")
+	}
+
+	if c.Timeouts.Login != time.Minute || c.Vault != nil {
+		t.Errorf("Expected the copy to hold the params values, got %+v", c)
+	}
+}
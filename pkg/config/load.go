@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -26,11 +27,20 @@ func (p *Params) Load(cfgFile string) {
 
 	// slice of config section validators
 	validators := []func() error{
+		p.validateSecretsCfg,
+		p.validateRotateCfg,
+		p.validateAssetCredentialsCfg,
 		p.validateVaultCfg,
 		p.validateMetricsCfg,
 		p.validateInventoryCfg,
 		p.defaults,
 		p.validateCertSignerCfg,
+		p.validateServeCfg,
+		p.validateStateCfg,
+		p.validateLimitsCfg,
+		p.validateTimeoutsCfg,
+		p.validateRetryCfg,
+		p.validateCheckpointCfg,
 	}
 
 	// validate config sections
@@ -89,32 +99,223 @@ func (p *Params) validateCertSignerCfg() error {
 		if p.CertSigner.FakeSigner != nil {
 			p.CertSigner.Client = "fakeSigner"
 
+		} else if p.CertSigner.ExecSigner != nil {
+			p.CertSigner.Client = "execSigner"
+
 		} else if p.CertSigner.LemurSigner != nil {
 			p.CertSigner.Client = "lemurSigner"
 
+		} else if p.CertSigner.LocalCASigner != nil {
+			p.CertSigner.Client = "localCASigner"
+
+		} else if p.CertSigner.StepCASigner != nil {
+			p.CertSigner.Client = "stepCASigner"
+
+		} else if p.CertSigner.VaultSigner != nil {
+			p.CertSigner.Client = "vaultSigner"
+
+			if p.CertSigner.VaultSigner.Mount == "" {
+				p.CertSigner.VaultSigner.Mount = "pki"
+			}
+
+			if p.CertSigner.VaultSigner.Role == "" && len(p.CertSigner.VaultSigner.Roles) == 0 {
+				return fmt.Errorf("vault cert_signer expects a role to be declared")
+			}
+
 		} else {
 			log.Println("[WARN] Invalid cert_signer declared in config.")
 		}
+
+		if p.CertSigner.Timeout == 0 {
+			p.CertSigner.Timeout = 30 * time.Second
+		}
 	}
 
 	return nil
 }
 
+// inventory config
+// the inventory source is the declared source,
+// or else the enc, dora, csv section declared, or the one other inventory section declared.
 func (p *Params) validateInventoryCfg() error {
-	//inventory config
-	if p.Inventory != nil {
 
-		if p.Inventory.Enc != nil {
-			p.Inventory.Source = "enc"
+	if p.Inventory == nil || p.Inventory.Source != "" {
+		return nil
+	}
 
-		} else if p.Inventory.Dora != nil {
-			p.Inventory.Source = "dora"
+	switch {
+	case p.Inventory.Enc != nil:
+		p.Inventory.Source = "enc"
+	case p.Inventory.Dora != nil:
+		p.Inventory.Source = "dora"
+	case p.Inventory.Csv != nil:
+		p.Inventory.Source = "csv"
+	case len(p.Inventory.Sources) == 1:
+		for source := range p.Inventory.Sources {
+			p.Inventory.Source = source
+		}
+	case len(p.Inventory.Sources) > 1:
+		return fmt.Errorf("multiple inventory sections declared, expected 'source' to declare the inventory source")
+	default:
+		log.Println("[WARN] Invalid inventory source declared in configuration.")
+	}
 
-		} else if p.Inventory.Csv != nil {
-			p.Inventory.Source = "csv"
+	return nil
+}
 
-		} else {
-			log.Println("[WARN] Invalid inventory source declared in configuration.")
+// serve config
+func (p *Params) validateServeCfg() error {
+
+	if p.Serve == nil {
+		p.Serve = &Serve{}
+	}
+
+	// default reconcile interval
+	if p.Serve.Interval == 0 {
+		p.Serve.Interval = time.Hour
+	}
+
+	if p.Serve.Interval < time.Minute {
+		return fmt.Errorf("serve interval expected to be a minute or more, got %s", p.Serve.Interval)
+	}
+
+	return nil
+}
+
+// state store config
+func (p *Params) validateStateCfg() error {
+
+	if p.State == nil {
+		return nil
+	}
+
+	if p.State.File == "" {
+		return fmt.Errorf("state configuration expects the state store file to be declared")
+	}
+
+	return nil
+}
+
+// scheduling limits config
+func (p *Params) validateLimitsCfg() error {
+
+	if p.Limits == nil {
+		return nil
+	}
+
+	if p.Limits.ConnectionsPerSecond < 0 || p.Limits.PerLocation < 0 || p.Limits.PerVendor < 0 || p.Limits.PerChassis < 0 {
+		return fmt.Errorf("limits configuration expects limits to be zero (unlimited) or more")
+	}
+
+	if p.Limits.Burst == 0 {
+		p.Limits.Burst = 1
+	}
+
+	// vendors are compared in lower case.
+	vendors := make(map[string]int, len(p.Limits.Vendors))
+	for vendor, limit := range p.Limits.Vendors {
+		vendors[strings.ToLower(vendor)] = limit
+	}
+
+	p.Limits.Vendors = vendors
+
+	return nil
+}
+
+// timeouts config, timeouts not declared are zero, butlers wait on the asset for as long as it takes.
+func (p *Params) validateTimeoutsCfg() error {
+
+	if p.Timeouts == nil {
+		p.Timeouts = &Timeouts{}
+	}
+
+	if p.Timeouts.Login < 0 || p.Timeouts.Resource < 0 || p.Timeouts.Asset < 0 {
+		return fmt.Errorf("timeouts configuration expects timeouts to be more than zero")
+	}
+
+	return nil
+}
+
+// checkpoint config
+func (p *Params) validateCheckpointCfg() error {
+
+	if p.Checkpoint == nil {
+		if p.Resume != "" {
+			return fmt.Errorf("--resume expects the checkpoint configuration to be declared")
+		}
+
+		return nil
+	}
+
+	if p.Checkpoint.Dir == "" {
+		return fmt.Errorf("checkpoint configuration expects the checkpoint dir to be declared")
+	}
+
+	if p.Checkpoint.Interval == 0 {
+		p.Checkpoint.Interval = 10 * time.Second
+	}
+
+	return nil
+}
+
+// retryKinds are the kinds of errors retry policies retry.
+var retryKinds = []string{"connection", "timeout", "5xx", "session", "login"}
+
+// defaultRetryKinds are the kinds retried when none are declared,
+// a failed login is also what wrong credentials return, retrying it may lock BMC accounts out.
+var defaultRetryKinds = []string{"connection", "timeout", "5xx", "session"}
+
+// retry config
+func (p *Params) validateRetryCfg() error {
+
+	if p.Retry == nil {
+		p.Retry = &Retry{}
+	}
+
+	if p.Retry.Requeue < 0 {
+		return fmt.Errorf("retry configuration expects requeue to be zero or more")
+	}
+
+	if p.Retry.Login == nil {
+		p.Retry.Login = &RetryPolicy{}
+	}
+
+	if p.Retry.Resource == nil {
+		p.Retry.Resource = &RetryPolicy{}
+	}
+
+	for _, policy := range []*RetryPolicy{p.Retry.Login, p.Retry.Resource} {
+		if policy.Attempts < 0 || policy.Backoff < 0 || policy.MaxBackoff < 0 {
+			return fmt.Errorf("retry configuration expects attempts, backoff to be more than zero")
+		}
+
+		if policy.Attempts == 0 {
+			policy.Attempts = 1
+		}
+
+		if policy.Backoff == 0 {
+			policy.Backoff = 5 * time.Second
+		}
+
+		if policy.MaxBackoff == 0 {
+			policy.MaxBackoff = time.Minute
+		}
+
+		if len(policy.Retryable) == 0 {
+			policy.Retryable = append([]string{}, defaultRetryKinds...)
+		}
+
+		for idx, kind := range policy.Retryable {
+			policy.Retryable[idx] = strings.ToLower(kind)
+
+			var known bool
+			for _, k := range retryKinds {
+				known = known || k == policy.Retryable[idx]
+			}
+
+			if !known {
+				return fmt.Errorf("retry configuration declares an unknown retryable kind %s, expected one of %s", kind, strings.Join(retryKinds, ", "))
+			}
 		}
 	}
 
@@ -124,36 +325,197 @@ func (p *Params) validateInventoryCfg() error {
 // metrics config
 func (p *Params) validateMetricsCfg() error {
 
-	if p.Metrics != nil {
-		if p.Metrics.Graphite != nil {
-			p.Metrics.Client = "graphite"
-		} else {
-			log.Println("[WARN] Invalid metrics client declared in config.")
+	if p.Metrics == nil {
+		return nil
+	}
+
+	if p.Metrics.Graphite != nil && p.Metrics.Prometheus != nil {
+		return fmt.Errorf("metrics configuration expects one of graphite, prometheus to be declared")
+	}
+
+	switch {
+	case p.Metrics.Graphite != nil:
+		p.Metrics.Client = "graphite"
+	case p.Metrics.Prometheus != nil:
+		p.Metrics.Client = "prometheus"
+
+		if p.Metrics.Prometheus.Listen == "" && p.Metrics.Prometheus.PushGateway == "" {
+			return fmt.Errorf("prometheus metrics configuration expects a listen address and/or a pushgateway")
+		}
+
+		if p.Metrics.Prometheus.Prefix == "" {
+			p.Metrics.Prometheus.Prefix = "bmcbutler"
+		}
+
+		if p.Metrics.Prometheus.Job == "" {
+			p.Metrics.Prometheus.Job = "bmcbutler"
+		}
+	default:
+		log.Println("[WARN] Invalid metrics client declared in config.")
+	}
+
+	return nil
+}
+
+// secrets providers config
+// when the providers are not declared, the sections declared are looked up in the order env, file, exec.
+func (p *Params) validateSecretsCfg() error {
+
+	if p.Secrets == nil {
+		return nil
+	}
+
+	if len(p.Secrets.Providers) == 0 {
+		if p.Secrets.Env != nil {
+			p.Secrets.Providers = append(p.Secrets.Providers, "env")
+		}
+
+		if p.Secrets.File != nil {
+			p.Secrets.Providers = append(p.Secrets.Providers, "file")
+		}
+
+		if p.Secrets.Exec != nil {
+			p.Secrets.Providers = append(p.Secrets.Providers, "exec")
+		}
+
+	}
+
+	// with secretsFromVault, vault is looked up last unless its position is declared.
+	vault := false
+	for _, provider := range p.Secrets.Providers {
+		vault = vault || provider == "vault"
+	}
+
+	if p.SecretsFromVault && !vault {
+		p.Secrets.Providers = append(p.Secrets.Providers, "vault")
+	}
+
+	for _, provider := range p.Secrets.Providers {
+		switch provider {
+		case "env":
+			if p.Secrets.Env == nil {
+				p.Secrets.Env = &SecretsEnv{}
+			}
+		case "file":
+			if p.Secrets.File == nil || p.Secrets.File.Path == "" {
+				return fmt.Errorf("secrets file provider expects a path")
+			}
+
+			if p.Secrets.File.SopsBin == "" {
+				p.Secrets.File.SopsBin = "sops"
+			}
+		case "exec":
+			if p.Secrets.Exec == nil || p.Secrets.Exec.Bin == "" {
+				return fmt.Errorf("secrets exec provider expects a bin")
+			}
+
+			if p.Secrets.Exec.Timeout == 0 {
+				p.Secrets.Exec.Timeout = 10 * time.Second
+			}
+		case "vault":
+			p.SecretsFromVault = true
+		default:
+			return fmt.Errorf("unknown secrets provider: %s, expected one of env, file, exec, vault", provider)
 		}
 	}
 
 	return nil
 }
 
+// credential rotation config
+func (p *Params) validateRotateCfg() error {
+
+	if !p.RotateCreds {
+		return nil
+	}
+
+	if p.Rotate == nil {
+		return fmt.Errorf("rotate-credentials expects the rotate configuration section")
+	}
+
+	if p.Rotate.User == "" {
+		return fmt.Errorf("rotate configuration expects the user whose password is rotated")
+	}
+
+	if p.Rotate.Path == "" {
+		return fmt.Errorf("rotate configuration expects the vault path rotated passwords are written to")
+	}
+
+	if p.Rotate.Role == "" {
+		p.Rotate.Role = "admin"
+	}
+
+	if p.Rotate.Length == 0 {
+		p.Rotate.Length = 20
+	}
+
+	return nil
+}
+
+// per asset credentials config
+func (p *Params) validateAssetCredentialsCfg() error {
+
+	if p.AssetCredentials == nil {
+		return nil
+	}
+
+	c := p.AssetCredentials
+	if (c.UserExtra == "") != (c.PasswordExtra == "") {
+		return fmt.Errorf("assetCredentials expects both userExtra and passwordExtra to be declared")
+	}
+
+	// vendors are compared in lower case.
+	vendors := make(map[string][]map[string]string, len(c.Vendors))
+	for vendor, credentials := range c.Vendors {
+		vendors[strings.ToLower(vendor)] = credentials
+	}
+
+	c.Vendors = vendors
+
+	return nil
+}
+
+// VaultRequired returns true if the configuration declared requires vault,
+// for secrets, the vault cert signer, credential rotation or per asset credentials.
+func (p *Params) VaultRequired() bool {
+
+	vaultSigner := p.CertSigner != nil && p.CertSigner.VaultSigner != nil
+	vaultPaths := p.AssetCredentials != nil && len(p.AssetCredentials.VaultPaths) > 0
+
+	return p.SecretsFromVault || vaultSigner || vaultPaths || p.RotateCreds
+}
+
 // vault config
 func (p *Params) validateVaultCfg() error {
 
-	if !p.SecretsFromVault {
+	if !p.VaultRequired() {
 		return nil
 	}
 
 	if p.Vault == nil {
-		return fmt.Errorf("secretsFromVault declared, expected vault configuration section missing")
+		return fmt.Errorf("secretsFromVault, vault cert_signer, rotate-credentials or assetCredentials vaultPaths declared, expected vault configuration section missing")
 	}
 
 	if p.Vault.HostAddress == "" {
 		return fmt.Errorf("bmcbutler vault configuration expects a valid hostAddress")
 	}
 
-	if p.Vault.SecretsPath == "" {
+	if p.SecretsFromVault && p.Vault.SecretsPath == "" {
 		return fmt.Errorf("bmcbutler vault configuration expects the vault path for secrets")
 	}
 
+	switch p.Vault.KVVersion {
+	case 0:
+		p.Vault.KVVersion = 1
+	case 1, 2:
+	default:
+		return fmt.Errorf("bmcbutler vault configuration expects kvVersion to be 1 or 2")
+	}
+
+	if p.Vault.Auth != nil {
+		return p.validateVaultAuthCfg()
+	}
+
 	err := p.loadVaultToken()
 	if err != nil {
 		return err
@@ -162,6 +524,42 @@ func (p *Params) validateVaultCfg() error {
 	return nil
 }
 
+// vault auth method config
+func (p *Params) validateVaultAuthCfg() error {
+
+	auth := p.Vault.Auth
+	if auth.Mount == "" {
+		auth.Mount = auth.Method
+	}
+
+	switch auth.Method {
+	case "approle":
+		if auth.RoleID == "" {
+			return fmt.Errorf("vault approle auth expects a roleID")
+		}
+
+		if auth.SecretID == "" && auth.SecretIDFromFile == "" {
+			return fmt.Errorf("vault approle auth expects a secretID or secretIDFromFile")
+		}
+	case "kubernetes":
+		if auth.Role == "" {
+			return fmt.Errorf("vault kubernetes auth expects a role")
+		}
+
+		if auth.ServiceAccountTokenFile == "" {
+			auth.ServiceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+		}
+	case "cert":
+		if auth.ClientCert == "" || auth.ClientKey == "" {
+			return fmt.Errorf("vault cert auth expects a clientCert and clientKey")
+		}
+	default:
+		return fmt.Errorf("unknown vault auth method: %s, expected one of approle, kubernetes, cert", auth.Method)
+	}
+
+	return nil
+}
+
 func (p *Params) loadVaultToken() error {
 
 	// token declared in config file
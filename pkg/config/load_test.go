@@ -49,4 +49,9 @@ func TestLoad(t *testing.T) {
 		t.Errorf("Expected ButlersToSpawn: 1, got %d", cfg.ButlersToSpawn)
 	}
 
+	// timeouts are opt-in, none are declared in the sample config.
+	if cfg.Timeouts == nil || cfg.Timeouts.Login != 0 || cfg.Timeouts.Resource != 0 || cfg.Timeouts.Asset != 0 {
+		t.Errorf("Expected no timeouts, got %+v", cfg.Timeouts)
+	}
+
 }
@@ -0,0 +1,276 @@
+package credentials
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/asset"
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+	"github.com/bmc-toolbox/bmcbutler/pkg/secrets"
+	"github.com/bmc-toolbox/bmcbutler/pkg/state"
+)
+
+const lookupPrefix = "lookup_secret::"
+
+// Resolver resolves the credentials to login to each asset with,
+// instead of trying every declared credential on every asset.
+//
+// The credentials are tried in the order,
+// - the credential rotated on the asset in this run.
+// - the credential that last worked on the asset, if recorded in the state store.
+// - the credential from the asset extra attributes, e.g from the ENC.
+// - the credentials from the vault paths templated with the asset serial, location, vendor.
+// - the credentials declared for the asset vendor.
+// - the declared credentials, unless exclusive is set and credentials were resolved for the asset.
+type Resolver struct {
+	config   *config.AssetCredentials
+	declared []map[string]string
+	secrets  secrets.Provider
+	vault    *secrets.Client
+	state    *state.Store
+	log      *logrus.Logger
+	rotated  map[string]map[string]string
+	mutex    sync.Mutex
+}
+
+// NewResolver returns a credentials resolver,
+// the asset credentials config, secrets provider, vault client and state store may be nil.
+func NewResolver(c *config.AssetCredentials, declared []map[string]string, secretsProvider secrets.Provider, vault *secrets.Client, store *state.Store, log *logrus.Logger) *Resolver {
+
+	if c == nil {
+		c = &config.AssetCredentials{}
+	}
+
+	return &Resolver{
+		config:   c,
+		declared: declared,
+		secrets:  secretsProvider,
+		vault:    vault,
+		state:    store,
+		log:      log,
+		rotated:  make(map[string]map[string]string),
+	}
+}
+
+// Credentials returns the credentials to login to the asset with, in the order they are to be tried.
+func (r *Resolver) Credentials(a *asset.Asset) []map[string]string {
+
+	resolved := r.resolve(a)
+
+	credentials := resolved
+	if !r.config.Exclusive || len(resolved) == 0 {
+		credentials = append(credentials, r.declared...)
+	}
+
+	credentials = dedup(credentials)
+
+	// the credential that last worked is tried first.
+	if r.state != nil && a.Serial != "" {
+		fingerprint, err := r.state.Credential(a.Serial)
+		if err != nil {
+			r.log.WithFields(logrus.Fields{
+				"component": "credentials",
+				"Serial":    a.Serial,
+				"Error":     err,
+			}).Warn("Unable to lookup the credential that last worked on asset.")
+		}
+
+		for idx, credential := range credentials {
+			if fingerprint != "" && Fingerprint(a.Serial, credential) == fingerprint {
+				credentials = append([]map[string]string{credential}, append(credentials[:idx:idx], credentials[idx+1:]...)...)
+				break
+			}
+		}
+	}
+
+	r.mutex.Lock()
+	rotated, exists := r.rotated[a.Serial]
+	r.mutex.Unlock()
+
+	if exists {
+		credentials = dedup(append([]map[string]string{rotated}, credentials...))
+	}
+
+	return credentials
+}
+
+// resolve returns the credentials resolved for the asset from its extra attributes, vault and the vendor.
+func (r *Resolver) resolve(a *asset.Asset) (credentials []map[string]string) {
+
+	log := r.log
+	component := "credentials"
+
+	if r.config.UserExtra != "" {
+		user, password := a.Extra[r.config.UserExtra], a.Extra[r.config.PasswordExtra]
+		if strings.HasPrefix(password, lookupPrefix) {
+			password = r.lookup(a, strings.TrimPrefix(password, lookupPrefix))
+		}
+
+		if user != "" && password != "" {
+			credentials = append(credentials, map[string]string{user: password})
+		}
+	}
+
+	for _, path := range r.config.VaultPaths {
+		if r.vault == nil {
+			break
+		}
+
+		path = Path(path, a)
+		values, err := r.vault.ReadKV(path)
+		if err != nil {
+			if !secrets.IsNotFound(err) {
+				log.WithFields(logrus.Fields{
+					"component": component,
+					"Serial":    a.Serial,
+					"Path":      path,
+					"Error":     err,
+				}).Warn("Unable to read asset credentials from vault.")
+			}
+
+			continue
+		}
+
+		credentials = append(credentials, sorted(values)...)
+	}
+
+	credentials = append(credentials, r.config.Vendors[strings.ToLower(a.Vendor)]...)
+
+	return credentials
+}
+
+// lookup returns the secret from the secrets provider, an empty string if it could not be looked up.
+func (r *Resolver) lookup(a *asset.Asset, secret string) string {
+
+	if r.secrets == nil {
+		r.log.WithFields(logrus.Fields{
+			"component": "credentials",
+			"Serial":    a.Serial,
+			"Secret":    secret,
+		}).Warn("Asset credential declares a secret lookup, no secrets providers declared.")
+		return ""
+	}
+
+	value, err := r.secrets.Get(secret)
+	if err != nil {
+		r.log.WithFields(logrus.Fields{
+			"component": "credentials",
+			"Serial":    a.Serial,
+			"Error":     err,
+		}).Warn("Unable to lookup asset credential secret.")
+		return ""
+	}
+
+	return value
+}
+
+// CheckCredential returns true if credentials are to be checked on login,
+// which is required to record the credential that worked on the asset,
+// since without the check, the first credential is returned as working for http(s) connections.
+func (r *Resolver) CheckCredential() bool {
+	return r.state != nil
+}
+
+// Worked records the credential that worked on the asset in the state store,
+// to be tried first on later runs.
+func (r *Resolver) Worked(a *asset.Asset, credential map[string]string) {
+
+	if r.state == nil || a.Serial == "" || len(credential) == 0 {
+		return
+	}
+
+	fingerprint := Fingerprint(a.Serial, credential)
+
+	// avoid a write if the credential is unchanged.
+	current, err := r.state.Credential(a.Serial)
+	if err == nil && current == fingerprint {
+		return
+	}
+
+	err = r.state.PutCredential(a.Serial, fingerprint)
+	if err != nil {
+		r.log.WithFields(logrus.Fields{
+			"component": "credentials",
+			"Serial":    a.Serial,
+			"Error":     err,
+		}).Warn("Unable to record the credential that worked on asset.")
+	}
+}
+
+// Rotated records the credential rotated on the asset, it is tried first on later logins.
+func (r *Resolver) Rotated(a *asset.Asset, credential map[string]string) {
+
+	r.mutex.Lock()
+	r.rotated[a.Serial] = credential
+	r.mutex.Unlock()
+
+	r.Worked(a, credential)
+}
+
+// Path returns the vault path with the {serial}, {location}, {vendor} placeholders replaced by the asset attributes.
+func Path(path string, a *asset.Asset) string {
+	return strings.NewReplacer(
+		"{serial}", a.Serial,
+		"{location}", a.Location,
+		"{vendor}", strings.ToLower(a.Vendor),
+	).Replace(path)
+}
+
+// Fingerprint returns a fingerprint of the credential, salted with the asset serial,
+// credentials are recorded by their fingerprint so the state store does not hold passwords.
+func Fingerprint(serial string, credential map[string]string) string {
+
+	h := sha256.New()
+	h.Write([]byte(serial))
+	for _, c := range sorted(credential) {
+		for user, password := range c {
+			h.Write([]byte{0})
+			h.Write([]byte(user))
+			h.Write([]byte{0})
+			h.Write([]byte(password))
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// sorted returns the user, password pairs as credentials sorted by user.
+func sorted(values map[string]string) []map[string]string {
+
+	users := make([]string, 0, len(values))
+	for user := range values {
+		users = append(users, user)
+	}
+
+	sort.Strings(users)
+
+	credentials := make([]map[string]string, 0, len(users))
+	for _, user := range users {
+		credentials = append(credentials, map[string]string{user: values[user]})
+	}
+
+	return credentials
+}
+
+// dedup returns the credentials with duplicates removed, the first occurrence is kept.
+func dedup(credentials []map[string]string) []map[string]string {
+
+	seen := make(map[string]bool)
+	unique := make([]map[string]string, 0, len(credentials))
+	for _, credential := range credentials {
+		key := Fingerprint("", credential)
+		if seen[key] {
+			continue
+		}
+
+		seen[key] = true
+		unique = append(unique, credential)
+	}
+
+	return unique
+}
@@ -0,0 +1,134 @@
+package credentials
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/asset"
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+	"github.com/bmc-toolbox/bmcbutler/pkg/secrets"
+	"github.com/bmc-toolbox/bmcbutler/pkg/state"
+)
+
+type secretsStandIn map[string]string
+
+func (s secretsStandIn) Get(k string) (string, error) {
+	value, exists := s[k]
+	if !exists {
+		return "", &secrets.NotFoundError{Secret: k, Provider: "test"}
+	}
+
+	return value, nil
+}
+
+// TestCredentials tests the credentials resolved for an asset and the order they are tried in.
+func TestCredentials(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/secret/bmc/CZ1234":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]interface{}{"Administrator": "rotated", "Ops": "ops"},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"errors": []string{}})
+		}
+	}))
+	defer server.Close()
+
+	vault, err := secrets.NewClient(config.Vault{HostAddress: server.URL, Token: "token", KVVersion: 1})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	dir, err := ioutil.TempDir("", "bmcbutler-credentials")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := state.Open(filepath.Join(dir, "state.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	c := &config.AssetCredentials{
+		UserExtra:     "bmcUser",
+		PasswordExtra: "bmcPassword",
+		VaultPaths:    []string{"secret/bmc/{serial}", "secret/bmc/{location}"},
+		Vendors:       map[string][]map[string]string{"hp": {{"Administrator": "hp-default"}}},
+	}
+
+	declared := []map[string]string{{"root": "calvin"}, {"Administrator": "hp-default"}}
+	r := NewResolver(c, declared, secretsStandIn{"enc/CZ1234": "enc"}, vault, store, logrus.New())
+
+	a := &asset.Asset{
+		Serial:   "CZ1234",
+		Vendor:   "HP",
+		Location: "ams2",
+		Extra:    map[string]string{"bmcUser": "Administrator", "bmcPassword": "lookup_secret::enc/CZ1234"},
+	}
+
+	expected := []map[string]string{
+		{"Administrator": "enc"},
+		{"Administrator": "rotated"},
+		{"Ops": "ops"},
+		{"Administrator": "hp-default"},
+		{"root": "calvin"},
+	}
+
+	credentials := r.Credentials(a)
+	if !reflect.DeepEqual(credentials, expected) {
+		t.Errorf("Expected credentials %v, got %v", expected, credentials)
+	}
+
+	// the credential that worked is tried first.
+	r.Worked(a, map[string]string{"root": "calvin"})
+	credentials = r.Credentials(a)
+	if !reflect.DeepEqual(credentials[0], map[string]string{"root": "calvin"}) || len(credentials) != len(expected) {
+		t.Errorf("Expected the credential that worked first, got %v", credentials)
+	}
+
+	// the rotated credential is tried before the credential that worked.
+	r.Rotated(a, map[string]string{"Administrator": "hunter3"})
+	credentials = r.Credentials(a)
+	if !reflect.DeepEqual(credentials[0], map[string]string{"Administrator": "hunter3"}) {
+		t.Errorf("Expected the rotated credential first, got %v", credentials)
+	}
+
+	// with exclusive, the declared credentials are only tried on assets with no credentials resolved.
+	c.Exclusive = true
+	r = NewResolver(c, declared, nil, nil, nil, logrus.New())
+	credentials = r.Credentials(a)
+	if !reflect.DeepEqual(credentials, []map[string]string{{"Administrator": "hp-default"}}) {
+		t.Errorf("Expected just the vendor credential, got %v", credentials)
+	}
+
+	credentials = r.Credentials(&asset.Asset{Serial: "ABC123", Vendor: "dell"})
+	if !reflect.DeepEqual(credentials, declared) {
+		t.Errorf("Expected the declared credentials, got %v", credentials)
+	}
+}
+
+// TestFingerprint tests fingerprints are salted with the asset serial.
+func TestFingerprint(t *testing.T) {
+
+	credential := map[string]string{"Administrator": "hunter2"}
+	if Fingerprint("CZ1234", credential) != Fingerprint("CZ1234", map[string]string{"Administrator": "hunter2"}) {
+		t.Error("Expected the same fingerprint for the same serial, credential")
+	}
+
+	if Fingerprint("CZ1234", credential) == Fingerprint("ABC123", credential) {
+		t.Error("Expected fingerprints to differ across serials")
+	}
+}
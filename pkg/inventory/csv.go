@@ -4,8 +4,9 @@ package inventory
 // to use this source, set source: csv in bmcbutler.yml
 
 import (
+	"errors"
+	"fmt"
 	"os"
-	"strings"
 
 	"github.com/gocarina/gocsv"
 	"github.com/sirupsen/logrus"
@@ -14,78 +15,65 @@ import (
 	"github.com/bmc-toolbox/bmcbutler/pkg/config"
 )
 
+func init() {
+	Register("csv", func(c *config.Params, log *logrus.Logger) (Source, error) {
+		if c.Inventory.Csv == nil || c.Inventory.Csv.File == "" {
+			return nil, errors.New("csv inventory source requires the csv file to be declared")
+		}
+
+		return &Csv{Config: c, Log: log}, nil
+	})
+}
+
 // Csv inventory struct holds attributes required to read in assets from a csv file.
 type Csv struct {
-	Config          *config.Params
-	Log             *logrus.Logger
-	BatchSize       int //number of inventory assets to return per iteration
-	AssetsChan      chan<- []asset.Asset
-	FilterAssetType []string
+	Config *config.Params
+	Log    *logrus.Logger
 }
 
 // CsvAsset struct holds attributes of an asset listed in a csv file.
 type CsvAsset struct {
 	BmcAddress string `csv:"bmcaddress"`
-	Serial     string `csv:"serial"` //optional
-	Vendor     string `csv:"vendor"` //optional
-	Type       string `csv:"type"`   //optional
+	Serial     string `csv:"serial"`  //optional
+	Vendor     string `csv:"vendor"`  //optional
+	Type       string `csv:"type"`    //optional
+	Chassis    string `csv:"chassis"` //optional, the parent chassis of a blade.
 }
 
-func (c *Csv) readCsv() []*CsvAsset {
+// SetsChassis returns true, the chassis of blades is read from the chassis column.
+func (c *Csv) SetsChassis() bool {
+	return true
+}
 
-	log := c.Log
+func (c *Csv) readCsv() ([]*CsvAsset, error) {
 
 	var csvAssets []*CsvAsset
 	csvFile, err := os.Open(c.Config.Inventory.Csv.File)
 	if err != nil {
-		log.Error("Error: ", err)
-		os.Exit(1)
+		return csvAssets, err
 	}
 
+	defer csvFile.Close()
+
 	err = gocsv.UnmarshalFile(csvFile, &csvAssets)
 	if err != nil {
-		log.Error("Error: ", err)
-		os.Exit(1)
+		return csvAssets, fmt.Errorf("unable to read csv inventory %s: %s", c.Config.Inventory.Csv.File, err)
 	}
 
-	return csvAssets
+	return csvAssets, nil
 }
 
-//AssetRetrieve looks at c.Config.FilterParams
-//and returns the appropriate function that will retrieve assets.
-func (c *Csv) AssetRetrieve() func() {
-
-	//setup the asset types we want to retrieve data for.
-	switch {
-	case c.Config.FilterParams.Chassis:
-		c.FilterAssetType = append(c.FilterAssetType, "chassis")
-	case c.Config.FilterParams.Servers:
-		c.FilterAssetType = append(c.FilterAssetType, "servers")
-	case !c.Config.FilterParams.Chassis && !c.Config.FilterParams.Servers:
-		c.FilterAssetType = []string{"chassis", "servers"}
-	}
-
-	//Based on the filter param given, return the asset iterator method.
-	switch {
-	case c.Config.FilterParams.Serials != "":
-		return c.AssetIterBySerial
-	case c.Config.FilterParams.Ips != "":
-		return c.AssetIterByIP
-	default:
-		return c.AssetIter
-	}
-
-}
-
-// AssetIterBySerial iterates over Assets and passes on the inventory channel.
-func (c *Csv) AssetIterBySerial() {
+// BySerial looks up the assets with the given serials in the csv file.
+func (c *Csv) BySerial(serials []string) ([]asset.Asset, error) {
 
 	log := c.Log
-	csvAssets := c.readCsv()
+	csvAssets, err := c.readCsv()
+	if err != nil {
+		return nil, err
+	}
 
-	serials := c.Config.FilterParams.Serials
 	assets := make([]asset.Asset, 0)
-	for _, serial := range strings.Split(serials, ",") {
+	for _, serial := range serials {
 
 		log.Debug("Fetching asset from csv by serial: ", serial)
 		for _, item := range csvAssets {
@@ -98,33 +86,29 @@ func (c *Csv) AssetIterBySerial() {
 
 			if item.Serial == serial {
 				assets = append(assets, asset.Asset{IPAddresses: []string{item.BmcAddress},
-					Serial: item.Serial,
-					Vendor: item.Vendor,
-					Type:   item.Type})
+					Serial:  item.Serial,
+					Vendor:  item.Vendor,
+					Type:    item.Type,
+					Chassis: item.Chassis})
 			}
 		}
 	}
 
-	//pass the asset to the channel
-	c.AssetsChan <- assets
-	close(c.AssetsChan)
-
+	return assets, nil
 }
 
-// AssetIterByIP reads in list of ips passed in via cli,
-// attempts to lookup any attributes for the IP in the inventory,
-// and sends an asset for each attribute over the asset channel
-func (c *Csv) AssetIterByIP() {
-
-	defer close(c.AssetsChan)
-
-	csvAssets := c.readCsv()
+// ByIP attempts to lookup any attributes for the given IPs in the csv file,
+// and returns an asset for each IP.
+func (c *Csv) ByIP(ips []string) ([]asset.Asset, error) {
 
-	ips := c.Config.FilterParams.Ips
+	csvAssets, err := c.readCsv()
+	if err != nil {
+		return nil, err
+	}
 
 	//query csv inventory for asset attributes
 	assets := make([]asset.Asset, 0)
-	for _, ip := range strings.Split(ips, ",") {
+	for _, ip := range ips {
 
 		a := asset.Asset{IPAddresses: []string{ip}}
 
@@ -142,6 +126,7 @@ func (c *Csv) AssetIterByIP() {
 				a.Serial = item.Serial
 				a.Vendor = item.Vendor
 				a.Type = item.Type
+				a.Chassis = item.Chassis
 
 			}
 		}
@@ -149,14 +134,17 @@ func (c *Csv) AssetIterByIP() {
 		assets = append(assets, a)
 	}
 
-	c.AssetsChan <- assets
+	return assets, nil
 }
 
-// AssetIter reads in assets and passes them to the inventory channel.
-func (c *Csv) AssetIter() {
+// All reads in assets from the csv file and passes them to the batch func,
+// filtering by asset type and location is left to the caller.
+func (c *Csv) All(filter Filter, batch func([]asset.Asset) bool) error {
 
-	//Asset needs to be an inventory asset
-	csvAssets := c.readCsv()
+	csvAssets, err := c.readCsv()
+	if err != nil {
+		return err
+	}
 
 	assets := make([]asset.Asset, 0)
 	for _, item := range csvAssets {
@@ -170,12 +158,14 @@ func (c *Csv) AssetIter() {
 		}
 
 		assets = append(assets, asset.Asset{IPAddresses: []string{item.BmcAddress},
-			Serial: item.Serial,
-			Vendor: item.Vendor,
-			Type:   item.Type})
+			Serial:  item.Serial,
+			Vendor:  item.Vendor,
+			Type:    item.Type,
+			Chassis: item.Chassis})
 
 	}
 
-	c.AssetsChan <- assets
-	close(c.AssetsChan)
+	batch(assets)
+
+	return nil
 }
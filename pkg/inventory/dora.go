@@ -16,6 +16,7 @@ package inventory
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -23,18 +24,26 @@ import (
 
 	"github.com/bmc-toolbox/bmcbutler/pkg/asset"
 	"github.com/bmc-toolbox/bmcbutler/pkg/config"
-	metrics "github.com/bmc-toolbox/gin-go-metrics"
+	"github.com/bmc-toolbox/bmcbutler/pkg/metrics"
 	"github.com/sirupsen/logrus"
 )
 
+func init() {
+	Register("dora", func(c *config.Params, log *logrus.Logger) (Source, error) {
+		if c.Inventory.Dora == nil || c.Inventory.Dora.URL == "" {
+			return nil, errors.New("dora inventory source requires the dora URL to be declared")
+		}
+
+		return &Dora{Config: c, Log: log, BatchSize: 10}, nil
+	})
+}
+
 // Dora struct holds attributes required to retrieve assets from Dora,
 // and pass them to the butlers.
 type Dora struct {
-	Log             *logrus.Logger
-	BatchSize       int
-	AssetsChan      chan<- []asset.Asset
-	Config          *config.Params
-	FilterAssetType []string
+	Log       *logrus.Logger
+	BatchSize int
+	Config    *config.Params
 }
 
 // DoraAssetAttributes struct is used to unmarshal Dora data.
@@ -64,11 +73,39 @@ type DoraAsset struct {
 	Links DoraLinks       `json:"links"`
 }
 
+// query queries the given Dora URL and unmarshals the response into the DoraAsset.
+func (d *Dora) query(queryURL string, doraAssets *DoraAsset) error {
+
+	resp, err := http.Get(queryURL)
+	if err != nil {
+		return fmt.Errorf("error querying dora %s: %s", queryURL, err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("error querying dora %s: status code %d", queryURL, resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error querying dora %s: %s", queryURL, err)
+	}
+
+	err = json.Unmarshal(body, doraAssets)
+	if err != nil {
+		return fmt.Errorf("unable to unmarshal data returned from dora %s: %s", queryURL, err)
+	}
+
+	return nil
+}
+
 // for a list of assets, update its location value
 func (d *Dora) setLocation(doraInventoryAssets []asset.Asset) (err error) {
 
-	component := "inventory"
-	log := d.Log
+	if len(doraInventoryAssets) == 0 {
+		return nil
+	}
 
 	apiURL := d.Config.Inventory.Dora.URL
 	queryURL := fmt.Sprintf("%s/v1/scanned_ports?filter[port]=22&filter[ip]=", apiURL)
@@ -81,28 +118,10 @@ func (d *Dora) setLocation(doraInventoryAssets []asset.Asset) (err error) {
 	}
 
 	queryURL += strings.Join(ips, ",")
-	resp, err := http.Get(queryURL)
-	if err != nil || resp.StatusCode != 200 {
-		log.WithFields(logrus.Fields{
-			"component":   component,
-			"url":         queryURL,
-			"error":       err,
-			"Status code": resp.StatusCode,
-		}).Warn("Unable to query Dora for IP location info.")
-		return err
-	}
-
-	body, _ := ioutil.ReadAll(resp.Body)
-	resp.Body.Close()
 
 	var doraScannedPortAssets DoraAsset
-	err = json.Unmarshal(body, &doraScannedPortAssets)
+	err = d.query(queryURL, &doraScannedPortAssets)
 	if err != nil {
-		log.WithFields(logrus.Fields{
-			"component": component,
-			"url":       queryURL,
-			"error":     err,
-		}).Warn("Unable to unmarshal Dora scanned IP info.")
 		return err
 	}
 
@@ -115,91 +134,75 @@ func (d *Dora) setLocation(doraInventoryAssets []asset.Asset) (err error) {
 		}
 	}
 
-	return err
+	return nil
 }
 
-//AssetRetrieve looks at d.Config.FilterParams
-//and returns the appropriate function that will retrieve assets.
-func (d *Dora) AssetRetrieve() func() {
-
-	//setup the asset types we want to retrieve data for.
-	switch {
-	case d.Config.FilterParams.Chassis:
-		d.FilterAssetType = append(d.FilterAssetType, "chassis")
-	case d.Config.FilterParams.Servers:
-		d.FilterAssetType = append(d.FilterAssetType, "blade")
-		d.FilterAssetType = append(d.FilterAssetType, "discrete")
-	case !d.Config.FilterParams.Chassis && !d.Config.FilterParams.Servers:
-		d.FilterAssetType = []string{"chassis", "blade", "discrete"}
-	}
+// doraPaths returns the dora query paths and asset types for the filter asset types,
+// since these asset types in dora are plural.
+func doraPaths(assetTypes []string) map[string]string {
 
-	//Based on the filter param given, return the asset iterator method.
-	switch {
-	case d.Config.FilterParams.Serials != "":
-		return d.AssetIterBySerial
-	default:
-		return d.AssetIter
+	paths := make(map[string]string)
+	for _, assetType := range assetTypes {
+		switch assetType {
+		case Chassis:
+			paths["chassis"] = "chassis"
+		case Servers:
+			paths["blades"] = "blade"
+			paths["discretes"] = "discrete"
+		}
 	}
 
+	return paths
 }
 
-// AssetIterBySerial is an iterator method,
-// to retrieve assets from Dora by the given serial numbers,
-// assets are then sent over the inventory channel.
-func (d *Dora) AssetIterBySerial() {
-
-	serials := d.Config.FilterParams.Serials
-	apiURL := d.Config.Inventory.Dora.URL
+// doraAssets returns the assets for the dora asset data,
+// assets with no bmc address are logged and skipped.
+func (d *Dora) doraAssets(data []DoraAssetData, assetType string) []asset.Asset {
 
 	component := "inventory"
-
 	log := d.Log
-	defer close(d.AssetsChan)
 
-	for _, assetType := range d.FilterAssetType {
-		var path string
-		//setup the right dora query path
-		switch assetType {
-		case "blade":
-			path = "blades"
-		case "discrete":
-			path = "discretes"
-		default:
-			path = assetType
-		}
-
-		queryURL := fmt.Sprintf("%s/v1/%s?filter[serial]=", apiURL, path)
-		queryURL += strings.ToLower(serials)
-		assets := make([]asset.Asset, 0)
-
-		resp, err := http.Get(queryURL)
-		if err != nil {
+	assets := make([]asset.Asset, 0)
+	for _, item := range data {
+		if item.Attributes.BmcAddress == "" || item.Attributes.BmcAddress == "0.0.0.0" {
 			log.WithFields(logrus.Fields{
 				"component": component,
-				"url":       queryURL,
-				"error":     err,
-			}).Fatal("Failed to query dora for serial(s).")
-		}
+				"DoraAsset": fmt.Sprintf("%+v", item),
+			}).Warn("Asset location could not be determined, since the asset has no IP.")
 
-		body, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			log.WithFields(logrus.Fields{
-				"component": component,
-				"url":       queryURL,
-				"error":     err,
-			}).Fatal("Failed to query dora for serial(s).")
+			metrics.IncrCounter([]string{"inventory", "assets_noip_dora"}, 1)
+			continue
 		}
-		resp.Body.Close()
+
+		assets = append(assets,
+			asset.Asset{IPAddress: item.Attributes.BmcAddress,
+				IPAddresses: []string{item.Attributes.BmcAddress},
+				Serial:      item.Attributes.Serial,
+				Vendor:      item.Attributes.Vendor,
+				Type:        assetType})
+	}
+
+	return assets
+}
+
+// BySerial retrieves assets from Dora by the given serial numbers.
+func (d *Dora) BySerial(serials []string) ([]asset.Asset, error) {
+
+	apiURL := d.Config.Inventory.Dora.URL
+	component := "inventory"
+	log := d.Log
+
+	assets := make([]asset.Asset, 0)
+	for path, assetType := range doraPaths([]string{Chassis, Servers}) {
+
+		queryURL := fmt.Sprintf("%s/v1/%s?filter[serial]=", apiURL, path)
+		queryURL += strings.ToLower(strings.Join(serials, ","))
 
 		//dora returns a list of assets
 		var doraAssets DoraAsset
-		err = json.Unmarshal(body, &doraAssets)
+		err := d.query(queryURL, &doraAssets)
 		if err != nil {
-			log.WithFields(logrus.Fields{
-				"component": component,
-				"url":       queryURL,
-				"error":     err,
-			}).Fatal("Unable to unmarshal data returned from dora.")
+			return assets, err
 		}
 
 		if len(doraAssets.Data) == 0 {
@@ -208,130 +211,65 @@ func (d *Dora) AssetIterBySerial() {
 				"Query url": queryURL,
 			}).Debug("Asset was not located in dora inventory.")
 			continue
-		} else {
-			log.WithFields(logrus.Fields{
-				"component": component,
-				"Query url": queryURL,
-			}).Debug("Asset located in dora inventory.")
 		}
 
-		for _, item := range doraAssets.Data {
-			if item.Attributes.BmcAddress == "" {
-				log.WithFields(logrus.Fields{
-					"component": component,
-					"DoraAsset": fmt.Sprintf("%+v", item),
-				}).Warn("Asset location could not be determined, since the asset has no IP.")
-				continue
-			}
+		log.WithFields(logrus.Fields{
+			"component": component,
+			"Query url": queryURL,
+		}).Debug("Asset located in dora inventory.")
 
-			assets = append(assets, asset.Asset{IPAddress: item.Attributes.BmcAddress,
-				Serial: item.Attributes.Serial,
-				Vendor: item.Attributes.Vendor,
-				Type:   assetType})
+		assets = append(assets, d.doraAssets(doraAssets.Data, assetType)...)
+	}
 
-		}
+	//set the location for the assets
+	err := d.setLocation(assets)
+	if err != nil {
+		return nil, fmt.Errorf("unable to determine location of assets: %s", err)
+	}
 
-		//set the location for the assets
-		err = d.setLocation(assets)
-		if err != nil {
-			log.WithFields(logrus.Fields{
-				"component": component,
-				"Error":     err,
-			}).Warn("Unable to determine location of assets.")
-			return
-		}
+	return assets, nil
+}
+
+// ByIP returns an asset for each of the given IPs,
+// with the location of the IP looked up in Dora.
+func (d *Dora) ByIP(ips []string) ([]asset.Asset, error) {
 
-		//pass the asset to the channel
-		d.AssetsChan <- assets
+	assets := make([]asset.Asset, 0)
+	for _, ip := range ips {
+		assets = append(assets, asset.Asset{IPAddress: ip, IPAddresses: []string{ip}})
 	}
-}
 
-// AssetIter routine that returns data to iter over
-func (d *Dora) AssetIter() {
+	err := d.setLocation(assets)
+	if err != nil {
+		return nil, fmt.Errorf("unable to determine location of assets: %s", err)
+	}
 
-	//Asset needs to be an inventory asset
-	//Iter stuffs assets into an array of Assets
-	//Iter writes the assets array to the channel
+	return assets, nil
+}
+
+// All retrieves assets of the filter asset types from Dora,
+// a batch of assets is passed to the batch func for each page of assets.
+func (d *Dora) All(filter Filter, batch func([]asset.Asset) bool) error {
 
 	apiURL := d.Config.Inventory.Dora.URL
 	component := "retrieveInventoryAssetsDora"
-
-	defer close(d.AssetsChan)
-	//defer metrics.MeasureSince(component, time.Now())
-
 	log := d.Log
 
-	for _, assetType := range d.FilterAssetType {
-		var path string
-
-		//since this asset type in dora is plural.
-		if assetType == "blade" {
-			path = "blades"
-		} else if assetType == "discrete" {
-			path = "discretes"
-		} else {
-			path = assetType
-		}
+	for path, assetType := range doraPaths(filter.AssetTypes) {
 
 		queryURL := fmt.Sprintf("%s/v1/%s?page[offset]=%d&page[limit]=%d", apiURL, path, 0, d.BatchSize)
 		for {
-			assets := make([]asset.Asset, 0)
-
-			resp, err := http.Get(queryURL)
-			if err != nil || resp.StatusCode != 200 {
-				log.WithFields(logrus.Fields{
-					"component": component,
-					"url":       queryURL,
-					"error":     err,
-				}).Fatal("Error querying Dora for assets.")
-			}
-
-			body, err := ioutil.ReadAll(resp.Body)
-			if err != nil {
-				log.WithFields(logrus.Fields{
-					"component": component,
-					"url":       queryURL,
-					"error":     err,
-				}).Fatal("Error querying Dora for assets.")
-			}
-			resp.Body.Close()
-
 			var doraAssets DoraAsset
-			err = json.Unmarshal(body, &doraAssets)
+			err := d.query(queryURL, &doraAssets)
 			if err != nil {
-				log.WithFields(logrus.Fields{
-					"component": component,
-					"url":       queryURL,
-					"error":     err,
-				}).Fatal("Error unmarshaling data returned from Dora.")
+				return err
 			}
 
 			metrics.IncrCounter(
 				[]string{"inventory", "assets_fetched_dora"},
 				int64(len(doraAssets.Data)))
 
-			// for each asset, get its location
-			// store in the assets slice
-			// if an asset has no bmcAddress we log and skip it.
-			for _, item := range doraAssets.Data {
-
-				if item.Attributes.BmcAddress == "" || item.Attributes.BmcAddress == "0.0.0.0" {
-					log.WithFields(logrus.Fields{
-						"component": component,
-						"DoraAsset": fmt.Sprintf("%+v", item),
-					}).Warn("Asset location could not be determined, since the asset has no IP.")
-
-					metrics.IncrCounter([]string{"inventory", "assets_noip_dora"}, 1)
-					continue
-				}
-
-				assets = append(assets,
-					asset.Asset{IPAddress: item.Attributes.BmcAddress,
-						Serial: item.Attributes.Serial,
-						Vendor: item.Attributes.Vendor,
-						Type:   assetType})
-
-			}
+			assets := d.doraAssets(doraAssets.Data, assetType)
 
 			//set the location for the assets
 			err = d.setLocation(assets)
@@ -343,16 +281,17 @@ func (d *Dora) AssetIter() {
 				}).Warn("Asset location could not be determined, ignoring assets")
 
 				metrics.IncrCounter([]string{"inventory", "assets_nolocation_dora"}, 1)
-				continue
+			} else {
+				metrics.IncrCounter(
+					[]string{"inventory", "assets_returned_dora"},
+					int64(len(assets)))
+
+				//pass the assets on
+				if !batch(assets) {
+					return nil
+				}
 			}
 
-			metrics.IncrCounter(
-				[]string{"inventory", "assets_returned_dora"},
-				int64(len(assets)))
-
-			//pass the asset to the channel
-			d.AssetsChan <- assets
-
 			// if we reached the end of dora assets
 			if doraAssets.Links.Next == "" {
 				log.WithFields(logrus.Fields{
@@ -366,4 +305,6 @@ func (d *Dora) AssetIter() {
 			queryURL = fmt.Sprintf("%s%s", apiURL, doraAssets.Links.Next)
 		}
 	}
+
+	return nil
 }
@@ -2,6 +2,7 @@ package inventory
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os/exec"
 	"strconv"
@@ -11,18 +12,25 @@ import (
 
 	"github.com/bmc-toolbox/bmcbutler/pkg/asset"
 	"github.com/bmc-toolbox/bmcbutler/pkg/config"
-	metrics "github.com/bmc-toolbox/gin-go-metrics"
+	"github.com/bmc-toolbox/bmcbutler/pkg/metrics"
 	"github.com/sirupsen/logrus"
 )
 
+func init() {
+	Register("enc", func(c *config.Params, log *logrus.Logger) (Source, error) {
+		if c.Inventory.Enc == nil || c.Inventory.Enc.Bin == "" {
+			return nil, errors.New("enc inventory source requires the enc bin to be declared")
+		}
+
+		return &Enc{Config: c, Log: log, BatchSize: 10}, nil
+	})
+}
+
 // Enc struct holds attributes required to run inventory/enc methods.
 type Enc struct {
-	Log             *logrus.Logger
-	BatchSize       int
-	AssetsChan      chan<- []asset.Asset
-	Config          *config.Params
-	FilterAssetType []string
-	StopChan        <-chan struct{}
+	Log       *logrus.Logger
+	BatchSize int
+	Config    *config.Params
 }
 
 // AssetAttributes is used to unmarshal data returned from an ENC.
@@ -100,31 +108,6 @@ func AttributesExtrasAsMap(attributeExtras *AttributesExtras) (extras map[string
 	return extras
 }
 
-//AssetRetrieve looks at c.Config.FilterParams
-//and returns the appropriate function that will retrieve assets.
-func (e *Enc) AssetRetrieve() func() {
-
-	//setup the asset types we want to retrieve data for.
-	switch {
-	case e.Config.FilterParams.Chassis:
-		e.FilterAssetType = append(e.FilterAssetType, "chassis")
-	case e.Config.FilterParams.Servers:
-		e.FilterAssetType = append(e.FilterAssetType, "servers")
-	case !e.Config.FilterParams.Chassis && !e.Config.FilterParams.Servers:
-		e.FilterAssetType = []string{"chassis", "servers"}
-	}
-
-	//Based on the filter param given, return the asset iterator method.
-	switch {
-	case e.Config.FilterParams.Serials != "":
-		return e.AssetIterBySerial
-	case e.Config.FilterParams.Ips != "":
-		return e.AssetIterByIP
-	default:
-		return e.AssetIter
-	}
-}
-
 // ExecCmd executes the executable with the given args and returns
 // if retry is declared, the command is retried for the given number with an interval of 10 seconds,
 // the response as a slice of bytes, and the error if any.
@@ -174,7 +157,7 @@ func (e *Enc) SetChassisInstalled(serials string) {
 }
 
 // nolint: gocyclo
-func (e *Enc) encQueryBySerial(serials string) (assets []asset.Asset) {
+func (e *Enc) encQueryBySerial(serials string) (assets []asset.Asset, err error) {
 
 	log := e.Log
 	component := "encQueryBySerial"
@@ -185,23 +168,15 @@ func (e *Enc) encQueryBySerial(serials string) (assets []asset.Asset) {
 	encBin := e.Config.Inventory.Enc.Bin
 	out, err := ExecCmd(encBin, cmdArgs, 0)
 	if err != nil {
-		log.WithFields(logrus.Fields{
-			"component": component,
-			"error":     err,
-			"cmd":       fmt.Sprintf("%s %s", encBin, strings.Join(cmdArgs, " ")),
-			"output":    fmt.Sprintf("%s", out),
-		}).Fatal("Inventory query failed, lookup command returned error.")
+		return assets, fmt.Errorf("inventory query failed, lookup command %s %s returned error: %s, output: %s",
+			encBin, strings.Join(cmdArgs, " "), err, out)
 	}
 
 	cmdResp := AssetAttributes{}
 	err = json.Unmarshal(out, &cmdResp)
 	if err != nil {
-		log.WithFields(logrus.Fields{
-			"component": component,
-			"error":     err,
-			"cmd":       fmt.Sprintf("%s %s", encBin, strings.Join(cmdArgs, " ")),
-			"output":    fmt.Sprintf("%s", out),
-		}).Fatal("JSON Unmarshal command response returned error.")
+		return assets, fmt.Errorf("JSON Unmarshal lookup command %s %s response returned error: %s",
+			encBin, strings.Join(cmdArgs, " "), err)
 	}
 
 	if len(cmdResp.Data) == 0 {
@@ -210,7 +185,7 @@ func (e *Enc) encQueryBySerial(serials string) (assets []asset.Asset) {
 			"Serial(s)": serials,
 		}).Warn("No assets returned by inventory for given serial(s).")
 
-		return []asset.Asset{}
+		return []asset.Asset{}, nil
 	}
 
 	missingSerials := strings.Split(serials, ",")
@@ -248,11 +223,11 @@ func (e *Enc) encQueryBySerial(serials string) (assets []asset.Asset) {
 
 	metrics.IncrCounter([]string{"inventory", "assets_fetched_enc"}, int64(len(assets)))
 
-	return assets
+	return assets, nil
 }
 
 // nolint: gocyclo
-func (e *Enc) encQueryByIP(ips string) (assets []asset.Asset) {
+func (e *Enc) encQueryByIP(ips string) (assets []asset.Asset, err error) {
 
 	log := e.Log
 	component := "encQueryByIP"
@@ -280,18 +255,14 @@ func (e *Enc) encQueryByIP(ips string) (assets []asset.Asset) {
 		}).Warn("Inventory query failed, lookup command returned error.")
 
 		populateAssetsWithNoAttributes()
-		return assets
+		return assets, nil
 	}
 
 	cmdResp := AssetAttributes{}
 	err = json.Unmarshal(out, &cmdResp)
 	if err != nil {
-		log.WithFields(logrus.Fields{
-			"component": component,
-			"error":     err,
-			"cmd":       fmt.Sprintf("%s %s", encBin, strings.Join(cmdArgs, " ")),
-			"output":    fmt.Sprintf("%s", out),
-		}).Fatal("JSON Unmarshal command response returned error.")
+		return assets, fmt.Errorf("JSON Unmarshal lookup command %s %s response returned error: %s",
+			encBin, strings.Join(cmdArgs, " "), err)
 	}
 
 	if len(cmdResp.Data) == 0 {
@@ -301,7 +272,7 @@ func (e *Enc) encQueryByIP(ips string) (assets []asset.Asset) {
 		}).Debug("No assets returned by inventory for given IP(s).")
 
 		populateAssetsWithNoAttributes()
-		return assets
+		return assets, nil
 	}
 
 	// missing IPs are IPs we looked up using the enc and got no data for.
@@ -341,16 +312,13 @@ func (e *Enc) encQueryByIP(ips string) (assets []asset.Asset) {
 
 	metrics.IncrCounter([]string{"inventory", "assets_fetched_enc"}, int64(len(assets)))
 
-	return assets
+	return assets, nil
 }
 
 // encQueryByOffset returns a slice of assets and if the query reached the end of assets.
 // assetType is one of 'servers/chassis'
 // location is a comma delimited list of locations
-func (e *Enc) encQueryByOffset(assetType string, offset int, limit int, location string) (assets []asset.Asset, endOfAssets bool) {
-
-	component := "EncQueryByOffset"
-	log := e.Log
+func (e *Enc) encQueryByOffset(assetType string, offset int, limit int, location string) (assets []asset.Asset, endOfAssets bool, err error) {
 
 	assets = make([]asset.Asset, 0)
 
@@ -379,29 +347,21 @@ func (e *Enc) encQueryByOffset(assetType string, offset int, limit int, location
 	encBin := e.Config.Inventory.Enc.Bin
 	out, err := ExecCmd(encBin, cmdArgs, 3)
 	if err != nil {
-		log.WithFields(logrus.Fields{
-			"component": component,
-			"error":     err,
-			"cmd":       fmt.Sprintf("%s %s", encBin, strings.Join(cmdArgs, " ")),
-			"output":    fmt.Sprintf("%s", out),
-		}).Fatal("Inventory query failed, lookup command returned error.")
+		return assets, false, fmt.Errorf("inventory query failed, lookup command %s %s returned error: %s, output: %s",
+			encBin, strings.Join(cmdArgs, " "), err, out)
 	}
 
 	cmdResp := AssetAttributes{}
 	err = json.Unmarshal(out, &cmdResp)
 	if err != nil {
-		log.WithFields(logrus.Fields{
-			"component": component,
-			"error":     err,
-			"cmd":       fmt.Sprintf("%s %s", encBin, strings.Join(cmdArgs, " ")),
-			"output":    fmt.Sprintf("%s", out),
-		}).Fatal("JSON Unmarshal command response returned error.")
+		return assets, false, fmt.Errorf("JSON Unmarshal lookup command %s %s response returned error: %s",
+			encBin, strings.Join(cmdArgs, " "), err)
 	}
 
 	endOfAssets = cmdResp.EndOfAssets
 
 	if len(cmdResp.Data) == 0 {
-		return []asset.Asset{}, endOfAssets
+		return []asset.Asset{}, endOfAssets, nil
 	}
 
 	for serial, attributes := range cmdResp.Data {
@@ -424,92 +384,65 @@ func (e *Enc) encQueryByOffset(assetType string, offset int, limit int, location
 
 	metrics.IncrCounter([]string{"inventory", "assets_fetched_enc"}, int64(len(assets)))
 
-	return assets, endOfAssets
+	return assets, endOfAssets, nil
 }
 
-// AssetIter fetches assets and sends them over the asset channel.
-func (e *Enc) AssetIter() {
-
-	//Asset needs to be an inventory asset
-	//Iter stuffs assets into an array of Assets
-	//Iter writes the assets array to the channel
-	//component := "AssetIterEnc"
-
-	var interrupt bool
-
-	go func() { <-e.StopChan; interrupt = true }()
+// All fetches assets of the filter asset types and locations,
+// and passes each batch of assets to the batch func.
+func (e *Enc) All(filter Filter, batch func([]asset.Asset) bool) error {
 
-	defer close(e.AssetsChan)
-	//defer d.MetricsEmitter.MeasureSince(component, time.Now())
-
-	locations := strings.Join(e.Config.Locations, ",")
-	for _, assetType := range e.FilterAssetType {
+	locations := strings.Join(filter.Locations, ",")
+	for _, assetType := range filter.AssetTypes {
 
 		var limit = e.BatchSize
 		var offset = 0
 
 		for {
-			var endOfAssets bool
-
-			assets, endOfAssets := e.encQueryByOffset(assetType, offset, limit, locations)
+			assets, endOfAssets, err := e.encQueryByOffset(assetType, offset, limit, locations)
+			if err != nil {
+				return err
+			}
 
 			e.Log.WithFields(logrus.Fields{
 				"component": "inventory",
-				"method":    "AssetIter",
+				"method":    "All",
 				"Asset":     assetType,
 				"Offset":    offset,
 				"Limit":     limit,
 				"locations": locations,
 			}).Debug("Assets retrieved.")
 
-			//pass the asset to the channel
-			e.AssetsChan <- assets
+			//pass the assets on, stop if an interrupt was received.
+			if !batch(assets) {
+				return nil
+			}
 
 			//increment offset for next set of assets
 			offset += limit
 
 			//If the ENC indicates we've reached the end of assets
-			if endOfAssets || interrupt {
+			if endOfAssets {
 
 				e.Log.WithFields(logrus.Fields{
 					"component": "inventory",
-					"method":    "AssetIter",
-				}).Debug("Reached end of assets/interrupt received.")
+					"method":    "All",
+				}).Debug("Reached end of assets.")
 				break
 			}
 		} // endless for
 	} // for each assetType
-}
-
-// AssetIterBySerial reads in list of serials passed in via cli,
-// queries the ENC for the serials, passes them to the assets channel
-func (e *Enc) AssetIterBySerial() {
-
-	defer close(e.AssetsChan)
 
-	//get serials passed in via cli - they need to be comma separated
-	serials := e.Config.FilterParams.Serials
-
-	//query ENC for given serials
-	assets := e.encQueryBySerial(serials)
+	return nil
+}
 
-	//pass assets returned by ENC to the assets channel
-	e.AssetsChan <- assets
+// BySerial queries the ENC for the given serials,
+// serials the ENC returned no data for are returned as assets with no IPs.
+func (e *Enc) BySerial(serials []string) ([]asset.Asset, error) {
+	return e.encQueryBySerial(strings.Join(serials, ","))
 }
 
-// AssetIterByIP reads in list of ips passed in via cli,
-// queries the ENC for attributes related to the, passes them to the assets channel
+// ByIP queries the ENC for attributes related to the given IPs,
 // if no attributes for a given IP are returned, an asset with just the IP is returned.
-func (e *Enc) AssetIterByIP() {
-
-	defer close(e.AssetsChan)
-
-	//get ips passed in via cli - they need to be comma separated
-	ips := e.Config.FilterParams.Ips
-
-	//query ENC for given serials
-	assets := e.encQueryByIP(ips)
-
-	//pass assets returned by ENC to the assets channel
-	e.AssetsChan <- assets
+func (e *Enc) ByIP(ips []string) ([]asset.Asset, error) {
+	return e.encQueryByIP(strings.Join(ips, ","))
 }
@@ -84,10 +84,13 @@ func TestEncQueryBySerial(t *testing.T) {
 
 	enc := Enc{
 		Log:    logrus.New(),
-		Config: &config.Params{Inventory: &config.Inventory{Source: assetLookup}},
+		Config: &config.Params{Inventory: &config.Inventory{Enc: &config.Enc{Bin: assetLookup}}},
 	}
 
-	assets := enc.encQueryBySerial(strings.Join(serials, ","))
+	assets, err := enc.encQueryBySerial(strings.Join(serials, ","))
+	if err != nil {
+		t.Fatalf("Expected assets to be returned, got error: %s", err)
+	}
 	if len(assets) < 2 {
 		t.Fatalf("Expected two assets to be returned, got %d", len(assets))
 	}
@@ -1,7 +1,7 @@
 package inventory
 
 import (
-	"strings"
+	"errors"
 
 	"github.com/sirupsen/logrus"
 
@@ -9,33 +9,36 @@ import (
 	"github.com/bmc-toolbox/bmcbutler/pkg/config"
 )
 
-// IPList struct is in inventory source,
-// this struct holds attributes to setup the IP list source.
-type IPList struct {
-	Log       *logrus.Logger
-	BatchSize int                  //number of inventory assets to return per iteration
-	Channel   chan<- []asset.Asset //the channel to send inventory assets over
-	Config    *config.Params       //bmcbutler config
+func init() {
+	Register("iplist", func(c *config.Params, log *logrus.Logger) (Source, error) {
+		return &IPList{Config: c, Log: log}, nil
+	})
 }
 
-//AssetRetrieve looks at d.Config.FilterParams
-//and returns the appropriate function that will retrieve assets.
-func (i *IPList) AssetRetrieve() func() {
-	return i.AssetIter
+// IPList struct is in inventory source,
+// the assets are the IPs passed in through the --ips flag.
+type IPList struct {
+	Log    *logrus.Logger
+	Config *config.Params //bmcbutler config
 }
 
-// AssetIter is an iterator method that sends assets to configure
-// over the inventory channel.
-func (i *IPList) AssetIter() {
-	ips := strings.Split(i.Config.FilterParams.Ips, ",")
+// ByIP returns an asset for each of the given IPs.
+func (i *IPList) ByIP(ips []string) ([]asset.Asset, error) {
 
 	assets := make([]asset.Asset, 0)
 	for _, ip := range ips {
-		assets = append(assets, asset.Asset{IPAddress: ip})
+		assets = append(assets, asset.Asset{IPAddress: ip, IPAddresses: []string{ip}})
 	}
 
-	//pass the asset to the channel
-	i.Channel <- assets
-	close(i.Channel)
+	return assets, nil
+}
+
+// BySerial is not supported by the IP list source.
+func (i *IPList) BySerial(serials []string) ([]asset.Asset, error) {
+	return nil, errors.New("iplist inventory source requires assets to be passed in with --ips")
+}
 
+// All is not supported by the IP list source.
+func (i *IPList) All(filter Filter, batch func([]asset.Asset) bool) error {
+	return errors.New("iplist inventory source requires assets to be passed in with --ips")
 }
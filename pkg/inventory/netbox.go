@@ -0,0 +1,411 @@
+package inventory
+
+// NetBox inventory source, assets are retrieved from the NetBox dcim/devices API.
+// to use this source, declare the netbox section under inventory in bmcbutler.yml
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/asset"
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+	"github.com/bmc-toolbox/bmcbutler/pkg/metrics"
+)
+
+func init() {
+	Register("netbox", func(c *config.Params, log *logrus.Logger) (Source, error) {
+		n := &NetBox{Log: log}
+
+		err := DecodeConfig(c, "netbox", &n.Config)
+		if err != nil {
+			return nil, err
+		}
+
+		if n.Config.URL == "" {
+			return nil, errors.New("netbox inventory source requires the netbox url to be declared")
+		}
+
+		if n.Config.PageSize == 0 {
+			n.Config.PageSize = 50
+		}
+
+		if len(n.Config.Status) == 0 {
+			n.Config.Status = []string{"active"}
+		}
+
+		if len(n.Config.ChassisRoles) == 0 {
+			n.Config.ChassisRoles = []string{"chassis"}
+		}
+
+		n.client = &http.Client{Timeout: 30 * time.Second}
+
+		return n, nil
+	})
+}
+
+// NetBoxConfig declares config for NetBox as an inventory source.
+type NetBoxConfig struct {
+	URL          string   `mapstructure:"url"`
+	Token        string   `mapstructure:"token"`
+	Sites        []string `mapstructure:"sites"`        //site slugs, all sites if not declared.
+	Roles        []string `mapstructure:"roles"`        //device role slugs, all roles if not declared.
+	Status       []string `mapstructure:"status"`       //device status, defaults to active.
+	ChassisRoles []string `mapstructure:"chassisRoles"` //device roles of chassis, defaults to chassis.
+	PageSize     int      `mapstructure:"pageSize"`
+}
+
+// NetBox struct holds attributes required to retrieve assets from NetBox.
+type NetBox struct {
+	Log    *logrus.Logger
+	Config NetBoxConfig
+	client *http.Client
+}
+
+// NetBoxSlug is used to unmarshal NetBox nested objects.
+type NetBoxSlug struct {
+	Slug string `json:"slug"`
+}
+
+// NetBoxDevice is used to unmarshal NetBox dcim/devices data.
+type NetBoxDevice struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Serial     string     `json:"serial"`
+	Site       NetBoxSlug `json:"site"`
+	Role       NetBoxSlug `json:"role"`
+	DeviceRole NetBoxSlug `json:"device_role"` //NetBox < 4.0
+	Status     struct {
+		Value string `json:"value"`
+	} `json:"status"`
+	DeviceType struct {
+		Model        string     `json:"model"`
+		Manufacturer NetBoxSlug `json:"manufacturer"`
+	} `json:"device_type"`
+	OobIP *struct {
+		Address string `json:"address"`
+	} `json:"oob_ip"`
+	ParentDevice *struct {
+		Name string `json:"name"`
+	} `json:"parent_device"` //the chassis of a blade.
+	CustomFields map[string]interface{} `json:"custom_fields"`
+	Tags         []NetBoxSlug           `json:"tags"`
+}
+
+// NetBoxIPAddress is used to unmarshal NetBox ipam/ip-addresses data.
+type NetBoxIPAddress struct {
+	ID      int    `json:"id"`
+	Address string `json:"address"`
+}
+
+// NetBoxPage is used to unmarshal a page of NetBox API results.
+type NetBoxPage struct {
+	Count   int             `json:"count"`
+	Next    string          `json:"next"`
+	Results json.RawMessage `json:"results"`
+}
+
+// SetsChassis returns true, the chassis of blades is their NetBox parent device.
+func (n *NetBox) SetsChassis() bool {
+	return true
+}
+
+// query queries the NetBox API and unmarshals the page of results.
+func (n *NetBox) query(queryURL string, page *NetBoxPage, results interface{}) error {
+
+	req, err := http.NewRequest("GET", queryURL, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if n.Config.Token != "" {
+		req.Header.Set("Authorization", "Token "+n.Config.Token)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error querying netbox %s: %s", queryURL, err)
+	}
+
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error querying netbox %s: %s", queryURL, err)
+	}
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("error querying netbox %s: status code %d, %s", queryURL, resp.StatusCode, body)
+	}
+
+	err = json.Unmarshal(body, page)
+	if err != nil {
+		return fmt.Errorf("unable to unmarshal data returned from netbox %s: %s", queryURL, err)
+	}
+
+	err = json.Unmarshal(page.Results, results)
+	if err != nil {
+		return fmt.Errorf("unable to unmarshal data returned from netbox %s: %s", queryURL, err)
+	}
+
+	return nil
+}
+
+// queryURL returns the NetBox API URL for the given path and query params.
+func (n *NetBox) queryURL(path string, params url.Values) string {
+	params.Set("limit", fmt.Sprintf("%d", n.Config.PageSize))
+
+	return fmt.Sprintf("%s/api/%s/?%s", strings.TrimSuffix(n.Config.URL, "/"), path, params.Encode())
+}
+
+// devices pages through the devices for the query params,
+// each page of devices is passed to the page func, paging stops if it returns false.
+func (n *NetBox) devices(params url.Values, page func([]NetBoxDevice) bool) error {
+
+	queryURL := n.queryURL("dcim/devices", params)
+	for queryURL != "" {
+		var results NetBoxPage
+		var devices []NetBoxDevice
+
+		err := n.query(queryURL, &results, &devices)
+		if err != nil {
+			return err
+		}
+
+		metrics.IncrCounter([]string{"inventory", "assets_fetched_netbox"}, int64(len(devices)))
+
+		if !page(devices) {
+			return nil
+		}
+
+		queryURL = results.Next
+	}
+
+	return nil
+}
+
+// ipAddresses pages through the IP addresses for the query params, returns all the addresses found.
+func (n *NetBox) ipAddresses(params url.Values) (addresses []NetBoxIPAddress, err error) {
+
+	queryURL := n.queryURL("ipam/ip-addresses", params)
+	for queryURL != "" {
+		var results NetBoxPage
+		var page []NetBoxIPAddress
+
+		err := n.query(queryURL, &results, &page)
+		if err != nil {
+			return addresses, err
+		}
+
+		addresses = append(addresses, page...)
+		queryURL = results.Next
+	}
+
+	return addresses, nil
+}
+
+// deviceParams returns the query params for the configured sites, roles and status.
+func (n *NetBox) deviceParams(locations []string) url.Values {
+
+	params := url.Values{}
+
+	//the configured sites are the sites managed, else the bmcbutler locations,
+	//assets are further filtered by location by the caller.
+	sites := n.Config.Sites
+	if len(sites) == 0 {
+		sites = locations
+	}
+
+	for _, site := range sites {
+		params.Add("site", site)
+	}
+
+	for _, role := range n.Config.Roles {
+		params.Add("role", role)
+	}
+
+	for _, status := range n.Config.Status {
+		params.Add("status", status)
+	}
+
+	return params
+}
+
+// asset returns the asset for the NetBox device,
+// false is returned if the device has no OOB IP.
+func (n *NetBox) asset(device NetBoxDevice) (asset.Asset, bool) {
+
+	a := asset.Asset{
+		Serial:   device.Serial,
+		Vendor:   device.DeviceType.Manufacturer.Slug,
+		Model:    device.DeviceType.Model,
+		Location: device.Site.Slug,
+		Type:     "server",
+		Extra:    make(map[string]string),
+	}
+
+	role := device.Role.Slug
+	if role == "" {
+		role = device.DeviceRole.Slug
+	}
+
+	for _, r := range n.Config.ChassisRoles {
+		if r == role {
+			a.Type = "chassis"
+		}
+	}
+
+	if device.ParentDevice != nil {
+		a.Chassis = device.ParentDevice.Name
+	}
+
+	a.Extra["name"] = device.Name
+	a.Extra["role"] = role
+	a.Extra["state"] = device.Status.Value
+
+	for key, value := range device.CustomFields {
+		if value == nil {
+			continue
+		}
+
+		a.Extra[key] = fmt.Sprintf("%v", value)
+	}
+
+	tags := make([]string, 0, len(device.Tags))
+	for _, tag := range device.Tags {
+		tags = append(tags, tag.Slug)
+	}
+
+	a.Extra["tags"] = strings.Join(tags, ",")
+
+	if device.OobIP == nil || device.OobIP.Address == "" {
+		n.Log.WithFields(logrus.Fields{
+			"component": "inventory",
+			"Serial":    device.Serial,
+			"Name":      device.Name,
+		}).Warn("NetBox device has no OOB IP, skipped.")
+
+		metrics.IncrCounter([]string{"inventory", "assets_noip_netbox"}, 1)
+		return a, false
+	}
+
+	//NetBox addresses include the prefix length.
+	ip := strings.SplitN(device.OobIP.Address, "/", 2)[0]
+	a.IPAddresses = []string{ip}
+
+	return a, true
+}
+
+// All pages through the NetBox devices of the configured sites, roles and status,
+// a batch of assets is passed to the batch func for each page of devices.
+// the asset types are filtered by the caller, when only chassis are to be retrieved
+// the query is narrowed down to the chassis roles.
+func (n *NetBox) All(filter Filter, batch func([]asset.Asset) bool) error {
+
+	params := n.deviceParams(filter.Locations)
+	if !filter.HasAssetType(Servers) {
+		params["role"] = n.Config.ChassisRoles
+	}
+
+	return n.devices(params, func(devices []NetBoxDevice) bool {
+		assets := make([]asset.Asset, 0, len(devices))
+		for _, device := range devices {
+			if a, ok := n.asset(device); ok {
+				assets = append(assets, a)
+			}
+		}
+
+		return batch(assets)
+	})
+}
+
+// BySerial looks up the NetBox devices with the given serials,
+// serials not found in NetBox are returned as assets with no IPs.
+func (n *NetBox) BySerial(serials []string) ([]asset.Asset, error) {
+
+	params := url.Values{}
+	for _, serial := range serials {
+		params.Add("serial", serial)
+	}
+
+	found := make(map[string]bool)
+	assets := make([]asset.Asset, 0)
+
+	err := n.devices(params, func(devices []NetBoxDevice) bool {
+		for _, device := range devices {
+			a, _ := n.asset(device)
+			found[strings.ToLower(a.Serial)] = true
+			assets = append(assets, a)
+		}
+
+		return true
+	})
+	if err != nil {
+		return assets, err
+	}
+
+	for _, serial := range serials {
+		if !found[strings.ToLower(serial)] {
+			assets = append(assets, asset.Asset{Serial: serial, IPAddresses: []string{}})
+		}
+	}
+
+	return assets, nil
+}
+
+// ByIP looks up the NetBox devices with the given OOB IPs,
+// IPs not found in NetBox are returned as assets with just the IP.
+func (n *NetBox) ByIP(ips []string) ([]asset.Asset, error) {
+
+	assets := make([]asset.Asset, 0)
+
+	//the devices API can't be filtered by IP, the IP address IDs are looked up first.
+	params := url.Values{}
+	for _, ip := range ips {
+		params.Add("address", ip)
+	}
+
+	addresses, err := n.ipAddresses(params)
+	if err != nil {
+		return assets, err
+	}
+
+	found := make(map[string]bool)
+
+	if len(addresses) > 0 {
+		params = url.Values{}
+		for _, address := range addresses {
+			params.Add("oob_ip_id", fmt.Sprintf("%d", address.ID))
+		}
+
+		err = n.devices(params, func(devices []NetBoxDevice) bool {
+			for _, device := range devices {
+				if a, ok := n.asset(device); ok {
+					found[a.IPAddresses[0]] = true
+					assets = append(assets, a)
+				}
+			}
+
+			return true
+		})
+		if err != nil {
+			return assets, err
+		}
+	}
+
+	for _, ip := range ips {
+		if !found[ip] {
+			assets = append(assets, asset.Asset{IPAddresses: []string{ip}})
+		}
+	}
+
+	return assets, nil
+}
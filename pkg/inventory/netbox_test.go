@@ -0,0 +1,212 @@
+package inventory
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/asset"
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+)
+
+var netboxDevices = []map[string]interface{}{
+	{
+		"id":            1,
+		"name":          "chassis1.ams2",
+		"serial":        "CHASSIS1",
+		"site":          map[string]string{"slug": "ams2"},
+		"role":          map[string]string{"slug": "chassis"},
+		"status":        map[string]string{"value": "active"},
+		"device_type":   map[string]interface{}{"model": "M1000e", "manufacturer": map[string]string{"slug": "dell"}},
+		"oob_ip":        map[string]string{"address": "10.0.0.1/24"},
+		"custom_fields": map[string]interface{}{"rack": "A1", "unset": nil},
+		"tags":          []map[string]string{{"slug": "prod"}, {"slug": "blue"}},
+	},
+	{
+		"id":            2,
+		"name":          "server1.ams2",
+		"serial":        "SERVER1",
+		"site":          map[string]string{"slug": "ams2"},
+		"device_role":   map[string]string{"slug": "compute"},
+		"status":        map[string]string{"value": "active"},
+		"oob_ip":        map[string]string{"address": "10.0.0.2/24"},
+		"parent_device": map[string]interface{}{"id": 1, "name": "chassis1.ams2"},
+	},
+	{
+		"id":     3,
+		"name":   "server2.ams2",
+		"serial": "SERVER2",
+		"site":   map[string]string{"slug": "ams2"},
+		"role":   map[string]string{"slug": "compute"},
+		"status": map[string]string{"value": "active"},
+	},
+}
+
+// netboxServer returns a httptest stand-in for the NetBox API,
+// devices and IP addresses are returned one per page.
+func netboxServer(t *testing.T) *httptest.Server {
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Token secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		q := r.URL.Query()
+		results := make([]map[string]interface{}, 0)
+		next := ""
+
+		switch {
+		case r.URL.Path == "/api/ipam/ip-addresses/":
+			//the IP address IDs match the ID of the device they are the OOB IP of.
+			addresses := make([]map[string]interface{}, 0)
+			for _, address := range q["address"] {
+				for _, d := range netboxDevices {
+					if oob, ok := d["oob_ip"].(map[string]string); ok && oob["address"] == address+"/24" {
+						addresses = append(addresses, map[string]interface{}{"id": d["id"], "address": oob["address"]})
+					}
+				}
+			}
+
+			offset, _ := strconv.Atoi(q.Get("offset"))
+			if offset < len(addresses) {
+				results = append(results, addresses[offset])
+			}
+
+			if offset < len(addresses)-1 {
+				q.Set("offset", strconv.Itoa(offset+1))
+				next = server.URL + r.URL.Path + "?" + q.Encode()
+			}
+		case r.URL.Path == "/api/dcim/devices/" && q.Get("serial") != "":
+			for _, d := range netboxDevices {
+				if d["serial"] == q.Get("serial") {
+					results = append(results, d)
+				}
+			}
+		case r.URL.Path == "/api/dcim/devices/" && q.Get("oob_ip_id") != "":
+			for _, id := range q["oob_ip_id"] {
+				for _, d := range netboxDevices {
+					if strconv.Itoa(d["id"].(int)) == id {
+						results = append(results, d)
+					}
+				}
+			}
+		case r.URL.Path == "/api/dcim/devices/":
+			if q.Get("site") != "ams2" || q.Get("status") != "active" {
+				t.Errorf("Expected devices to be filtered by site, status, got %s", r.URL.RawQuery)
+			}
+
+			offset, _ := strconv.Atoi(q.Get("offset"))
+			results = append(results, netboxDevices[offset])
+			if offset < len(netboxDevices)-1 {
+				q.Set("offset", strconv.Itoa(offset+1))
+				next = server.URL + r.URL.Path + "?" + q.Encode()
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"count": len(results), "next": next, "results": results})
+	}))
+
+	return server
+}
+
+func netboxSource(t *testing.T, url string) Source {
+
+	c := &config.Params{
+		Inventory: &config.Inventory{
+			Source: "netbox",
+			Sources: map[string]interface{}{
+				"netbox": map[string]interface{}{"url": url, "token": "secret", "sites": []string{"ams2"}},
+			},
+		},
+	}
+
+	source, err := New(c, logrus.New())
+	if err != nil {
+		t.Fatalf("Expected netbox source, got error: %s", err)
+	}
+
+	return source
+}
+
+// TestNetBoxAll tests devices are paged through and mapped to assets,
+// devices with no OOB IP are skipped and assets are filtered by asset type.
+func TestNetBoxAll(t *testing.T) {
+
+	server := netboxServer(t)
+	defer server.Close()
+
+	source := netboxSource(t, server.URL)
+
+	var assets []asset.Asset
+	err := source.All(Filter{AssetTypes: []string{Chassis, Servers}}, func(batch []asset.Asset) bool {
+		assets = append(assets, batch...)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("Expected assets, got error: %s", err)
+	}
+
+	if len(assets) != 2 {
+		t.Fatalf("Expected two assets with OOB IPs, got %+v", assets)
+	}
+
+	chassis := assets[0]
+	if chassis.Type != "chassis" || chassis.IPAddresses[0] != "10.0.0.1" || chassis.Location != "ams2" ||
+		chassis.Vendor != "dell" || chassis.Extra["rack"] != "A1" || chassis.Extra["tags"] != "prod,blue" {
+		t.Fatalf("Unexpected chassis asset %+v", chassis)
+	}
+
+	if _, exists := chassis.Extra["unset"]; exists {
+		t.Fatalf("Expected unset custom fields to be left out of extras")
+	}
+
+	if assets[1].Type != "server" || assets[1].Extra["role"] != "compute" || assets[1].Chassis != "chassis1.ams2" {
+		t.Fatalf("Unexpected server asset %+v", assets[1])
+	}
+
+	servers := collect(Retrieve(source, Filter{AssetTypes: []string{Servers}}, make(chan struct{}), logrus.New()))
+	if len(servers) != 1 || servers[0].Serial != "SERVER1" {
+		t.Fatalf("Expected server asset, got %+v", servers)
+	}
+}
+
+// TestNetBoxBySerialByIP tests assets are looked up by serial and IP,
+// serials and IPs not found are returned as assets with no attributes.
+func TestNetBoxBySerialByIP(t *testing.T) {
+
+	server := netboxServer(t)
+	defer server.Close()
+
+	source := netboxSource(t, server.URL)
+
+	assets, err := source.BySerial([]string{"SERVER1"})
+	if err != nil || len(assets) != 1 || assets[0].IPAddresses[0] != "10.0.0.2" {
+		t.Fatalf("Unexpected assets looked up by serial %+v, error: %v", assets, err)
+	}
+
+	assets, err = source.ByIP([]string{"10.0.0.2", "10.0.0.9"})
+	if err != nil || len(assets) != 2 || assets[0].Serial != "SERVER1" || assets[1].IPAddresses[0] != "10.0.0.9" {
+		t.Fatalf("Unexpected assets looked up by IP %+v, error: %v", assets, err)
+	}
+
+	// the IP addresses are paged through, one per page.
+	assets, err = source.ByIP([]string{"10.0.0.1", "10.0.0.2"})
+	if err != nil || len(assets) != 2 || assets[0].Serial != "CHASSIS1" || assets[1].Serial != "SERVER1" {
+		t.Fatalf("Unexpected assets looked up by IP across pages %+v, error: %v", assets, err)
+	}
+
+	bad := netboxSource(t, server.URL+"/foo")
+	_, err = bad.BySerial([]string{"SERVER1"})
+	if err == nil {
+		t.Fatalf("Expected error on netbox API error")
+	}
+}
@@ -0,0 +1,310 @@
+package inventory
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/sirupsen/logrus"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/asset"
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+	"github.com/bmc-toolbox/bmcbutler/pkg/metrics"
+)
+
+// Source is implemented by inventory sources,
+// sources return errors and leave it to the caller to decide how to handle them,
+// along with an error BySerial, ByIP return just the assets that were retrieved.
+type Source interface {
+	// All retrieves assets of the filter asset types and locations,
+	// each batch of assets retrieved is passed to the batch func,
+	// the source stops retrieving assets if the batch func returns false.
+	All(filter Filter, batch func(assets []asset.Asset) bool) error
+	// BySerial returns assets for the given serials,
+	// serials not found in the inventory are returned as assets with no IPs.
+	BySerial(serials []string) ([]asset.Asset, error)
+	// ByIP returns assets for the given IPs,
+	// IPs not found in the inventory are returned as assets with just the IP.
+	ByIP(ips []string) ([]asset.Asset, error)
+}
+
+// ChassisInstaller is implemented by inventory sources that track the chassis install state.
+type ChassisInstaller interface {
+	SetChassisInstalled(serials string)
+}
+
+// ChassisSetter is implemented by inventory sources that set the parent chassis of blades,
+// the per chassis limit is enforced only with these sources.
+type ChassisSetter interface {
+	SetsChassis() bool
+}
+
+// Factory returns an inventory source set up with the bmcbutler config.
+type Factory func(c *config.Params, log *logrus.Logger) (Source, error)
+
+var sources = make(map[string]Factory)
+
+// Register adds an inventory source factory to the registry,
+// registering a source name twice panics.
+func Register(name string, factory Factory) {
+	if _, exists := sources[name]; exists {
+		panic(fmt.Sprintf("inventory source %s registered twice", name))
+	}
+
+	sources[name] = factory
+}
+
+// Names returns a sorted list of registered inventory sources.
+func Names() []string {
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// New returns the inventory source declared in the config.
+func New(c *config.Params, log *logrus.Logger) (Source, error) {
+
+	if c.Inventory == nil || c.Inventory.Source == "" {
+		return nil, fmt.Errorf("no inventory source declared in config, valid sources: %s", strings.Join(Names(), ", "))
+	}
+
+	factory, exists := sources[c.Inventory.Source]
+	if !exists {
+		return nil, fmt.Errorf("unknown inventory source: %s, valid sources: %s", c.Inventory.Source, strings.Join(Names(), ", "))
+	}
+
+	source, err := factory(c, log)
+	if err != nil {
+		return nil, err
+	}
+
+	// a per chassis limit that can't be enforced is rejected rather than ignored.
+	if c.Limits != nil && c.Limits.PerChassis > 0 {
+		setter, ok := source.(ChassisSetter)
+		if !ok || !setter.SetsChassis() {
+			return nil, fmt.Errorf("the %s inventory source does not declare the chassis of blades, the perChassis limit can't be enforced", c.Inventory.Source)
+		}
+	}
+
+	return source, nil
+}
+
+// DecodeConfig decodes the configuration section of an inventory source
+// not declared in config.Inventory into the given struct, using mapstructure tags.
+func DecodeConfig(c *config.Params, name string, out interface{}) error {
+
+	section, exists := c.Inventory.Sources[name]
+	if !exists {
+		return fmt.Errorf("expected inventory configuration section %s missing", name)
+	}
+
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+		WeaklyTypedInput: true,
+		Result:           out,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = decoder.Decode(section)
+	if err != nil {
+		return fmt.Errorf("inventory configuration section %s: %s", name, err)
+	}
+
+	return nil
+}
+
+// Asset types to filter by.
+const (
+	Chassis = "chassis"
+	Servers = "servers"
+)
+
+// Filter declares the assets to be retrieved from an inventory source.
+type Filter struct {
+	Serials    []string
+	Ips        []string
+	AssetTypes []string //Chassis, Servers - all asset types if empty.
+	Locations  []string //all locations if empty.
+}
+
+// NewFilter returns the filter declared by the asset filter params and locations in the config.
+func NewFilter(c *config.Params) (f Filter) {
+
+	if c.FilterParams != nil {
+		if c.FilterParams.Serials != "" {
+			f.Serials = strings.Split(c.FilterParams.Serials, ",")
+		}
+
+		if c.FilterParams.Ips != "" {
+			f.Ips = strings.Split(c.FilterParams.Ips, ",")
+		}
+
+		if c.FilterParams.Chassis {
+			f.AssetTypes = append(f.AssetTypes, Chassis)
+		}
+
+		if c.FilterParams.Servers {
+			f.AssetTypes = append(f.AssetTypes, Servers)
+		}
+	}
+
+	if len(f.AssetTypes) == 0 {
+		f.AssetTypes = []string{Chassis, Servers}
+	}
+
+	if !c.IgnoreLocation {
+		f.Locations = c.Locations
+	}
+
+	return f
+}
+
+// HasAssetType returns true if the filter includes the asset type.
+func (f *Filter) HasAssetType(assetType string) bool {
+	for _, t := range f.AssetTypes {
+		if t == assetType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// assetType returns the filter asset type for the inventory asset type,
+// an empty string if the asset type is not known.
+func assetType(t string) string {
+	switch strings.ToLower(t) {
+	case "chassis":
+		return Chassis
+	case "server", "servers", "blade", "discrete", "discretes":
+		return Servers
+	default:
+		return ""
+	}
+}
+
+// Match returns true if the asset matches the filter asset types and locations,
+// assets with an unknown type or location are matched.
+func (f *Filter) Match(a *asset.Asset) bool {
+
+	if t := assetType(a.Type); t != "" && !f.HasAssetType(t) {
+		return false
+	}
+
+	if a.Location == "" || len(f.Locations) == 0 {
+		return true
+	}
+
+	for _, l := range f.Locations {
+		if l == a.Location {
+			return true
+		}
+	}
+
+	return false
+}
+
+// unretrieved returns an asset with the error set, for each of the given serials, IPs not in the assets retrieved,
+// so the inventory error is attributed to the assets requested.
+func unretrieved(assets []asset.Asset, serials []string, ips []string, err error) (failed []asset.Asset) {
+
+	retrieved := make(map[string]bool)
+	for _, a := range assets {
+		retrieved[strings.ToLower(a.Serial)] = true
+		for _, ip := range a.IPAddresses {
+			retrieved[ip] = true
+		}
+	}
+
+	for _, serial := range serials {
+		if !retrieved[strings.ToLower(serial)] {
+			failed = append(failed, asset.Asset{Serial: serial, Error: err.Error()})
+		}
+	}
+
+	for _, ip := range ips {
+		if !retrieved[ip] {
+			failed = append(failed, asset.Asset{IPAddress: ip, IPAddresses: []string{ip}, Error: err.Error()})
+		}
+	}
+
+	return failed
+}
+
+// Retrieve retrieves assets matching the filter from the inventory source,
+// assets are sent over the returned channel, which is closed once all assets were sent.
+// Serials, IPs in the filter are looked up, else all assets of the filter asset types and locations are retrieved.
+// Inventory errors are logged and counted, assets retrieved until the error are passed on,
+// serials, IPs that could not be looked up are passed on as assets with the error set.
+func Retrieve(source Source, filter Filter, stopChan <-chan struct{}, log *logrus.Logger) <-chan []asset.Asset {
+
+	assetsChan := make(chan []asset.Asset, 5)
+
+	// send returns false if an interrupt was received.
+	send := func(assets []asset.Asset) bool {
+		if len(assets) == 0 {
+			return true
+		}
+
+		select {
+		case assetsChan <- assets:
+			return true
+		case <-stopChan:
+			return false
+		}
+	}
+
+	go func() {
+		defer close(assetsChan)
+
+		var assets []asset.Asset
+		var err error
+
+		switch {
+		case len(filter.Serials) > 0:
+			assets, err = source.BySerial(filter.Serials)
+			if err != nil {
+				assets = append(assets, unretrieved(assets, filter.Serials, nil, err)...)
+			}
+
+			send(assets)
+		case len(filter.Ips) > 0:
+			assets, err = source.ByIP(filter.Ips)
+			if err != nil {
+				assets = append(assets, unretrieved(assets, nil, filter.Ips, err)...)
+			}
+
+			send(assets)
+		default:
+			err = source.All(filter, func(assets []asset.Asset) bool {
+				matched := make([]asset.Asset, 0, len(assets))
+				for idx := range assets {
+					if filter.Match(&assets[idx]) {
+						matched = append(matched, assets[idx])
+					}
+				}
+
+				return send(matched)
+			})
+		}
+
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"component": "inventory",
+				"Error":     err,
+			}).Error("Inventory retrieve returned error.")
+
+			metrics.IncrCounter([]string{"inventory", "errors"}, 1)
+		}
+	}()
+
+	return assetsChan
+}
@@ -0,0 +1,163 @@
+package inventory
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/asset"
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+)
+
+type fakeSource struct {
+	assets []asset.Asset
+	err    error
+}
+
+func (f *fakeSource) All(filter Filter, batch func([]asset.Asset) bool) error {
+	for idx := range f.assets {
+		if !batch(f.assets[idx : idx+1]) {
+			return nil
+		}
+	}
+
+	return f.err
+}
+
+func (f *fakeSource) BySerial(serials []string) ([]asset.Asset, error) {
+	return []asset.Asset{{Serial: serials[0]}}, f.err
+}
+
+func (f *fakeSource) ByIP(ips []string) ([]asset.Asset, error) {
+	return []asset.Asset{{IPAddresses: ips}}, f.err
+}
+
+func collect(assetsChan <-chan []asset.Asset) (assets []asset.Asset) {
+	for batch := range assetsChan {
+		assets = append(assets, batch...)
+	}
+
+	return assets
+}
+
+// TestNewFilter tests the filter is set up from the config filter params and locations.
+func TestNewFilter(t *testing.T) {
+
+	c := &config.Params{
+		FilterParams: &config.FilterParams{Serials: "FOO,BAR", Chassis: true},
+		Locations:    []string{"ams2"},
+	}
+
+	f := NewFilter(c)
+	if len(f.Serials) != 2 || len(f.AssetTypes) != 1 || !f.HasAssetType(Chassis) || len(f.Locations) != 1 {
+		t.Fatalf("Unexpected filter %+v", f)
+	}
+
+	c.FilterParams = &config.FilterParams{All: true}
+	c.IgnoreLocation = true
+
+	f = NewFilter(c)
+	if !f.HasAssetType(Chassis) || !f.HasAssetType(Servers) || len(f.Locations) != 0 {
+		t.Fatalf("Expected all asset types and locations, got %+v", f)
+	}
+}
+
+// TestRetrieve tests assets returned by All are filtered by asset type and location,
+// serials, IPs are looked up and the channel is closed on errors,
+// serials, IPs not retrieved are passed on with the error set.
+func TestRetrieve(t *testing.T) {
+
+	source := &fakeSource{
+		assets: []asset.Asset{
+			{Serial: "chassis1", Type: "chassis", Location: "ams2"},
+			{Serial: "blade1", Type: "blade", Location: "ams2"},
+			{Serial: "chassis2", Type: "chassis", Location: "lhr4"},
+			{Serial: "unknown", Location: "ams2"},
+		},
+		err: errors.New("inventory unavailable"),
+	}
+
+	filter := Filter{AssetTypes: []string{Chassis}, Locations: []string{"ams2"}}
+	assets := collect(Retrieve(source, filter, make(chan struct{}), logrus.New()))
+	if len(assets) != 2 || assets[0].Serial != "chassis1" || assets[1].Serial != "unknown" {
+		t.Fatalf("Expected chassis1, unknown assets, got %+v", assets)
+	}
+
+	assets = collect(Retrieve(source, Filter{Serials: []string{"FOO"}}, make(chan struct{}), logrus.New()))
+	if len(assets) != 1 || assets[0].Serial != "FOO" {
+		t.Fatalf("Expected asset looked up by serial, got %+v", assets)
+	}
+
+	// serials, IPs not retrieved are attributed the inventory error.
+	assets = collect(Retrieve(source, Filter{Serials: []string{"FOO", "BAR"}}, make(chan struct{}), logrus.New()))
+	if len(assets) != 2 || assets[0].Error != "" || assets[1].Serial != "BAR" || assets[1].Error != "inventory unavailable" {
+		t.Fatalf("Expected BAR asset with the inventory error, got %+v", assets)
+	}
+
+	assets = collect(Retrieve(source, Filter{Ips: []string{"10.0.0.1"}}, make(chan struct{}), logrus.New()))
+	if len(assets) != 1 || assets[0].Error != "" {
+		t.Fatalf("Expected asset looked up by IP with no error, got %+v", assets)
+	}
+
+	stopChan := make(chan struct{})
+	close(stopChan)
+	assetsChan := Retrieve(source, Filter{AssetTypes: []string{Chassis, Servers}}, stopChan, logrus.New())
+	if len(collect(assetsChan)) > len(source.assets) {
+		t.Fatalf("Expected retrieve to stop on interrupt")
+	}
+}
+
+// TestCsvSource tests the csv source is set up from the registry and returns assets.
+func TestCsvSource(t *testing.T) {
+
+	csvFile, err := ioutil.TempFile("", "inventory*.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.Remove(csvFile.Name()) }()
+
+	_, err = csvFile.WriteString("bmcaddress,serial,vendor,type\n192.168.1.1,FOO,dell,chassis\n192.168.1.2,BAR,hp,server\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	csvFile.Close()
+
+	c := &config.Params{Inventory: &config.Inventory{Source: "csv", Csv: &config.Csv{File: csvFile.Name()}}}
+	source, err := New(c, logrus.New())
+	if err != nil {
+		t.Fatalf("Expected csv source, got error: %s", err)
+	}
+
+	assets, err := source.ByIP([]string{"192.168.1.2", "192.168.1.3"})
+	if err != nil || len(assets) != 2 || assets[0].Serial != "BAR" || assets[1].Serial != "" {
+		t.Fatalf("Unexpected assets looked up by IP %+v, error: %v", assets, err)
+	}
+
+	filter := Filter{AssetTypes: []string{Servers}}
+	assets = collect(Retrieve(source, filter, make(chan struct{}), logrus.New()))
+	if len(assets) != 1 || assets[0].Serial != "BAR" {
+		t.Fatalf("Expected server asset, got %+v", assets)
+	}
+
+	// the per chassis limit is rejected with sources that don't declare the chassis of blades.
+	c.Limits = &config.Limits{PerChassis: 2}
+	_, err = New(c, logrus.New())
+	if err != nil {
+		t.Errorf("Expected the per chassis limit with the csv source, got error: %s", err)
+	}
+
+	c.Inventory.Source = "iplist"
+	_, err = New(c, logrus.New())
+	if err == nil {
+		t.Error("Expected error on the per chassis limit with the iplist source")
+	}
+
+	c.Inventory.Source = "foobar"
+	_, err = New(c, logrus.New())
+	if err == nil {
+		t.Fatalf("Expected error on unknown inventory source")
+	}
+}
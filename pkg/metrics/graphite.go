@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"errors"
+	"time"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+	ginmetrics "github.com/bmc-toolbox/gin-go-metrics"
+)
+
+// graphite emits metrics to graphite using gin-go-metrics, labels are ignored.
+type graphite struct{}
+
+func newGraphite(cfg *config.Graphite) (*graphite, error) {
+
+	if cfg == nil {
+		return nil, errors.New("graphite metrics client declared without graphite configuration")
+	}
+
+	err := ginmetrics.Setup("graphite", cfg.Host, cfg.Port, cfg.Prefix, cfg.FlushInterval)
+	if err != nil {
+		return nil, err
+	}
+
+	return &graphite{}, nil
+}
+
+func (g *graphite) incrCounter(key []string, value int64, _ Labels) {
+	ginmetrics.IncrCounter(key, value)
+}
+
+func (g *graphite) updateGauge(key []string, value int64, _ Labels) {
+	ginmetrics.UpdateGauge(key, value)
+}
+
+// runtime is emitted as a gauge in milliseconds, as gin-go-metrics MeasureRuntime does.
+func (g *graphite) measureRuntime(key []string, elapsed time.Duration, _ Labels) {
+	ginmetrics.UpdateGauge(key, int64(elapsed/time.Millisecond))
+}
+
+func (g *graphite) close(printStats bool) {
+	ginmetrics.Close(printStats)
+}
@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+)
+
+// Labels are attached to metrics by clients that support labels (prometheus),
+// clients that don't (graphite) ignore them.
+type Labels struct {
+	Vendor   string
+	Model    string
+	Location string
+	Resource string
+}
+
+// client is implemented by metric emitters.
+type client interface {
+	incrCounter(key []string, value int64, labels Labels)
+	updateGauge(key []string, value int64, labels Labels)
+	measureRuntime(key []string, elapsed time.Duration, labels Labels)
+	close(printStats bool)
+}
+
+// emitter is the metrics client set up, metrics are dropped if no client was set up.
+var emitter client
+
+// Setup sets up the metrics client declared in the config,
+// if no metrics client is declared, metrics are dropped.
+func Setup(cfg *config.Metrics) (err error) {
+
+	if cfg == nil {
+		return nil
+	}
+
+	switch cfg.Client {
+	case "graphite":
+		emitter, err = newGraphite(cfg.Graphite)
+	case "prometheus":
+		emitter, err = newPrometheus(cfg.Prometheus)
+	default:
+		err = fmt.Errorf("no supported metrics client declared in config")
+	}
+
+	return err
+}
+
+// IncrCounter increments the counter by the given value.
+func IncrCounter(key []string, value int64) {
+	IncrCounterWithLabels(key, value, Labels{})
+}
+
+// IncrCounterWithLabels increments the counter by the given value, with the given labels.
+func IncrCounterWithLabels(key []string, value int64, labels Labels) {
+	if emitter == nil {
+		return
+	}
+
+	emitter.incrCounter(key, value, labels)
+}
+
+// UpdateGauge sets the gauge to the given value.
+func UpdateGauge(key []string, value int64) {
+	UpdateGaugeWithLabels(key, value, Labels{})
+}
+
+// UpdateGaugeWithLabels sets the gauge to the given value, with the given labels.
+func UpdateGaugeWithLabels(key []string, value int64, labels Labels) {
+	if emitter == nil {
+		return
+	}
+
+	emitter.updateGauge(key, value, labels)
+}
+
+// MeasureRuntime measures time elapsed since start,
+// to be invoked with defer.
+func MeasureRuntime(key []string, start time.Time) {
+	MeasureRuntimeWithLabels(key, start, Labels{})
+}
+
+// MeasureRuntimeWithLabels measures time elapsed since start, with the given labels.
+func MeasureRuntimeWithLabels(key []string, start time.Time, labels Labels) {
+	if emitter == nil {
+		return
+	}
+
+	emitter.measureRuntime(key, time.Since(start), labels)
+}
+
+// Close flushes any metrics and runs cleanup actions.
+func Close(printStats bool) {
+	if emitter == nil {
+		return
+	}
+
+	emitter.close(printStats)
+}
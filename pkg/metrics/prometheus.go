@@ -0,0 +1,171 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/push"
+	"github.com/sirupsen/logrus"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+)
+
+// labelNames are the labels set on every prometheus metric,
+// labels not known when the metric is emitted are left empty.
+var labelNames = []string{"vendor", "model", "location", "resource"}
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// prometheus exposes metrics on a /metrics endpoint for prometheus to scrape,
+// and/or pushes them to a pushgateway on close - for one shot cli runs.
+type prometheusClient struct {
+	cfg        *config.Prometheus
+	registry   *prometheus.Registry
+	server     *http.Server
+	mutex      sync.Mutex
+	counters   map[string]*prometheus.CounterVec
+	gauges     map[string]*prometheus.GaugeVec
+	histograms map[string]*prometheus.HistogramVec
+}
+
+func newPrometheus(cfg *config.Prometheus) (*prometheusClient, error) {
+
+	if cfg == nil {
+		return nil, errors.New("prometheus metrics client declared without prometheus configuration")
+	}
+
+	p := &prometheusClient{
+		cfg:        cfg,
+		registry:   prometheus.NewRegistry(),
+		counters:   make(map[string]*prometheus.CounterVec),
+		gauges:     make(map[string]*prometheus.GaugeVec),
+		histograms: make(map[string]*prometheus.HistogramVec),
+	}
+
+	if cfg.Listen != "" {
+		p.registry.MustRegister(prometheus.NewGoCollector())
+		p.registry.MustRegister(prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.HandlerFor(p.registry, promhttp.HandlerOpts{}))
+		p.server = &http.Server{Addr: cfg.Listen, Handler: mux}
+
+		go func() {
+			err := p.server.ListenAndServe()
+			if err != nil && err != http.ErrServerClosed {
+				logrus.WithFields(logrus.Fields{
+					"component": "metrics",
+					"Listen":    cfg.Listen,
+					"Error":     err,
+				}).Error("Prometheus metrics endpoint failed.")
+			}
+		}()
+	}
+
+	return p, nil
+}
+
+// name returns the prometheus metric name for the key,
+// e.g prefix bmcbutler, key [butler, configure_success] -> bmcbutler_butler_configure_success
+func (p *prometheusClient) name(key []string, suffix string) string {
+	parts := append([]string{p.cfg.Prefix}, key...)
+	if suffix != "" {
+		parts = append(parts, suffix)
+	}
+
+	return invalidNameChars.ReplaceAllString(strings.Join(parts, "_"), "_")
+}
+
+func labelValues(labels Labels) []string {
+	return []string{labels.Vendor, labels.Model, labels.Location, labels.Resource}
+}
+
+func (p *prometheusClient) incrCounter(key []string, value int64, labels Labels) {
+
+	name := p.name(key, "total")
+
+	p.mutex.Lock()
+	c, exists := p.counters[name]
+	if !exists {
+		c = prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: strings.Join(key, " ")}, labelNames)
+		p.registry.MustRegister(c)
+		p.counters[name] = c
+	}
+	p.mutex.Unlock()
+
+	c.WithLabelValues(labelValues(labels)...).Add(float64(value))
+}
+
+func (p *prometheusClient) updateGauge(key []string, value int64, labels Labels) {
+
+	name := p.name(key, "")
+
+	p.mutex.Lock()
+	g, exists := p.gauges[name]
+	if !exists {
+		g = prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name, Help: strings.Join(key, " ")}, labelNames)
+		p.registry.MustRegister(g)
+		p.gauges[name] = g
+	}
+	p.mutex.Unlock()
+
+	g.WithLabelValues(labelValues(labels)...).Set(float64(value))
+}
+
+func (p *prometheusClient) measureRuntime(key []string, elapsed time.Duration, labels Labels) {
+
+	name := p.name(key, "seconds")
+
+	p.mutex.Lock()
+	h, exists := p.histograms[name]
+	if !exists {
+		h = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    name,
+			Help:    strings.Join(key, " "),
+			Buckets: []float64{1, 5, 10, 30, 60, 120, 300, 600, 1200},
+		}, labelNames)
+		p.registry.MustRegister(h)
+		p.histograms[name] = h
+	}
+	p.mutex.Unlock()
+
+	h.WithLabelValues(labelValues(labels)...).Observe(elapsed.Seconds())
+}
+
+// close pushes metrics to the pushgateway if declared, and stops the /metrics endpoint.
+func (p *prometheusClient) close(printStats bool) {
+
+	log := logrus.WithFields(logrus.Fields{"component": "metrics"})
+
+	if printStats {
+		families, err := p.registry.Gather()
+		if err == nil {
+			for _, f := range families {
+				log.WithFields(logrus.Fields{"metric": f.GetName()}).Info(f.GetMetric())
+			}
+		}
+	}
+
+	if p.cfg.PushGateway != "" {
+		err := push.New(p.cfg.PushGateway, p.cfg.Job).Gatherer(p.registry).Push()
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"PushGateway": p.cfg.PushGateway,
+				"Error":       err,
+			}).Error("Unable to push metrics to pushgateway.")
+		}
+	}
+
+	if p.server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = p.server.Shutdown(ctx)
+	}
+}
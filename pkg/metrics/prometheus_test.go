@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+)
+
+// TestPrometheusPush tests metrics are emitted with labels,
+// and pushed to the pushgateway on close.
+func TestPrometheusPush(t *testing.T) {
+
+	var path, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	p, err := newPrometheus(&config.Prometheus{Prefix: "bmcbutler", PushGateway: server.URL, Job: "bmcbutler"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	labels := Labels{Vendor: "dell", Model: "idrac9", Location: "ams2"}
+	p.incrCounter([]string{"butler", "configure_success"}, 1, labels)
+	p.incrCounter([]string{"butler", "configure_success"}, 1, labels)
+	p.measureRuntime([]string{"butler", "configure_runtime"}, 3*time.Second, labels)
+
+	families, err := p.registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var found bool
+	for _, f := range families {
+		if f.GetName() != "bmcbutler_butler_configure_success_total" {
+			continue
+		}
+
+		found = true
+		m := f.GetMetric()[0]
+		if m.GetCounter().GetValue() != 2 {
+			t.Errorf("Expected counter value 2, got %f", m.GetCounter().GetValue())
+		}
+
+		if len(m.GetLabel()) != len(labelNames) {
+			t.Errorf("Expected labels %v, got %v", labelNames, m.GetLabel())
+		}
+	}
+
+	if !found {
+		t.Fatalf("Expected bmcbutler_butler_configure_success_total metric, got %v", families)
+	}
+
+	p.close(false)
+
+	if path != "/metrics/job/bmcbutler" {
+		t.Fatalf("Expected metrics pushed to /metrics/job/bmcbutler, got %s", path)
+	}
+
+	if !strings.Contains(body, "bmcbutler_butler_configure_runtime_seconds") {
+		t.Fatalf("Expected configure_runtime to be pushed.")
+	}
+}
@@ -0,0 +1,272 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record holds the outcome of an action carried out by a butler on an asset.
+type Record struct {
+	Serial    string         `json:"serial"`
+	IPAddress string         `json:"ip_address"`
+	Vendor    string         `json:"vendor"`
+	Model     string         `json:"model"`
+	Type      string         `json:"type"`
+	Location  string         `json:"location"`
+	Action    string         `json:"action"`
+	Applied   []string       `json:"applied"`
+	Failed    []string       `json:"failed"`
+	Skipped   []string       `json:"skipped,omitempty"`
+	Errors    []string       `json:"errors"`
+	Success   bool           `json:"success"`
+	TimedOut  bool           `json:"timed_out,omitempty"`
+	Duration  float64        `json:"duration_seconds"`
+	JobID     string         `json:"job_id,omitempty"`
+	Attempts  int            `json:"attempts"`          //the times the asset was actioned, failed assets are re-queued.
+	Retried   map[string]int `json:"retried,omitempty"` //the attempts made on resources that were retried.
+}
+
+// Recorder is implemented by types that receive records from butlers.
+type Recorder interface {
+	Add(r Record)
+}
+
+// Collector aggregates records sent in by butlers.
+type Collector struct {
+	mutex   sync.Mutex
+	records []Record
+}
+
+// NewCollector returns a Collector to aggregate records.
+func NewCollector() *Collector {
+	return &Collector{records: make([]Record, 0)}
+}
+
+// Add appends a record to the collector.
+func (c *Collector) Add(r Record) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.records = append(c.records, r)
+}
+
+// Records returns a copy of the records collected.
+func (c *Collector) Records() []Record {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	records := make([]Record, len(c.records))
+	copy(records, c.records)
+
+	return records
+}
+
+// Flush writes the records collected since the last flush to the given files, and resets the collector,
+// so a long running process doesn't hold on to every record.
+func (c *Collector) Flush(jsonFile string, junitFile string) error {
+	c.mutex.Lock()
+	records := c.records
+	c.records = make([]Record, 0)
+	c.mutex.Unlock()
+
+	flushed := &Collector{records: records}
+	return flushed.Write(jsonFile, junitFile)
+}
+
+// Write writes the collected records as JSON and JUnit XML to the given files,
+// an empty file name skips the format.
+func (c *Collector) Write(jsonFile string, junitFile string) error {
+
+	writers := []struct {
+		file  string
+		write func(io.Writer) error
+	}{
+		{jsonFile, c.WriteJSON},
+		{junitFile, c.WriteJUnit},
+	}
+
+	for _, w := range writers {
+		if w.file == "" {
+			continue
+		}
+
+		f, err := os.Create(w.file)
+		if err != nil {
+			return err
+		}
+
+		err = w.write(f)
+		if err != nil {
+			_ = f.Close()
+			return fmt.Errorf("unable to write report %s: %s", w.file, err)
+		}
+
+		err = f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteJSON writes the collected records as a JSON list.
+func (c *Collector) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(c.Records())
+}
+
+// junit XML structure as understood by most CI systems.
+type junitTestSuites struct {
+	XMLName    xml.Name         `xml:"testsuites"`
+	TestSuites []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Skipped    int             `xml:"skipped,attr"`
+	Time       string          `xml:"time,attr"`
+	Properties []junitProperty `xml:"properties>property"`
+	TestCases  []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Content string `xml:",chardata"`
+}
+
+// WriteJUnit writes the collected records as JUnit XML,
+// each asset is a test suite, each resource applied on the asset a test case.
+func (c *Collector) WriteJUnit(w io.Writer) error {
+
+	suites := junitTestSuites{}
+
+	for _, r := range c.Records() {
+		suite := junitTestSuite{
+			Name: fmt.Sprintf("%s.%s", r.Action, r.Serial),
+			Time: fmt.Sprintf("%.3f", r.Duration),
+			Properties: []junitProperty{
+				{Name: "ip_address", Value: r.IPAddress},
+				{Name: "vendor", Value: r.Vendor},
+				{Name: "model", Value: r.Model},
+				{Name: "location", Value: r.Location},
+				{Name: "attempts", Value: strconv.Itoa(r.Attempts)},
+			},
+		}
+
+		className := strings.Join([]string{r.Action, r.Vendor, r.Model}, ".")
+
+		for _, resource := range r.Applied {
+			suite.TestCases = append(suite.TestCases, junitTestCase{Name: resource, ClassName: className})
+		}
+
+		for _, resource := range r.Failed {
+			content := resourceErrors(resource, r.Errors)
+			suite.TestCases = append(suite.TestCases, junitTestCase{
+				Name:      resource,
+				ClassName: className,
+				Failure:   &junitFailure{Message: failureMessage(r, content), Content: content},
+			})
+		}
+
+		for _, resource := range r.Skipped {
+			suite.TestCases = append(suite.TestCases, junitTestCase{
+				Name:      resource,
+				ClassName: className,
+				Skipped:   &junitSkipped{Message: "unchanged since last applied"},
+			})
+		}
+
+		// an asset that failed before any resource was applied.
+		if !r.Success && len(r.Failed) == 0 {
+			suite.TestCases = append(suite.TestCases, junitTestCase{
+				Name:      r.Action,
+				ClassName: className,
+				Failure:   &junitFailure{Message: failureMessage(r, ""), Content: strings.Join(r.Errors, "\n")},
+			})
+		}
+
+		suite.Tests = len(suite.TestCases)
+		for _, tc := range suite.TestCases {
+			if tc.Failure != nil {
+				suite.Failures++
+			}
+
+			if tc.Skipped != nil {
+				suite.Skipped++
+			}
+		}
+
+		suites.TestSuites = append(suites.TestSuites, suite)
+	}
+
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	return encoder.Encode(suites)
+}
+
+// resourceErrors returns errors prefixed with the resource name,
+// if there are none, all errors are returned.
+func resourceErrors(resource string, errors []string) string {
+
+	matched := make([]string, 0)
+	for _, e := range errors {
+		if strings.HasPrefix(e, resource+":") {
+			matched = append(matched, e)
+		}
+	}
+
+	if len(matched) == 0 {
+		return strings.Join(errors, "\n")
+	}
+
+	return strings.Join(matched, "\n")
+}
+
+// failureMessage returns the failure message for the record, or one of its resources,
+// resources are reported as timed out if their errors say so.
+func failureMessage(r Record, resourceErrors string) string {
+
+	if r.TimedOut && (resourceErrors == "" || strings.Contains(resourceErrors, context.DeadlineExceeded.Error())) {
+		return "timed out"
+	}
+
+	return "failed"
+}
+
+// Since returns the seconds elapsed since the given time, for the record duration.
+func Since(t time.Time) float64 {
+	return time.Since(t).Seconds()
+}
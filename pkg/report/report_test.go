@@ -0,0 +1,130 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testCollector() *Collector {
+
+	c := NewCollector()
+	c.Add(Record{
+		Serial:  "FOOBAR",
+		Vendor:  "dell",
+		Model:   "idrac9",
+		Action:  "configure",
+		Applied: []string{"syslog", "ntp"},
+		Failed:  []string{"ldap"},
+		Skipped: []string{"user"},
+		Errors:  []string{"ldap: connection reset"},
+	})
+	c.Add(Record{
+		Serial:  "BARFOO",
+		Action:  "configure",
+		Errors:  []string{"All attempts to login failed."},
+		Success: false,
+	})
+	c.Add(Record{
+		Serial:   "BAZ123",
+		Action:   "configure",
+		Applied:  []string{"syslog"},
+		Failed:   []string{"ldap", "bios"},
+		Errors:   []string{"ldap: connection reset", "bios: timed out after 10m0s: context deadline exceeded"},
+		TimedOut: true,
+	})
+
+	return c
+}
+
+// TestWriteJSON tests records are written as a JSON list.
+func TestWriteJSON(t *testing.T) {
+
+	var buf bytes.Buffer
+	err := testCollector().WriteJSON(&buf)
+	if err != nil {
+		t.Fatalf("Expected JSON report to be written, got error: %s", err)
+	}
+
+	var records []Record
+	err = json.Unmarshal(buf.Bytes(), &records)
+	if err != nil {
+		t.Fatalf("Expected valid JSON, got error: %s", err)
+	}
+
+	if len(records) != 3 || records[0].Serial != "FOOBAR" || !records[2].TimedOut {
+		t.Fatalf("Expected three records, got %+v", records)
+	}
+}
+
+// TestWriteJUnit tests each resource is reported as a test case,
+// assets that failed before resources were applied are reported as a failure,
+// and resources that timed out are reported as such.
+func TestWriteJUnit(t *testing.T) {
+
+	var buf bytes.Buffer
+	err := testCollector().WriteJUnit(&buf)
+	if err != nil {
+		t.Fatalf("Expected JUnit report to be written, got error: %s", err)
+	}
+
+	out := buf.String()
+
+	expected := []string{
+		`<testsuite name="configure.FOOBAR" tests="4" failures="1" skipped="1"`,
+		`<skipped message="unchanged since last applied">`,
+		`<testcase name="ldap" classname="configure.dell.idrac9">`,
+		`ldap: connection reset`,
+		`<testsuite name="configure.BARFOO" tests="1" failures="1"`,
+		`All attempts to login failed.`,
+		`<testsuite name="configure.BAZ123" tests="3" failures="2"`,
+		`<failure message="timed out">bios: timed out after 10m0s`,
+	}
+
+	for _, e := range expected {
+		if !strings.Contains(out, e) {
+			t.Fatalf("Expected '%s' in JUnit report:\n%s", e, out)
+		}
+	}
+
+	if strings.Count(out, `message="timed out"`) != 1 {
+		t.Errorf("Expected only the resource that timed out reported as timed out:\n%s", out)
+	}
+}
+
+// TestFlush tests the records are written and the collector is reset.
+func TestFlush(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "bmcbutler-report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := testCollector()
+	file := filepath.Join(dir, "report.json")
+
+	err = c.Flush(file, "")
+	if err != nil {
+		t.Fatalf("Expected report to be flushed, got error: %s", err)
+	}
+
+	if len(c.Records()) != 0 {
+		t.Fatalf("Expected the collector to be reset, got %d records", len(c.Records()))
+	}
+
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var records []Record
+	err = json.Unmarshal(b, &records)
+	if err != nil || len(records) != 3 {
+		t.Fatalf("Expected three records written, got %d, error: %v", len(records), err)
+	}
+}
@@ -0,0 +1,280 @@
+package resource
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/asset"
+)
+
+const (
+	// ConfigFile is the single configuration template read from the bmcCfgDir.
+	ConfigFile = "configuration.yml"
+	// BaseFile is the base layer, when declared in the bmcCfgDir the configuration is layered.
+	BaseFile = "base.yml"
+)
+
+// LayerDirs are the sub directories of the bmcCfgDir holding layers,
+// in the order the layers are merged over the base layer, each layer is named after the asset attribute
+// e.g location/ams2.yml, vendor/dell.yml, model/idrac9.yml, serial/<serial>.yml
+var LayerDirs = []string{"location", "vendor", "model", "serial"}
+
+// Layer is a configuration template merged into the asset configuration.
+type Layer struct {
+	Name     string //path of the layer relative to the bmcCfgDir.
+	Template []byte
+}
+
+// Templates holds the configuration templates read in from the bmcCfgDir,
+// either the single configuration.yml, or the base.yml along with the location, vendor, model, serial layers.
+type Templates struct {
+	layers map[string][]byte
+}
+
+// NewTemplates returns Templates with a single configuration template.
+func NewTemplates(yamlTemplate []byte) *Templates {
+	return &Templates{layers: map[string][]byte{ConfigFile: yamlTemplate}}
+}
+
+// ReadTemplates reads in the configuration templates from the given directory,
+// if a base.yml is present the layers are read in, else the configuration.yml.
+func ReadTemplates(dir string) (*Templates, error) {
+
+	_, err := os.Stat(filepath.Join(dir, BaseFile))
+	if os.IsNotExist(err) {
+		yamlTemplate, err := ReadYamlTemplate(filepath.Join(dir, ConfigFile))
+		if err != nil {
+			return nil, err
+		}
+
+		return NewTemplates(yamlTemplate), nil
+	}
+
+	base, err := ReadYamlTemplate(filepath.Join(dir, BaseFile))
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Templates{layers: map[string][]byte{BaseFile: base}}
+	for _, layerDir := range LayerDirs {
+		files, err := filepath.Glob(filepath.Join(dir, layerDir, "*.yml"))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range files {
+			yamlTemplate, err := ReadYamlTemplate(file)
+			if err != nil {
+				return nil, err
+			}
+
+			// layers are looked up by the lower cased asset attributes.
+			name := filepath.Join(layerDir, strings.ToLower(filepath.Base(file)))
+			t.layers[name] = yamlTemplate
+		}
+	}
+
+	return t, nil
+}
+
+// Layered returns true if the configuration was read in from a base.yml and layers.
+func (t *Templates) Layered() bool {
+	_, exists := t.layers[BaseFile]
+	return exists
+}
+
+// Names returns the sorted names of the templates read in.
+func (t *Templates) Names() []string {
+
+	names := make([]string, 0, len(t.layers))
+	for name := range t.layers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Equal returns true if the given templates are identical.
+func (t *Templates) Equal(o *Templates) bool {
+
+	if t == nil || o == nil {
+		return t == o
+	}
+
+	if len(t.layers) != len(o.layers) {
+		return false
+	}
+
+	for name, yamlTemplate := range t.layers {
+		if !bytes.Equal(yamlTemplate, o.layers[name]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Layers returns the layers that apply to the asset, in the order they are merged,
+// the base layer followed by the location, vendor, model, serial layers declared for the asset.
+func (t *Templates) Layers(a *asset.Asset) (layers []Layer) {
+
+	if !t.Layered() {
+		return []Layer{{Name: ConfigFile, Template: t.layers[ConfigFile]}}
+	}
+
+	layers = append(layers, Layer{Name: BaseFile, Template: t.layers[BaseFile]})
+
+	attributes := []string{a.Location, a.Vendor, a.Model, a.Serial}
+	for idx, layerDir := range LayerDirs {
+		if attributes[idx] == "" {
+			continue
+		}
+
+		name := filepath.Join(layerDir, strings.ToLower(attributes[idx])+".yml")
+		if yamlTemplate, exists := t.layers[name]; exists {
+			layers = append(layers, Layer{Name: name, Template: yamlTemplate})
+		}
+	}
+
+	return layers
+}
+
+// Provenance maps each configuration value to the layer it was declared in,
+// values are identified by their dotted yaml path e.g syslog.server
+type Provenance map[string]string
+
+// Keys returns the sorted configuration value paths.
+func (p Provenance) Keys() []string {
+
+	keys := make([]string, 0, len(p))
+	for key := range p {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// RenderLayers renders the templates that apply to the asset and deep merges them,
+// maps are merged key by key, other values - lists included, are replaced by the later layer.
+// Returns the merged yml along with the layer each value was declared in.
+func (r *Resource) RenderLayers(t *Templates) (yamlData []byte, provenance Provenance, err error) {
+
+	layers := t.Layers(r.Asset)
+	provenance = make(Provenance)
+
+	// the single configuration.yml is passed on as is.
+	if len(layers) == 1 {
+		yamlData, err = r.RenderYamlTemplate(layers[0].Template)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %s", layers[0].Name, err)
+		}
+
+		var rendered map[interface{}]interface{}
+		err = yaml.Unmarshal(yamlData, &rendered)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: unable to unmarshal config resources template: %s", layers[0].Name, err)
+		}
+
+		mergeLayer(nil, rendered, "", layers[0].Name, provenance)
+
+		return yamlData, provenance, nil
+	}
+
+	merged := make(map[interface{}]interface{})
+	for _, layer := range layers {
+		rendered, err := r.RenderYamlTemplate(layer.Template)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %s", layer.Name, err)
+		}
+
+		var values map[interface{}]interface{}
+		err = yaml.Unmarshal(rendered, &values)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: unable to unmarshal config resources template: %s", layer.Name, err)
+		}
+
+		merged = mergeLayer(merged, values, "", layer.Name, provenance)
+	}
+
+	if len(merged) == 0 {
+		return []byte{}, provenance, nil
+	}
+
+	yamlData, err = yaml.Marshal(merged)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return yamlData, provenance, nil
+}
+
+// LoadTemplates renders, merges the templates that apply to the asset,
+// returns the configuration resources along with the layer each value was declared in.
+func (r *Resource) LoadTemplates(t *Templates) (config *Config, provenance Provenance, err error) {
+
+	yamlData, provenance, err := r.RenderLayers(t)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	config, err = UnmarshalResources(yamlData, r.Strict)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return config, provenance, nil
+}
+
+// mergeLayer merges the layer values into dst, recording the layer of each value merged.
+func mergeLayer(dst, src map[interface{}]interface{}, path string, layer string, provenance Provenance) map[interface{}]interface{} {
+
+	if dst == nil {
+		dst = make(map[interface{}]interface{})
+	}
+
+	for k, v := range src {
+		key := fmt.Sprintf("%v", k)
+		if path != "" {
+			key = path + "." + key
+		}
+
+		srcMap, srcIsMap := v.(map[interface{}]interface{})
+		dstMap, dstIsMap := dst[k].(map[interface{}]interface{})
+
+		if srcIsMap {
+			// values declared by the replaced layer no longer apply.
+			if !dstIsMap {
+				forget(provenance, key)
+				dstMap = nil
+			}
+
+			dst[k] = mergeLayer(dstMap, srcMap, key, layer, provenance)
+			continue
+		}
+
+		forget(provenance, key)
+		dst[k] = v
+		provenance[key] = layer
+	}
+
+	return dst
+}
+
+// forget removes the provenance of the value at the path and any values nested under it.
+func forget(provenance Provenance, path string) {
+	for key := range provenance {
+		if key == path || strings.HasPrefix(key, path+".") {
+			delete(provenance, key)
+		}
+	}
+}
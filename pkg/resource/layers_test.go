@@ -0,0 +1,137 @@
+package resource
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/asset"
+)
+
+func writeLayers(t *testing.T, layers map[string]string) string {
+
+	dir, err := ioutil.TempDir("", "bmcbutler-layers")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, content := range layers {
+		file := filepath.Join(dir, name)
+		err = os.MkdirAll(filepath.Dir(file), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = ioutil.WriteFile(file, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+// TestLoadTemplatesLayered tests the layers that apply to the asset are deep merged in order,
+// and the layer each value was declared in is recorded.
+func TestLoadTemplatesLayered(t *testing.T) {
+
+	dir := writeLayers(t, map[string]string{
+		"base.yml":          "syslog:\n  server: syslog.example.com\n  port: 514\nntp:\n  server1: ntp0.example.com\n",
+		"location/ams2.yml": "syslog:\n  server: syslog.ams2.example.com\n",
+		"vendor/dell.yml":   "ntp:\n  timezone: CET\n",
+		"vendor/hp.yml":     "license:\n  key: FOO\n",
+		"serial/abc123.yml": "syslog:\n  port: 1514\n",
+	})
+	defer os.RemoveAll(dir)
+
+	templates, err := ReadTemplates(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error reading templates: %s", err)
+	}
+
+	if !templates.Layered() {
+		t.Fatalf("Expected layered templates")
+	}
+
+	a := asset.Asset{Location: "ams2", Vendor: "Dell", Serial: "ABC123"}
+	r := Resource{Log: logrus.New(), Asset: &a, MaskSecrets: true}
+
+	config, provenance, err := r.LoadTemplates(templates)
+	if err != nil {
+		t.Fatalf("Unexpected error loading templates: %s", err)
+	}
+
+	if config.Syslog.Server != "syslog.ams2.example.com" || config.Syslog.Port != 1514 {
+		t.Errorf("Expected syslog merged from the location, serial layers, got %+v", config.Syslog)
+	}
+
+	if config.Ntp.Server1 != "ntp0.example.com" || config.Ntp.Timezone != "CET" {
+		t.Errorf("Expected ntp merged from the base, vendor layers, got %+v", config.Ntp)
+	}
+
+	if config.License != nil {
+		t.Errorf("Expected the hp vendor layer not to apply")
+	}
+
+	expected := map[string]string{
+		"syslog.server": "location/ams2.yml",
+		"syslog.port":   "serial/abc123.yml",
+		"ntp.server1":   "base.yml",
+		"ntp.timezone":  "vendor/dell.yml",
+	}
+
+	for key, layer := range expected {
+		if provenance[key] != layer {
+			t.Errorf("Expected %s from %s, got %s", key, layer, provenance[key])
+		}
+	}
+}
+
+// TestLoadTemplatesSingle tests configuration.yml is read in when there is no base.yml.
+func TestLoadTemplatesSingle(t *testing.T) {
+
+	dir := writeLayers(t, map[string]string{
+		"configuration.yml": "syslog:\n  server: <%= vendor %>.example.com\n",
+		"vendor/dell.yml":   "syslog:\n  server: ignored\n",
+	})
+	defer os.RemoveAll(dir)
+
+	templates, err := ReadTemplates(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error reading templates: %s", err)
+	}
+
+	a := asset.Asset{Vendor: "dell"}
+	r := Resource{Log: logrus.New(), Asset: &a}
+
+	config, provenance, err := r.LoadTemplates(templates)
+	if err != nil {
+		t.Fatalf("Unexpected error loading templates: %s", err)
+	}
+
+	if templates.Layered() || config.Syslog.Server != "dell.example.com" || provenance["syslog.server"] != ConfigFile {
+		t.Errorf("Expected syslog from configuration.yml, got %+v, %+v", config.Syslog, provenance)
+	}
+
+	if !templates.Equal(NewTemplates([]byte("syslog:\n  server: <%= vendor %>.example.com\n"))) {
+		t.Errorf("Expected templates to equal")
+	}
+}
+
+// TestMergeLayerReplaced tests values replaced by a later layer no longer list the earlier layer.
+func TestMergeLayerReplaced(t *testing.T) {
+
+	provenance := make(Provenance)
+	merged := mergeLayer(nil, map[interface{}]interface{}{
+		"bios": map[interface{}]interface{}{"dell": map[interface{}]interface{}{"foo": "bar"}},
+	}, "", "base.yml", provenance)
+
+	merged = mergeLayer(merged, map[interface{}]interface{}{"bios": "none"}, "", "vendor/hp.yml", provenance)
+
+	if merged["bios"] != "none" || len(provenance) != 1 || provenance["bios"] != "vendor/hp.yml" {
+		t.Errorf("Expected bios replaced by vendor/hp.yml, got %+v, %+v", merged, provenance)
+	}
+}
@@ -15,6 +15,7 @@
 package resource
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"strings"
@@ -32,11 +33,16 @@ import (
 // Resource struct holds configuration resource related attributes
 // Config resources are configuration parameters butlers apply to assets.
 type Resource struct {
-	Log     *logrus.Logger
-	Asset   *asset.Asset
-	Secrets *secrets.Store
+	Log         *logrus.Logger
+	Asset       *asset.Asset
+	Secrets     secrets.Provider
+	MaskSecrets bool //When set, secrets are not looked up and rendered masked.
+	Strict      bool //When set, unknown keys in the rendered yml are rejected.
 }
 
+// Masked is the value secrets are rendered as when secrets are masked.
+const Masked = "MASKED"
+
 // ReadYamlTemplate reads the given config .yml file, returns it as a slice of bytes.
 func ReadYamlTemplate(yamlFile string) (yamlTemplate []byte, err error) {
 
@@ -56,10 +62,7 @@ func ReadYamlTemplate(yamlFile string) (yamlTemplate []byte, err error) {
 }
 
 // RenderYamlTemplate renders templated values in the given config .yml, returns it as a slice of bytes.
-func (r *Resource) RenderYamlTemplate(yamlTemplate []byte) (yamlData []byte) {
-
-	log := r.Log
-	component := "RenderYamlTemplate"
+func (r *Resource) RenderYamlTemplate(yamlTemplate []byte) (yamlData []byte, err error) {
 
 	//render any templated data
 	ctx := plush.NewContext()
@@ -73,8 +76,13 @@ func (r *Resource) RenderYamlTemplate(yamlTemplate []byte) (yamlData []byte) {
 	ctx.Set("ipaddress", strings.ToLower(r.Asset.IPAddress))
 	ctx.Set("extra", r.Asset.Extra)
 
-	// r.Secrets is non nil if the bmcbutler.yml declares secretsFromVault: true
-	if r.Secrets != nil {
+	// r.Secrets is non nil if the bmcbutler.yml declares secretsFromVault: true or secrets providers
+	switch {
+	case r.MaskSecrets:
+		ctx.Set("lookup_secret", func(s string) string {
+			return Masked
+		})
+	case r.Secrets != nil:
 		ctx.Set("lookup_secret", func(s string) string {
 			secret, _ := r.Secrets.Get(s)
 			return secret
@@ -84,30 +92,144 @@ func (r *Resource) RenderYamlTemplate(yamlTemplate []byte) (yamlData []byte) {
 	//render, plush is awesome!
 	s, err := plush.Render(string(yamlTemplate), ctx)
 	if err != nil {
-		log.WithFields(logrus.Fields{
-			"component": component,
-			"error":     err,
-		}).Fatal("Error rendering configuration yml template.")
+		return []byte{}, fmt.Errorf("error rendering configuration yml template: %s", err)
+	}
+
+	return []byte(s), nil
+}
+
+// Config holds the rendered configuration resources,
+// resources applied through bmclib and Redfish targets applied through the BMC Redfish API.
+type Config struct {
+	cfgresources.ResourcesConfig `yaml:",inline"`
+	Redfish                      []*RedfishTarget `yaml:"redfish"`
+}
+
+// RedfishTarget declares a Redfish resource and the properties to be set on it,
+// properties are PATCHed to the PatchPath if declared, else to the Path,
+// e.g the BIOS Attributes are read from /redfish/v1/Systems/1/Bios and PATCHed to /redfish/v1/Systems/1/Bios/Settings
+type RedfishTarget struct {
+	Path       string                 `yaml:"path"`
+	PatchPath  string                 `yaml:"patchPath"`
+	Properties map[string]interface{} `yaml:"properties"`
+}
+
+// Declared returns the configuration resources declared,
+// in the order they are applied.
+// nolint: gocyclo
+func (c *Config) Declared() (resources []string) {
+
+	declared := []struct {
+		resource string
+		declared bool
+	}{
+		{"user", c.User != nil},
+		{"syslog", c.Syslog != nil},
+		{"ntp", c.Ntp != nil},
+		{"ldap", c.Ldap != nil},
+		{"ldap_group", c.LdapGroup != nil && c.Ldap != nil},
+		{"license", c.License != nil},
+		{"network", c.Network != nil},
+		{"bios", c.Bios != nil},
+		{"https_cert", c.HTTPSCert != nil},
+		{"power", c.Power != nil},
+		{"redfish", len(c.Redfish) > 0},
+		{"setup_chassis", c.SetupChassis != nil},
+	}
+
+	for _, d := range declared {
+		if d.declared {
+			resources = append(resources, d.resource)
+		}
+	}
+
+	return resources
+}
+
+// LoadResources gets the template rendered and unmarshals the resulting yml,
+// nil is returned if no configuration was declared.
+func (r *Resource) LoadResources(yamlTemplate []byte) (config *Config, err error) {
+
+	yamlData, err := r.RenderYamlTemplate(yamlTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	return UnmarshalResources(yamlData, r.Strict)
+}
+
+// UnmarshalResources unmarshals the rendered yml, unknown keys are rejected if strict,
+// nil is returned if no configuration was declared.
+func UnmarshalResources(yamlData []byte, strict bool) (config *Config, err error) {
+
+	if strict {
+		err = yaml.UnmarshalStrict(yamlData, &config)
+	} else {
+		err = yaml.Unmarshal(yamlData, &config)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable to unmarshal config resources template: %s", err)
 	}
 
-	return []byte(s)
+	return config, nil
 }
 
-// LoadConfigResources gets the template rendered and unmarshals the resulting yml.
-func (r *Resource) LoadConfigResources(yamlTemplate []byte) (config *cfgresources.ResourcesConfig) {
+// LoadConfigResources gets the template rendered and unmarshals the resulting yml,
+// returning the resources applied through bmclib.
+func (r *Resource) LoadConfigResources(yamlTemplate []byte) (config *cfgresources.ResourcesConfig, err error) {
+
+	c, err := r.LoadResources(yamlTemplate)
+	if err != nil || c == nil {
+		return nil, err
+	}
+
+	return &c.ResourcesConfig, nil
+}
 
-	component := "LoadConfigResources"
-	log := r.Log
+// maskedKeys are keys whose values are masked in the rendered yml by MaskYaml.
+var maskedKeys = []string{"password", "secret", "token"}
 
-	yamlData := r.RenderYamlTemplate(yamlTemplate)
+// MaskYaml masks the values of password, secret, token keys in the rendered yml,
+// the order of the keys is retained.
+func MaskYaml(yamlData []byte) ([]byte, error) {
 
-	err := yaml.Unmarshal(yamlData, &config)
+	var data yaml.MapSlice
+	err := yaml.Unmarshal(yamlData, &data)
 	if err != nil {
-		log.WithFields(logrus.Fields{
-			"component": component,
-			"error":     err,
-		}).Fatal("Unable to Unmarshal config resources template.")
+		return []byte{}, err
 	}
 
-	return config
+	return yaml.Marshal(maskValue(data))
+}
+
+func maskValue(value interface{}) interface{} {
+
+	switch v := value.(type) {
+	case yaml.MapSlice:
+		for idx, item := range v {
+			key := strings.ToLower(fmt.Sprintf("%v", item.Key))
+			masked := false
+			for _, k := range maskedKeys {
+				if strings.Contains(key, k) {
+					masked = true
+				}
+			}
+
+			if masked && item.Value != nil {
+				v[idx].Value = Masked
+				continue
+			}
+
+			v[idx].Value = maskValue(item.Value)
+		}
+		return v
+	case []interface{}:
+		for idx := range v {
+			v[idx] = maskValue(v[idx])
+		}
+		return v
+	default:
+		return v
+	}
 }
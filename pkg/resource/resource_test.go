@@ -40,6 +40,8 @@ func TestRenderYamlTemplate(t *testing.T) {
 			Model:  "002",
 			Type:   "Server",
 		},
+		// the sample config looks up secrets.
+		MaskSecrets: true,
 	}
 
 	resourceConfig := "../../samples/cfg/configuration.yml"
@@ -51,7 +53,11 @@ func TestRenderYamlTemplate(t *testing.T) {
 	}
 
 	// render as plush template
-	rendered := r.RenderYamlTemplate(configBytes)
+	rendered, err := r.RenderYamlTemplate(configBytes)
+	if err != nil {
+		t.Fatalf("Error rendering template: %s", err)
+	}
+
 	if !strings.Contains(string(rendered), "cn=acme,cn=bmcUsers") {
 		t.Fatal("Expected string not found in rendered template")
 	}
@@ -77,9 +83,15 @@ func TestLoadConfigResources(t *testing.T) {
 			Model:  "002",
 			Type:   "Server",
 		},
+		// the sample config looks up secrets.
+		MaskSecrets: true,
+	}
+
+	configResources, err := r.LoadConfigResources(configBytes)
+	if err != nil {
+		t.Fatalf("Error loading config resources: %s", err)
 	}
 
-	configResources := r.LoadConfigResources(configBytes)
 	if fmt.Sprintf("%T", configResources) != "*cfgresources.ResourcesConfig" {
 		t.Fatal("Expected return type does not match *cfgresources.ResourcesConfig")
 	}
@@ -89,3 +101,35 @@ func TestLoadConfigResources(t *testing.T) {
 	}
 
 }
+
+// Test template render, unmarshal errors are returned
+// and secrets are masked.
+func TestLoadResourcesErrors(t *testing.T) {
+
+	r := Resource{Log: logrus.New(), Asset: &asset.Asset{Vendor: "dell"}}
+
+	_, err := r.LoadResources([]byte("syslog:\n  server: <%= lookup_secret(\"syslog\") %>\n"))
+	if err == nil {
+		t.Fatal("Expected error rendering template with secrets not loaded")
+	}
+
+	_, err = r.LoadResources([]byte("syslog: [\n"))
+	if err == nil {
+		t.Fatal("Expected error unmarshalling invalid yaml")
+	}
+
+	r.MaskSecrets = true
+	config, err := r.LoadResources([]byte("syslog:\n  server: <%= lookup_secret(\"syslog\") %>\nredfish:\n  - path: /redfish/v1/Managers/1\n"))
+	if err != nil {
+		t.Fatalf("Expected config to be loaded, got error: %s", err)
+	}
+
+	if config.Syslog.Server != Masked || strings.Join(config.Declared(), ",") != "syslog,redfish" {
+		t.Fatalf("Unexpected config %+v, declared %v", config, config.Declared())
+	}
+
+	masked, err := MaskYaml([]byte("user:\n  - name: admin\n    password: foo\nntp:\n  server1: ntp0\n"))
+	if err != nil || strings.Contains(string(masked), "foo") || !strings.Contains(string(masked), "ntp0") {
+		t.Fatalf("Expected password to be masked, got %s, error: %v", masked, err)
+	}
+}
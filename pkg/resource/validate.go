@@ -0,0 +1,267 @@
+package resource
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/go-playground/validator.v9"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/asset"
+)
+
+// DefaultSampleAssets are the assets the configuration is validated for when no sample assets are declared,
+// one for each vendor, model supported by bmclib.
+var DefaultSampleAssets = []asset.Asset{
+	{Vendor: "dell", Model: "idrac8", Type: "server"},
+	{Vendor: "dell", Model: "idrac9", Type: "server"},
+	{Vendor: "dell", Model: "m1000e", Type: "chassis"},
+	{Vendor: "hp", Model: "ilo4", Type: "server"},
+	{Vendor: "hp", Model: "ilo5", Type: "server"},
+	{Vendor: "hp", Model: "c7000", Type: "chassis"},
+	{Vendor: "supermicro", Model: "supermicrox", Type: "server"},
+}
+
+// sample asset attributes set when not declared, for templates that refer to them.
+const (
+	sampleSerial    = "SAMPLE0001"
+	sampleIPAddress = "192.0.2.1"
+)
+
+var (
+	validRoles           = []string{"admin", "user"}
+	validPowerRegulators = []string{"dynamic", "static_low", "static_high", "os_control"}
+	validCertAttributes  = []string{
+		"commonName",
+		"organizationName",
+		"organizationUnit",
+		"locality",
+		"stateName",
+		"countryCode",
+		"email",
+		"subjectAltName",
+	}
+)
+
+// ValidationErrors lists the configuration values that failed validation.
+type ValidationErrors []string
+
+func (v ValidationErrors) Error() string {
+	return strings.Join(v, "; ")
+}
+
+// AssetValidation holds the outcome of the configuration validated for a sample asset.
+type AssetValidation struct {
+	Asset  asset.Asset
+	Errors []string
+}
+
+// SampleAssets returns the sample assets for each of the locations,
+// sample assets with a location declared are returned as is.
+func SampleAssets(samples []asset.Asset, locations []string) (assets []asset.Asset) {
+
+	if len(samples) == 0 {
+		samples = DefaultSampleAssets
+	}
+
+	for _, a := range samples {
+		if a.Serial == "" {
+			a.Serial = sampleSerial
+		}
+
+		if a.IPAddress == "" {
+			a.IPAddress = sampleIPAddress
+			a.IPAddresses = []string{sampleIPAddress}
+		}
+
+		if a.Location != "" || len(locations) == 0 {
+			assets = append(assets, a)
+			continue
+		}
+
+		for _, location := range locations {
+			a.Location = location
+			assets = append(assets, a)
+		}
+	}
+
+	return assets
+}
+
+// ValidateTemplates renders the templates for each of the assets and validates the resulting configuration,
+// secrets are masked and not looked up, unknown keys are rejected. Returns the assets the configuration is invalid for.
+func ValidateTemplates(t *Templates, assets []asset.Asset, log *logrus.Logger) (invalid []AssetValidation) {
+
+	for idx := range assets {
+		a := assets[idx]
+		r := Resource{Log: log, Asset: &a, MaskSecrets: true, Strict: true}
+
+		config, _, err := r.LoadTemplates(t)
+		if err == nil && config != nil {
+			err = config.Validate()
+		}
+
+		if err == nil {
+			continue
+		}
+
+		v := AssetValidation{Asset: assets[idx]}
+		if errs, ok := err.(ValidationErrors); ok {
+			v.Errors = errs
+		} else {
+			v.Errors = []string{err.Error()}
+		}
+
+		invalid = append(invalid, v)
+	}
+
+	return invalid
+}
+
+func oneOf(value string, valid []string) bool {
+	for _, v := range valid {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func validPort(port int) bool {
+	return port >= 0 && port <= 65535
+}
+
+// Validate checks the configuration values are valid before they are applied,
+// returns ValidationErrors listing the values that are not.
+// nolint: gocyclo
+func (c *Config) Validate() error {
+
+	var errs ValidationErrors
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Sprintf(format, args...))
+	}
+
+	for idx, user := range c.User {
+		if user == nil {
+			continue
+		}
+
+		if user.Name == "" {
+			add("user[%d].name: required", idx)
+		}
+
+		if !oneOf(user.Role, validRoles) {
+			add("user[%d].role: %q expected to be one of %s", idx, user.Role, strings.Join(validRoles, ", "))
+		}
+	}
+
+	if c.Syslog != nil {
+		if c.Syslog.Enable && c.Syslog.Server == "" {
+			add("syslog.server: required")
+		}
+
+		if !validPort(c.Syslog.Port) {
+			add("syslog.port: %d out of range", c.Syslog.Port)
+		}
+	}
+
+	if c.Ldap != nil {
+		if c.Ldap.Enable && c.Ldap.Server == "" {
+			add("ldap.server: required")
+		}
+
+		if !validPort(c.Ldap.Port) {
+			add("ldap.port: %d out of range", c.Ldap.Port)
+		}
+	}
+
+	for idx, group := range c.LdapGroup {
+		if group == nil {
+			continue
+		}
+
+		if group.Group == "" {
+			add("ldapGroup[%d].group: required", idx)
+		}
+
+		if !oneOf(group.Role, validRoles) {
+			add("ldapGroup[%d].role: %q expected to be one of %s", idx, group.Role, strings.Join(validRoles, ", "))
+		}
+	}
+
+	if c.Network != nil {
+		ports := []struct {
+			key  string
+			port int
+		}{
+			{"sshPort", c.Network.SSHPort},
+			{"ipmiPort", c.Network.IpmiPort},
+			{"kvmMediaPort", c.Network.KVMMediaPort},
+			{"kvmConsolePort", c.Network.KVMConsolePort},
+		}
+
+		for _, p := range ports {
+			if !validPort(p.port) {
+				add("network.%s: %d out of range", p.key, p.port)
+			}
+		}
+	}
+
+	if c.Power != nil && c.Power.HPE != nil && !oneOf(c.Power.HPE.PowerRegulator, validPowerRegulators) {
+		add("power.hpe.regulator: %q expected to be one of %s", c.Power.HPE.PowerRegulator, strings.Join(validPowerRegulators, ", "))
+	}
+
+	if c.Bios != nil && c.Bios.Dell != nil && c.Bios.Dell.Idrac9BiosSettings != nil {
+		err := validator.New().Struct(c.Bios.Dell.Idrac9BiosSettings)
+		if fieldErrs, ok := err.(validator.ValidationErrors); ok {
+			for _, fieldErr := range fieldErrs {
+				add("bios.dell.idrac9bios.%s: %q expected to be one of %s", fieldErr.Field(), fieldErr.Value(), fieldErr.Param())
+			}
+		} else if err != nil {
+			add("bios.dell.idrac9bios: %s", err)
+		}
+	}
+
+	if c.HTTPSCert != nil {
+		if c.HTTPSCert.Attributes == nil {
+			add("httpsCert.attributes: required")
+		} else if c.HTTPSCert.Attributes.CommonName == "" {
+			add("httpsCert.attributes.commonName: required")
+		}
+
+		if c.HTTPSCert.RenewBeforeExpiry < 0 {
+			add("httpsCert.renewBeforeExpiry: %s expected to be positive", c.HTTPSCert.RenewBeforeExpiry)
+		}
+
+		for _, attribute := range c.HTTPSCert.ValidateAttributes {
+			if !oneOf(attribute, validCertAttributes) {
+				add("httpsCert.validateAttributes: %q expected to be one of %s", attribute, strings.Join(validCertAttributes, ", "))
+			}
+		}
+	}
+
+	for idx, target := range c.Redfish {
+		if target == nil {
+			continue
+		}
+
+		if !strings.HasPrefix(target.Path, "/redfish/") {
+			add("redfish[%d].path: %q expected to begin with /redfish/", idx, target.Path)
+		}
+
+		if target.PatchPath != "" && !strings.HasPrefix(target.PatchPath, "/redfish/") {
+			add("redfish[%d].patchPath: %q expected to begin with /redfish/", idx, target.PatchPath)
+		}
+
+		if len(target.Properties) == 0 {
+			add("redfish[%d].properties: required", idx)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
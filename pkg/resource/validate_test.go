@@ -0,0 +1,122 @@
+package resource
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/asset"
+)
+
+// TestUnmarshalResourcesStrict tests unknown keys are rejected when strict, and ignored otherwise.
+func TestUnmarshalResourcesStrict(t *testing.T) {
+
+	_, err := UnmarshalResources([]byte("ldapgroup:\n  - role: admin\n"), true)
+	if err == nil || !strings.Contains(err.Error(), "ldapgroup") {
+		t.Fatalf("Expected unknown key ldapgroup to be rejected, got %v", err)
+	}
+
+	_, err = UnmarshalResources([]byte("ldapgroup:\n  - role: admin\n"), false)
+	if err != nil {
+		t.Fatalf("Expected unknown key ldapgroup to be ignored, got %s", err)
+	}
+
+	_, err = UnmarshalResources([]byte("ldapGroup:\n  - role: admin\n    group: foo\n"), true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+}
+
+// TestConfigValidate tests field level rules are checked.
+func TestConfigValidate(t *testing.T) {
+
+	yamlData := `
+user:
+  - name: Administrator
+    role: admn
+syslog:
+  server: syslog.example.com
+  port: 70000
+power:
+  hpe:
+    regulator: turbo
+bios:
+  dell:
+    idrac9bios:
+      PxeDev1EnDis: Enabled
+      PxeDev2EnDis: enabled
+      PxeDev3EnDis: Disabled
+      PxeDev4EnDis: Disabled
+httpsCert:
+  validateAttributes:
+    - cn
+  attributes:
+    organizationName: Acme B.V.
+redfish:
+  - path: /Systems/1/Bios
+`
+
+	config, err := UnmarshalResources([]byte(yamlData), true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	err = config.Validate()
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("Expected ValidationErrors, got %v", err)
+	}
+
+	expected := []string{
+		"user[0].role",
+		"syslog.port",
+		"power.hpe.regulator",
+		"bios.dell.idrac9bios.PxeDev2EnDis",
+		"httpsCert.attributes.commonName",
+		"httpsCert.validateAttributes",
+		"redfish[0].path",
+		"redfish[0].properties",
+	}
+
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected %d errors, got %d: %s", len(expected), len(errs), errs)
+	}
+
+	for idx, key := range expected {
+		if !strings.HasPrefix(errs[idx], key+":") {
+			t.Errorf("Expected error for %s, got %s", key, errs[idx])
+		}
+	}
+}
+
+// TestValidateTemplates tests the sample configuration is valid for the default sample assets,
+// and invalid values are reported for the sample assets they are rendered for.
+func TestValidateTemplates(t *testing.T) {
+
+	configBytes, err := ReadYamlTemplate("../../samples/cfg/configuration.yml")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	assets := SampleAssets(nil, []string{"ams2", "lhr4"})
+	if len(assets) != 2*len(DefaultSampleAssets) || assets[0].Serial == "" || assets[0].IPAddress == "" {
+		t.Fatalf("Expected sample assets in each location, got %+v", assets)
+	}
+
+	invalid := ValidateTemplates(NewTemplates(configBytes), assets, logrus.New())
+	if len(invalid) > 0 {
+		t.Fatalf("Expected sample configuration to be valid, got %+v", invalid)
+	}
+
+	templates := NewTemplates([]byte(`<%= if ( vendor == "hp" ) { %>
+user:
+  - name: Administrator
+    role: admn
+<% } %>`))
+
+	invalid = ValidateTemplates(templates, SampleAssets([]asset.Asset{{Vendor: "dell"}, {Vendor: "hp"}}, nil), logrus.New())
+	if len(invalid) != 1 || invalid[0].Asset.Vendor != "hp" || len(invalid[0].Errors) != 1 {
+		t.Fatalf("Expected configuration invalid for the hp asset, got %+v", invalid)
+	}
+}
@@ -0,0 +1,105 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"net"
+	"regexp"
+	"time"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+)
+
+// kinds of errors retried, BMCs report most errors as strings,
+// so errors are classified by matching their message.
+var kinds = []struct {
+	kind    string
+	pattern *regexp.Regexp
+}{
+	{"connection", regexp.MustCompile(`(?i)connection (refused|reset)|broken pipe|no route to host|network is unreachable|\bEOF\b|ScanAndConnect attempt unsuccessful`)},
+	{"timeout", regexp.MustCompile(`(?i)timeout|timed out`)},
+	{"5xx", regexp.MustCompile(`(?i)(status|code|http|error)\D{0,15}\b5\d\d\b|\b5\d\d (internal server error|bad gateway|service unavailable|gateway timeout)`)},
+	{"session", regexp.MustCompile(`(?i)(too many|maximum|max|limit|no (available|free))\D{0,20}sessions?|sessions?\D{0,20}(limit|exceeded|full|maximum)|RAC0218`)},
+	{"login", regexp.MustCompile(`All attempts to login failed`)},
+}
+
+// Kind returns the kind of the error, an empty string if it is not of a known kind.
+func Kind(err error) string {
+
+	if err == nil {
+		return ""
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return "timeout"
+	}
+
+	for _, k := range kinds {
+		if k.pattern.MatchString(err.Error()) {
+			return k.kind
+		}
+	}
+
+	return ""
+}
+
+// Retryable returns true if the error is of a kind the policy retries,
+// errors returned since the context is done are never retried.
+func Retryable(policy *config.RetryPolicy, err error) bool {
+
+	if err == nil || policy == nil {
+		return false
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	kind := Kind(err)
+	for _, k := range policy.Retryable {
+		if k == kind {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Backoff returns the wait before the given attempt, the backoff is doubled on each attempt up to the max backoff.
+func Backoff(policy *config.RetryPolicy, attempt int) time.Duration {
+
+	backoff := policy.Backoff
+	for i := 2; i < attempt; i++ {
+		backoff *= 2
+		if policy.MaxBackoff > 0 && backoff >= policy.MaxBackoff {
+			return policy.MaxBackoff
+		}
+	}
+
+	if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+		return policy.MaxBackoff
+	}
+
+	return backoff
+}
+
+// Do calls fn until it returns nil or an error that is not retryable, the policy attempts are made or the context is done,
+// returns the number of attempts made and the last error, a nil policy makes a single attempt.
+func Do(ctx context.Context, policy *config.RetryPolicy, fn func() error) (attempts int, err error) {
+
+	for {
+		attempts++
+		err = fn()
+
+		if policy == nil || attempts >= policy.Attempts || !Retryable(policy, err) {
+			return attempts, err
+		}
+
+		select {
+		case <-time.After(Backoff(policy, attempts+1)):
+		case <-ctx.Done():
+			return attempts, err
+		}
+	}
+}
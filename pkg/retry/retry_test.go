@@ -0,0 +1,102 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+)
+
+// TestKind tests BMC errors are classified by kind.
+func TestKind(t *testing.T) {
+
+	tc := []struct {
+		err  string
+		kind string
+	}{
+		{"Post https://10.0.0.1/data/login: dial tcp 10.0.0.1:443: connect: connection refused", "connection"},
+		{"Get https://10.0.0.1/redfish/v1: net/http: TLS handshake timeout", "timeout"},
+		{"Received a non 200 response code: 503", "5xx"},
+		{"500 Internal Server Error", "5xx"},
+		{"RAC0218: The maximum number of user sessions is reached.", "session"},
+		{"Login failed, session limit exceeded", "session"},
+		{"All attempts to login failed.", "login"},
+		{"syslog port 514 is invalid", ""},
+		{"Unknown user role: foo", ""},
+	}
+
+	for _, c := range tc {
+		kind := Kind(errors.New(c.err))
+		if kind != c.kind {
+			t.Errorf("Expected '%s' to be of kind '%s', got '%s'", c.err, c.kind, kind)
+		}
+	}
+}
+
+// TestBackoff tests the backoff is doubled on each attempt, up to the max backoff.
+func TestBackoff(t *testing.T) {
+
+	policy := &config.RetryPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Second}
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for idx, e := range expected {
+		backoff := Backoff(policy, idx+2)
+		if backoff != e {
+			t.Errorf("Expected a backoff of %s before attempt %d, got %s", e, idx+2, backoff)
+		}
+	}
+}
+
+// TestDo tests retryable errors are retried up to the policy attempts,
+// and other errors are returned without being retried.
+func TestDo(t *testing.T) {
+
+	policy := &config.RetryPolicy{Attempts: 3, Backoff: time.Millisecond, MaxBackoff: time.Millisecond, Retryable: []string{"5xx", "session"}}
+
+	var calls int
+	attempts, err := Do(context.Background(), policy, func() error {
+		calls++
+		if calls < 2 {
+			return errors.New("Received a non 200 response code: 503")
+		}
+
+		return nil
+	})
+
+	if err != nil || attempts != 2 {
+		t.Errorf("Expected success on the second attempt, got %d attempts, error: %v", attempts, err)
+	}
+
+	attempts, _ = Do(context.Background(), policy, func() error { return errors.New("RAC0218: max sessions") })
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts)
+	}
+
+	attempts, _ = Do(context.Background(), policy, func() error { return errors.New("connection refused") })
+	if attempts != 1 {
+		t.Errorf("Expected errors not declared retryable to not be retried, got %d attempts", attempts)
+	}
+
+	attempts, _ = Do(context.Background(), policy, func() error { return fmt.Errorf("503: %w", context.DeadlineExceeded) })
+	if attempts != 1 {
+		t.Errorf("Expected timed out resources to not be retried, got %d attempts", attempts)
+	}
+
+	attempts, _ = Do(context.Background(), nil, func() error { return errors.New("503 Service Unavailable") })
+	if attempts != 1 {
+		t.Errorf("Expected a single attempt without a policy, got %d attempts", attempts)
+	}
+
+	// retries are abandoned once the context is done.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	policy.Backoff, policy.MaxBackoff = time.Minute, time.Minute
+	attempts, _ = Do(ctx, policy, func() error { return errors.New("503 Service Unavailable") })
+	if attempts != 1 {
+		t.Errorf("Expected retries to be abandoned once the context is done, got %d attempts", attempts)
+	}
+}
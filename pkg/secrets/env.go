@@ -0,0 +1,32 @@
+package secrets
+
+import (
+	"os"
+	"regexp"
+)
+
+var envInvalidChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// Env looks up secrets from environment variables named <prefix><secret>,
+// characters not valid in environment variable names are replaced with '_',
+// e.g the secret ams2/Administrator is looked up from BMCBUTLER_ams2_Administrator with the prefix BMCBUTLER_.
+type Env struct {
+	Prefix string
+}
+
+// NewEnv returns the environment variables secrets provider.
+func NewEnv(prefix string) *Env {
+	return &Env{Prefix: prefix}
+}
+
+// Get returns the secret from the environment variable.
+func (e *Env) Get(k string) (string, error) {
+
+	name := e.Prefix + envInvalidChars.ReplaceAllString(k, "_")
+	value, exists := os.LookupEnv(name)
+	if !exists {
+		return "", &NotFoundError{Secret: k, Provider: "env", Hint: "expected env var " + name + " to be set"}
+	}
+
+	return value, nil
+}
@@ -0,0 +1,75 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// execNotFoundStatus is the exit status the secrets helper returns when a secret is not found.
+const execNotFoundStatus = 2
+
+// Exec looks up secrets with a helper executable, the secret name is passed as the last arg,
+// the helper returns the secret on STDOUT, and exits with status 2 if the secret is not found.
+// Secrets are cached once looked up.
+type Exec struct {
+	Bin     string
+	Args    []string
+	Timeout time.Duration
+	cache   map[string]string
+	mutex   sync.Mutex
+}
+
+// NewExec returns the secrets helper executable provider.
+func NewExec(bin string, args []string, timeout time.Duration) *Exec {
+	return &Exec{Bin: bin, Args: args, Timeout: timeout, cache: make(map[string]string)}
+}
+
+// Get returns the secret returned by the helper.
+func (e *Exec) Get(k string) (string, error) {
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if value, exists := e.cache[k]; exists {
+		return value, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, e.Bin, append(append([]string{}, e.Args...), k)...)
+
+	var outBuf, errBuf bytes.Buffer
+	cmd.Stdout = &outBuf
+	cmd.Stderr = &errBuf
+
+	// To ignore SIGINTs received by bmcbutler,
+	// the commands are spawned in its own process group.
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid: true,
+	}
+
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", fmt.Errorf("secrets helper %s timed out after %s looking up %s", e.Bin, e.Timeout, k)
+	}
+
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == execNotFoundStatus {
+		return "", &NotFoundError{Secret: k, Provider: "exec", Hint: strings.TrimSpace(errBuf.String())}
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("secrets helper %s returned error looking up %s: %s %s", e.Bin, k, err, strings.TrimSpace(errBuf.String()))
+	}
+
+	value := strings.TrimSuffix(outBuf.String(), "\n")
+	e.cache[k] = value
+
+	return value, nil
+}
@@ -0,0 +1,109 @@
+package secrets
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	"gopkg.in/yaml.v2"
+)
+
+// File holds a copy of secrets read from a YAML file,
+// nested secrets are looked up as <key>/<secret>, e.g per location secrets as ams2/Administrator.
+type File struct {
+	Path string
+	data map[string]string
+}
+
+// NewFile returns the secrets provider for the YAML file,
+// files encrypted with SOPS (e.g with age keys) are decrypted with the sops bin,
+// the sops bin is expected to find the decryption keys as it would on the command line, e.g SOPS_AGE_KEY_FILE.
+func NewFile(path string, sopsBin string) (*File, error) {
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read secrets file: %s", err)
+	}
+
+	var values map[string]interface{}
+	err = yaml.Unmarshal(b, &values)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse secrets file %s: %s", path, err)
+	}
+
+	if _, encrypted := values["sops"]; encrypted {
+		b, err = sopsDecrypt(sopsBin, path)
+		if err != nil {
+			return nil, err
+		}
+
+		values = nil
+		err = yaml.Unmarshal(b, &values)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse decrypted secrets file %s: %s", path, err)
+		}
+	}
+
+	f := &File{Path: path, data: make(map[string]string)}
+	f.flatten("", values)
+
+	return f, nil
+}
+
+// sopsDecrypt returns the decrypted file contents.
+func sopsDecrypt(sopsBin string, path string) ([]byte, error) {
+
+	cmd := exec.Command(sopsBin, "--decrypt", "--output-type", "yaml", path)
+
+	var errBuf bytes.Buffer
+	cmd.Stderr = &errBuf
+
+	// To ignore SIGINTs received by bmcbutler,
+	// the commands are spawned in its own process group.
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid: true,
+	}
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt secrets file %s: %s %s", path, err, strings.TrimSpace(errBuf.String()))
+	}
+
+	return out, nil
+}
+
+// flatten stores the values, nested values are stored as <key>/<secret>.
+func (f *File) flatten(prefix string, values map[string]interface{}) {
+
+	for k, v := range values {
+		key := prefix + k
+
+		switch value := v.(type) {
+		case map[interface{}]interface{}:
+			nested := make(map[string]interface{}, len(value))
+			for nk, nv := range value {
+				nested[fmt.Sprint(nk)] = nv
+			}
+
+			f.flatten(key+"/", nested)
+		case nil:
+			f.data[key] = ""
+		default:
+			f.data[key] = fmt.Sprint(value)
+		}
+	}
+}
+
+// Get returns the secret from the file.
+func (f *File) Get(k string) (string, error) {
+
+	value, exists := f.data[k]
+	if !exists {
+		return "", &NotFoundError{Secret: k, Provider: "file", Hint: "has it been set in " + f.Path}
+	}
+
+	return value, nil
+}
@@ -0,0 +1,69 @@
+package secrets
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// passwordMinLength is the minimum length of generated passwords.
+const passwordMinLength = 8
+
+// passwordChars are the characters passwords are generated from,
+// limited to letters and digits since BMCs differ in the special characters accepted.
+var passwordChars = []string{
+	"ABCDEFGHJKLMNPQRSTUVWXYZ",
+	"abcdefghijkmnopqrstuvwxyz",
+	"23456789",
+}
+
+// GeneratePassword returns a random password of the given length,
+// with at least one upper case, lower case letter and digit, as BMC password policies require.
+func GeneratePassword(length int) (string, error) {
+
+	if length < passwordMinLength {
+		return "", fmt.Errorf("password length expected to be %d or more, got %d", passwordMinLength, length)
+	}
+
+	all := strings.Join(passwordChars, "")
+
+	password := make([]byte, length)
+	for i := range password {
+		// the first characters are drawn from each set, the rest from all sets.
+		chars := all
+		if i < len(passwordChars) {
+			chars = passwordChars[i]
+		}
+
+		c, err := randInt(len(chars))
+		if err != nil {
+			return "", err
+		}
+
+		password[i] = chars[c]
+	}
+
+	// shuffle so the character sets drawn from first are not at known positions.
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := randInt(i + 1)
+		if err != nil {
+			return "", err
+		}
+
+		password[i], password[j] = password[j], password[i]
+	}
+
+	return string(password), nil
+}
+
+// randInt returns a random int in [0, n).
+func randInt(n int) (int, error) {
+
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, fmt.Errorf("unable to generate password: %s", err)
+	}
+
+	return int(v.Int64()), nil
+}
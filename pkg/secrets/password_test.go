@@ -0,0 +1,39 @@
+package secrets
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestGeneratePassword tests passwords are of the length requested, and include each character set.
+func TestGeneratePassword(t *testing.T) {
+
+	seen := make(map[string]bool)
+	for i := 0; i < 50; i++ {
+		password, err := GeneratePassword(12)
+		if err != nil {
+			t.Fatalf("Unexpected error: %s", err)
+		}
+
+		if len(password) != 12 {
+			t.Errorf("Expected a 12 character password, got %s", password)
+		}
+
+		for _, chars := range passwordChars {
+			if !strings.ContainsAny(password, chars) {
+				t.Errorf("Expected password %s to contain one of %s", password, chars)
+			}
+		}
+
+		if seen[password] {
+			t.Errorf("Expected passwords to be unique, got %s twice", password)
+		}
+
+		seen[password] = true
+	}
+
+	_, err := GeneratePassword(passwordMinLength - 1)
+	if err == nil {
+		t.Error("Expected error for a password shorter than the minimum length")
+	}
+}
@@ -0,0 +1,108 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+)
+
+// Provider is implemented by secrets backends,
+// Get returns a NotFoundError if the secret is not found.
+type Provider interface {
+	Get(k string) (string, error)
+}
+
+// NotFoundError is returned by providers when a secret is not found.
+type NotFoundError struct {
+	Secret   string
+	Provider string
+	Hint     string
+}
+
+func (e *NotFoundError) Error() string {
+	msg := fmt.Sprintf("Secret '%s' not found in %s", e.Secret, e.Provider)
+	if e.Hint != "" {
+		msg += ", " + e.Hint
+	}
+
+	return msg
+}
+
+// IsNotFound returns true if the error is a NotFoundError.
+func IsNotFound(err error) bool {
+	var notFound *NotFoundError
+	return errors.As(err, &notFound)
+}
+
+// Chain looks up secrets from the providers in order,
+// the secret from the first provider it is found in is returned.
+type Chain []Provider
+
+// Get returns the secret from the first provider it is found in,
+// errors other than a secret not found are returned as is.
+func (c Chain) Get(k string) (string, error) {
+
+	var providers []string
+	for _, p := range c {
+		secret, err := p.Get(k)
+		if err == nil {
+			return secret, nil
+		}
+
+		var notFound *NotFoundError
+		if !errors.As(err, &notFound) {
+			return "", err
+		}
+
+		providers = append(providers, notFound.Provider)
+	}
+
+	return "", &NotFoundError{Secret: k, Provider: strings.Join(providers, ", ")}
+}
+
+// New returns the secrets providers declared chained in the order declared,
+// vault is the secrets store loaded when secretsFromVault is declared, nil otherwise.
+// Returns nil if no providers are declared.
+func New(c *config.Secrets, vault *Store) (Provider, error) {
+
+	if c == nil {
+		if vault == nil {
+			return nil, nil
+		}
+
+		return vault, nil
+	}
+
+	var chain Chain
+	for _, name := range c.Providers {
+		switch name {
+		case "env":
+			chain = append(chain, NewEnv(c.Env.Prefix))
+		case "file":
+			p, err := NewFile(c.File.Path, c.File.SopsBin)
+			if err != nil {
+				return nil, err
+			}
+
+			chain = append(chain, p)
+		case "exec":
+			chain = append(chain, NewExec(c.Exec.Bin, c.Exec.Args, c.Exec.Timeout))
+		case "vault":
+			if vault == nil {
+				return nil, errors.New("vault secrets provider declared, requires 'secretsFromVault: true'")
+			}
+
+			chain = append(chain, vault)
+		default:
+			return nil, fmt.Errorf("unknown secrets provider: %s", name)
+		}
+	}
+
+	if len(chain) == 0 {
+		return nil, nil
+	}
+
+	return chain, nil
+}
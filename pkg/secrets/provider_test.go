@@ -0,0 +1,148 @@
+package secrets
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+)
+
+// writeScript writes an executable shell script to the dir and returns its path.
+func writeScript(t *testing.T, dir string, name string, script string) string {
+
+	path := filepath.Join(dir, name)
+	err := ioutil.WriteFile(path, []byte("#!/bin/sh\n"+script), 0700)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	return path
+}
+
+// TestChain tests secrets are looked up from the providers in order.
+func TestChain(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	secretsFile := filepath.Join(dir, "secrets.yml")
+	err = ioutil.WriteFile(secretsFile, []byte("Administrator: hunter2\npin: 1234\nams2:\n  Administrator: ams2-hunter2\n"), 0600)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	helper := writeScript(t, dir, "helper", `
+case "$2" in
+  Ops) echo "helper-$1";;
+  broken) echo "backend unavailable" >&2; exit 1;;
+  *) exit 2;;
+esac
+`)
+
+	os.Setenv("BMCBUTLER_TEST_Administrator", "env-hunter2")
+	defer os.Unsetenv("BMCBUTLER_TEST_Administrator")
+
+	c := &config.Secrets{
+		Providers: []string{"file", "env", "exec"},
+		Env:       &config.SecretsEnv{Prefix: "BMCBUTLER_TEST_"},
+		File:      &config.SecretsFile{Path: secretsFile},
+		Exec:      &config.SecretsExec{Bin: helper, Args: []string{"ops"}, Timeout: time.Second},
+	}
+
+	provider, err := New(c, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	expected := map[string]string{
+		"Administrator":      "hunter2",
+		"pin":                "1234",
+		"ams2/Administrator": "ams2-hunter2",
+		"Ops":                "helper-ops",
+	}
+
+	for k, v := range expected {
+		value, err := provider.Get(k)
+		if err != nil || value != v {
+			t.Errorf("Expected secret %s to be %s, got %s %v", k, v, value, err)
+		}
+	}
+
+	_, err = provider.Get("missing")
+	if !IsNotFound(err) {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+
+	_, err = provider.Get("broken")
+	if err == nil || IsNotFound(err) {
+		t.Errorf("Expected the helper error to be returned, got %v", err)
+	}
+
+	// env is looked up first when declared first.
+	c.Providers = []string{"env", "file"}
+	provider, _ = New(c, nil)
+	value, _ := provider.Get("Administrator")
+	if value != "env-hunter2" {
+		t.Errorf("Expected the env secret, got %s", value)
+	}
+
+	c.Providers = []string{"vault"}
+	_, err = New(c, nil)
+	if err == nil {
+		t.Error("Expected error for vault provider without secretsFromVault")
+	}
+}
+
+// TestEnvName tests secret names are mapped to valid env var names.
+func TestEnvName(t *testing.T) {
+
+	os.Setenv("BMC_ams2_Administrator", "ams2-hunter2")
+	defer os.Unsetenv("BMC_ams2_Administrator")
+
+	value, err := NewEnv("BMC_").Get("ams2/Administrator")
+	if err != nil || value != "ams2-hunter2" {
+		t.Errorf("Expected the ams2/Administrator secret, got %s %v", value, err)
+	}
+}
+
+// TestFileSops tests SOPS encrypted files are decrypted with the sops bin.
+func TestFileSops(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "secrets")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	encrypted := filepath.Join(dir, "secrets.enc.yml")
+	err = ioutil.WriteFile(encrypted, []byte("Administrator: ENC[AES256_GCM,data:abc]\nsops:\n  age:\n    - recipient: age1xyz\n"), 0600)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	sops := writeScript(t, dir, "sops", `
+[ "$1" = "--decrypt" ] || exit 1
+echo "Administrator: hunter2"
+`)
+
+	f, err := NewFile(encrypted, sops)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	value, err := f.Get("Administrator")
+	if err != nil || value != "hunter2" {
+		t.Errorf("Expected the decrypted secret, got %s %v", value, err)
+	}
+
+	_, err = NewFile(encrypted, filepath.Join(dir, "no-sops"))
+	if err == nil {
+		t.Error("Expected error when the file can't be decrypted")
+	}
+}
@@ -3,45 +3,37 @@ package secrets
 import (
 	"fmt"
 	"strings"
-	"time"
-
-	"github.com/bmc-toolbox/bmcbutler/pkg/config"
-	vaultapi "github.com/hashicorp/vault/api"
 )
 
-// Store holds a copy of secrets from vault
+// Store holds a copy of secrets from vault, it is the vault secrets provider.
 type Store struct {
 	data map[string]string
 }
 
-// Load connects to Vault and returns a secret Store populated with secrets
-func Load(c config.Vault) (*Store, error) {
+// Load returns a secret Store populated with secrets read from the vault secrets path,
+// and the named secrets paths, secrets under the named paths are stored as <name>/<secret>.
+func Load(v *Client) (*Store, error) {
 
 	s := &Store{data: make(map[string]string)}
-	v, err := vaultapi.NewClient(
-		&vaultapi.Config{
-			Address:    c.HostAddress,
-			Timeout:    20 * time.Second,
-			MaxRetries: 5,
-		},
-	)
 
+	secrets, err := v.ReadKV(v.config.SecretsPath)
 	if err != nil {
 		return s, err
 	}
 
-	v.SetToken(c.Token)
-	secrets, err := v.Logical().Read(c.SecretsPath)
-	if err != nil {
-		return s, err
+	for k, value := range secrets {
+		s.data[k] = value
 	}
 
-	if secrets == nil {
-		return s, fmt.Errorf("read on vault secrets path %s returned nil", c.SecretsPath)
-	}
+	for name, path := range v.config.SecretsPaths {
+		secrets, err := v.ReadKV(path)
+		if err != nil {
+			return s, err
+		}
 
-	for k, v := range secrets.Data {
-		s.data[k] = v.(string)
+		for k, value := range secrets {
+			s.data[name+"/"+k] = value
+		}
 	}
 
 	return s, nil
@@ -51,14 +43,18 @@ func Load(c config.Vault) (*Store, error) {
 func (s *Store) Get(k string) (string, error) {
 	value, exists := s.data[k]
 	if !exists {
-		return "", fmt.Errorf("Secret '%s' not found, has it been set in vault under vault.SecretsPath", k)
+		return "", &NotFoundError{
+			Secret:   k,
+			Provider: "vault",
+			Hint:     "has it been set in vault under vault.SecretsPath or vault.SecretsPaths",
+		}
 	}
 
 	return value, nil
 }
 
-// GetSignerToken is a helper method to retrieve and return the signer token key
-func (s *Store) GetSignerToken(v string) (string, error) {
+// GetSignerToken is a helper to retrieve and return the signer token key from the secrets provider
+func GetSignerToken(p Provider, v string) (string, error) {
 
 	prefix := "lookup_secret::"
 
@@ -71,7 +67,7 @@ func (s *Store) GetSignerToken(v string) (string, error) {
 		return "", fmt.Errorf("signer token value %s declares invalid lookup parameter", v)
 	}
 
-	secret, err := s.Get(lookup)
+	secret, err := p.Get(lookup)
 	if err != nil {
 		return secret, err
 	}
@@ -79,8 +75,8 @@ func (s *Store) GetSignerToken(v string) (string, error) {
 	return secret, nil
 }
 
-// SetCredentials updates credentials that contain the lookup_secret keyword
-func (s *Store) SetCredentials(config []map[string]string) ([]map[string]string, error) {
+// SetCredentials updates credentials that contain the lookup_secret keyword with secrets from the provider
+func SetCredentials(p Provider, config []map[string]string) ([]map[string]string, error) {
 
 	lookupPrefix := "lookup_secret::"
 	// config is a []map[string]string
@@ -93,7 +89,7 @@ func (s *Store) SetCredentials(config []map[string]string) ([]map[string]string,
 					return config, fmt.Errorf("config credentials key %s declares invalid lookup parameter", k)
 				}
 
-				secret, err := s.Get(lookup)
+				secret, err := p.Get(lookup)
 				if err != nil {
 					return config, err
 				}
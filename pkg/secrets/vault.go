@@ -0,0 +1,308 @@
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"time"
+
+	vaultapi "github.com/hashicorp/vault/api"
+	"github.com/sirupsen/logrus"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+)
+
+// minTokenTTL is the token TTL below which a new token is obtained by logging in again,
+// when renewals no longer extend the token TTL.
+const minTokenTTL = 2 * time.Minute
+
+// Client is a Vault client authenticated with the vault config token or auth method.
+type Client struct {
+	*vaultapi.Client
+	config config.Vault
+	ttl    time.Duration //token TTL as of the last login, renewal.
+}
+
+// NewClient returns a Vault client for the vault config,
+// if an auth method is declared, the client logs in to obtain a token.
+func NewClient(c config.Vault) (*Client, error) {
+
+	vaultConfig := &vaultapi.Config{
+		Address:    c.HostAddress,
+		Timeout:    20 * time.Second,
+		MaxRetries: 5,
+	}
+
+	if c.Auth != nil && (c.Auth.Method == "cert" || c.Auth.CACert != "") {
+		err := vaultConfig.ConfigureTLS(&vaultapi.TLSConfig{
+			CACert:     c.Auth.CACert,
+			ClientCert: c.Auth.ClientCert,
+			ClientKey:  c.Auth.ClientKey,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	v, err := vaultapi.NewClient(vaultConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &Client{Client: v, config: c}
+	if c.Auth == nil {
+		v.SetToken(c.Token)
+		return client, nil
+	}
+
+	err = client.login()
+	if err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// login authenticates with the auth method declared and sets the token obtained.
+func (c *Client) login() error {
+
+	auth := c.config.Auth
+	data := make(map[string]interface{})
+
+	switch auth.Method {
+	case "approle":
+		secretID := auth.SecretID
+		if auth.SecretIDFromFile != "" {
+			b, err := ioutil.ReadFile(auth.SecretIDFromFile)
+			if err != nil {
+				return fmt.Errorf("vault approle secretID load from file %s failed: %s", auth.SecretIDFromFile, err)
+			}
+
+			secretID = strings.TrimSpace(string(b))
+		}
+
+		data["role_id"] = auth.RoleID
+		data["secret_id"] = secretID
+	case "kubernetes":
+		b, err := ioutil.ReadFile(auth.ServiceAccountTokenFile)
+		if err != nil {
+			return fmt.Errorf("vault kubernetes service account token load from file %s failed: %s", auth.ServiceAccountTokenFile, err)
+		}
+
+		data["role"] = auth.Role
+		data["jwt"] = strings.TrimSpace(string(b))
+	case "cert":
+		if auth.Role != "" {
+			data["name"] = auth.Role
+		}
+	default:
+		return fmt.Errorf("unknown vault auth method: %s", auth.Method)
+	}
+
+	// the login request is not to be made with an expired token.
+	c.ClearToken()
+
+	secret, err := c.Logical().Write(fmt.Sprintf("auth/%s/login", strings.Trim(auth.Mount, "/")), data)
+	if err != nil {
+		return fmt.Errorf("vault %s login failed: %s", auth.Method, err)
+	}
+
+	if secret == nil || secret.Auth == nil || secret.Auth.ClientToken == "" {
+		return fmt.Errorf("vault %s login returned no token", auth.Method)
+	}
+
+	c.SetToken(secret.Auth.ClientToken)
+	c.ttl = time.Duration(secret.Auth.LeaseDuration) * time.Second
+
+	return nil
+}
+
+// renewToken renews the token, or logs in again if the token can't be renewed,
+// returns the TTL of the token, zero if the token does not expire.
+func (c *Client) renewToken() (time.Duration, error) {
+
+	secret, err := c.Auth().Token().RenewSelf(0)
+	if err == nil && secret != nil && secret.Auth != nil {
+		c.ttl = time.Duration(secret.Auth.LeaseDuration) * time.Second
+	}
+
+	// renewals no longer extend the token beyond its max TTL.
+	if c.config.Auth != nil && (err != nil || c.ttl < minTokenTTL) {
+		err = c.login()
+	}
+
+	return c.ttl, err
+}
+
+// Renew keeps the token valid until the stop channel is closed,
+// the token is renewed when two thirds of its TTL has elapsed.
+func (c *Client) Renew(log *logrus.Logger, stopChan <-chan struct{}) {
+
+	component := "vault"
+
+	if c.config.Auth == nil {
+		secret, err := c.Auth().Token().LookupSelf()
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"component": component,
+				"Error":     err,
+			}).Warn("Unable to lookup vault token, token not renewed.")
+			return
+		}
+
+		c.ttl, _ = secret.TokenTTL()
+		renewable, _ := secret.TokenIsRenewable()
+
+		if c.ttl == 0 {
+			return
+		}
+
+		if !renewable {
+			log.WithFields(logrus.Fields{
+				"component": component,
+				"TTL":       c.ttl,
+			}).Warn("Vault token not renewable, token expires before long runs complete.")
+			return
+		}
+	}
+
+	for {
+		wait := c.ttl * 2 / 3
+		if wait <= 0 {
+			wait = time.Minute
+		}
+
+		select {
+		case <-stopChan:
+			return
+		case <-time.After(wait):
+		}
+
+		ttl, err := c.renewToken()
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"component": component,
+				"Error":     err,
+			}).Warn("Unable to renew vault token.")
+
+			// retry before the token expires.
+			c.ttl = ttl / 2
+			continue
+		}
+
+		log.WithFields(logrus.Fields{
+			"component": component,
+			"TTL":       ttl,
+		}).Debug("Vault token renewed.")
+	}
+}
+
+// kvPath returns the KV read path and the version query for the secrets path,
+// for KV v2 the data/ prefix is inserted after the mount, and a version may be declared as path?version=N.
+func (c *Client) kvPath(path string) (string, map[string][]string, error) {
+
+	var query map[string][]string
+	if idx := strings.Index(path, "?"); idx >= 0 {
+		values, err := url.ParseQuery(path[idx+1:])
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid secrets path %s: %s", path, err)
+		}
+
+		path, query = path[:idx], values
+	}
+
+	path = strings.Trim(path, "/")
+	if c.config.KVVersion != 2 {
+		return path, query, nil
+	}
+
+	mount := strings.Trim(c.config.KVMount, "/")
+	if mount == "" {
+		mount = strings.SplitN(path, "/", 2)[0]
+	}
+
+	if !strings.HasPrefix(path, mount+"/") {
+		return "", nil, fmt.Errorf("secrets path %s expected to be under the KV mount %s", path, mount)
+	}
+
+	rest := strings.TrimPrefix(path, mount+"/")
+	if strings.HasPrefix(rest, "data/") {
+		return path, query, nil
+	}
+
+	return mount + "/data/" + rest, query, nil
+}
+
+// ReadKV returns the secrets at the KV path,
+// values that are not strings are returned in their JSON form.
+func (c *Client) ReadKV(path string) (map[string]string, error) {
+
+	readPath, query, err := c.kvPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := c.Logical().ReadWithData(readPath, query)
+	if err != nil {
+		return nil, err
+	}
+
+	if secret == nil || secret.Data == nil {
+		return nil, &NotFoundError{Secret: path, Provider: "vault", Hint: "read on vault secrets path returned nil"}
+	}
+
+	data := secret.Data
+	if c.config.KVVersion == 2 {
+		data, _ = secret.Data["data"].(map[string]interface{})
+		if data == nil {
+			return nil, &NotFoundError{Secret: path, Provider: "vault", Hint: "read on vault secrets path returned no data, has the version been deleted"}
+		}
+	}
+
+	values := make(map[string]string, len(data))
+	for k, v := range data {
+		switch value := v.(type) {
+		case string:
+			values[k] = value
+		case nil:
+			values[k] = ""
+		default:
+			b, err := json.Marshal(value)
+			if err != nil {
+				return nil, fmt.Errorf("secret %s at %s: %s", k, path, err)
+			}
+
+			values[k] = string(b)
+		}
+	}
+
+	return values, nil
+}
+
+// WriteKV writes the secrets to the KV path, replacing the secrets at the path,
+// for KV v2 a new version of the secrets is written.
+func (c *Client) WriteKV(path string, values map[string]string) error {
+
+	writePath, _, err := c.kvPath(path)
+	if err != nil {
+		return err
+	}
+
+	data := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		data[k] = v
+	}
+
+	if c.config.KVVersion == 2 {
+		data = map[string]interface{}{"data": data}
+	}
+
+	_, err = c.Logical().Write(writePath, data)
+	if err != nil {
+		return fmt.Errorf("write on vault secrets path %s failed: %s", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,212 @@
+package secrets
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+)
+
+// vaultStandIn returns a Vault stand-in that serves an approle login,
+// a KV v2 mount at secret/ and a KV v1 mount at kv/, requests are recorded by path.
+func vaultStandIn(requests map[string]string) *httptest.Server {
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path] = r.URL.RawQuery
+
+		reply := func(v interface{}) {
+			_ = json.NewEncoder(w).Encode(v)
+		}
+
+		if r.URL.Path == "/v1/auth/approle/login" {
+			var body map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if body["role_id"] != "bmcbutler" || body["secret_id"] != "s3cr3t" {
+				w.WriteHeader(http.StatusBadRequest)
+				reply(map[string]interface{}{"errors": []string{"invalid role or secret ID"}})
+				return
+			}
+
+			reply(map[string]interface{}{
+				"auth": map[string]interface{}{"client_token": "approle-token", "lease_duration": 3600, "renewable": true},
+			})
+			return
+		}
+
+		if r.Header.Get("X-Vault-Token") != "approle-token" {
+			w.WriteHeader(http.StatusForbidden)
+			reply(map[string]interface{}{"errors": []string{"permission denied"}})
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v1/auth/token/renew-self":
+			reply(map[string]interface{}{
+				"auth": map[string]interface{}{"client_token": "approle-token", "lease_duration": 60, "renewable": true},
+			})
+		case "/v1/secret/data/baremetal/bmc":
+			reply(map[string]interface{}{
+				"data": map[string]interface{}{
+					"data":     map[string]interface{}{"Administrator": "hunter2", "pin": 1234, "enabled": true},
+					"metadata": map[string]interface{}{"version": 3},
+				},
+			})
+		case "/v1/kv/baremetal/bmc/ams2":
+			reply(map[string]interface{}{
+				"data": map[string]interface{}{"Administrator": "ams2-hunter2"},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			reply(map[string]interface{}{"errors": []string{}})
+		}
+	}))
+}
+
+// TestLoadKVv2 tests an approle login, KV v2 reads, versions and non string values.
+func TestLoadKVv2(t *testing.T) {
+
+	requests := make(map[string]string)
+	server := vaultStandIn(requests)
+	defer server.Close()
+
+	c := config.Vault{
+		HostAddress: server.URL,
+		SecretsPath: "/secret/baremetal/bmc?version=3",
+		KVVersion:   2,
+		Auth:        &config.VaultAuth{Method: "approle", Mount: "approle", RoleID: "bmcbutler", SecretID: "s3cr3t"},
+	}
+
+	client, err := NewClient(c)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if client.Token() != "approle-token" || client.ttl != time.Hour {
+		t.Fatalf("Expected approle token with 1h TTL, got %s %s", client.Token(), client.ttl)
+	}
+
+	store, err := Load(client)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if requests["/v1/secret/data/baremetal/bmc"] != "version=3" {
+		t.Errorf("Expected KV v2 read of version 3, got %+v", requests)
+	}
+
+	expected := map[string]string{"Administrator": "hunter2", "pin": "1234", "enabled": "true"}
+	for k, v := range expected {
+		value, err := store.Get(k)
+		if err != nil || value != v {
+			t.Errorf("Expected secret %s to be %s, got %s %v", k, v, value, err)
+		}
+	}
+
+	// renewals that don't extend the TTL beyond the minimum cause a login.
+	delete(requests, "/v1/auth/approle/login")
+	ttl, err := client.renewToken()
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if _, loggedIn := requests["/v1/auth/approle/login"]; !loggedIn || ttl != time.Hour {
+		t.Errorf("Expected login after renewal with a TTL below the minimum, got TTL %s", ttl)
+	}
+}
+
+// TestLoadSecretsPaths tests secrets under the named secrets paths are stored as <name>/<secret>.
+func TestLoadSecretsPaths(t *testing.T) {
+
+	requests := make(map[string]string)
+	server := vaultStandIn(requests)
+	defer server.Close()
+
+	client, err := NewClient(config.Vault{
+		HostAddress:  server.URL,
+		Token:        "approle-token",
+		SecretsPath:  "kv/baremetal/bmc/ams2",
+		SecretsPaths: map[string]string{"ams2": "kv/baremetal/bmc/ams2"},
+		KVVersion:    1,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	store, err := Load(client)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	value, err := store.Get("ams2/Administrator")
+	if err != nil || value != "ams2-hunter2" {
+		t.Errorf("Expected the ams2 path secret, got %s %v", value, err)
+	}
+
+	_, err = store.Get("lhr4/Administrator")
+	if err == nil {
+		t.Error("Expected error for a secret under an undeclared path")
+	}
+}
+
+// TestKVPath tests the KV v2 data/ prefix is inserted after the mount.
+func TestKVPath(t *testing.T) {
+
+	tests := []struct {
+		config   config.Vault
+		path     string
+		expected string
+	}{
+		{config.Vault{KVVersion: 1}, "/secret/baremetal/bmc", "secret/baremetal/bmc"},
+		{config.Vault{KVVersion: 2}, "/secret/baremetal/bmc", "secret/data/baremetal/bmc"},
+		{config.Vault{KVVersion: 2}, "secret/data/baremetal/bmc", "secret/data/baremetal/bmc"},
+		{config.Vault{KVVersion: 2, KVMount: "ops/kv"}, "ops/kv/baremetal/bmc", "ops/kv/data/baremetal/bmc"},
+	}
+
+	for _, tc := range tests {
+		c := &Client{config: tc.config}
+		path, _, err := c.kvPath(tc.path)
+		if err != nil || path != tc.expected {
+			t.Errorf("Expected %s for %s, got %s %v", tc.expected, tc.path, path, err)
+		}
+	}
+
+	c := &Client{config: config.Vault{KVVersion: 2, KVMount: "ops/kv"}}
+	_, _, err := c.kvPath("secret/baremetal/bmc")
+	if err == nil {
+		t.Error("Expected error for a path not under the KV mount")
+	}
+}
+
+// TestWriteKV tests KV v2 writes are made to the data/ path, with the secrets under data.
+func TestWriteKV(t *testing.T) {
+
+	var path string
+	var body map[string]map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.Method + " " + r.URL.Path
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(config.Vault{HostAddress: server.URL, Token: "approle-token", KVVersion: 2})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	err = client.WriteKV("secret/baremetal/rotated/CZ1234", map[string]string{"Administrator": "hunter3"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if path != "PUT /v1/secret/data/baremetal/rotated/CZ1234" {
+		t.Errorf("Expected write to the KV v2 data path, got %s", path)
+	}
+
+	if body["data"]["Administrator"] != "hunter3" {
+		t.Errorf("Expected the secret under data, got %+v", body)
+	}
+}
@@ -0,0 +1,99 @@
+package signer
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/bmc-toolbox/bmclib/cfgresources"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+)
+
+// Exec signs CSRs with an executable,
+// the CSR is passed on STDIN and the signed cert is expected on STDOUT.
+type Exec struct {
+	Name    string
+	Bin     string
+	Args    []string
+	Env     map[string]string
+	Timeout time.Duration
+}
+
+// newFakeSigner returns an exec signer for the fake signer config e.g certstrap.
+func newFakeSigner(c *config.CertSigner) *Exec {
+	return &Exec{
+		Name:    "fake",
+		Bin:     c.FakeSigner.Bin,
+		Args:    c.FakeSigner.Args,
+		Env:     map[string]string{"PASSPHRASE": c.FakeSigner.Passphrase},
+		Timeout: c.Timeout,
+	}
+}
+
+// newExecSigner returns an exec signer for the exec signer config.
+func newExecSigner(c *config.CertSigner) *Exec {
+
+	env := make(map[string]string)
+	for k, v := range c.ExecSigner.Env {
+		env[k] = v
+	}
+
+	return &Exec{
+		Name:    "exec",
+		Bin:     c.ExecSigner.Bin,
+		Args:    c.ExecSigner.Args,
+		Env:     env,
+		Timeout: c.Timeout,
+	}
+}
+
+// Sign passes the CSR to the executable and returns its output.
+func (e *Exec) Sign(csr []byte, attributes *cfgresources.HTTPSCertAttributes) ([]byte, error) {
+
+	if e.Bin == "" {
+		return nil, &Error{Signer: e.Name, Err: errors.New("no signer binary declared in config")}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, e.Bin, e.Args...)
+
+	for k, v := range e.Env {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	var outBuf, errBuf bytes.Buffer
+	cmd.Stdout = &outBuf
+	cmd.Stderr = &errBuf
+	cmd.Stdin = bytes.NewBuffer(csr)
+
+	// To ignore SIGINTs received by the parent process,
+	// this is to allow bmcbutler to gracefully handle ongoing goroutines,
+	// this causes the commands to be spawned in its own process group.
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Setpgid: true,
+	}
+
+	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, &Error{Signer: e.Name, Err: fmt.Errorf("%s timed out after %s", e.Bin, e.Timeout)}
+	}
+
+	if err != nil {
+		stdErr := strings.TrimSpace(errBuf.String())
+		if stdErr == "" {
+			stdErr = err.Error()
+		}
+
+		return nil, &Error{Signer: e.Name, Err: fmt.Errorf("%s returned error: %s", e.Bin, stdErr)}
+	}
+
+	return outBuf.Bytes(), nil
+}
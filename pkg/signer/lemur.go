@@ -0,0 +1,215 @@
+package signer
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/bmc-toolbox/bmclib/cfgresources"
+	"github.com/sirupsen/logrus"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+)
+
+// Lemur signs CSRs with the Lemur API.
+type Lemur struct {
+	Endpoint      string
+	Key           string
+	Authority     string
+	Owner         string
+	ValidityYears string
+	Notify        bool
+	client        *http.Client
+	log           *logrus.Logger
+}
+
+// lemurRequest is the CSR POST payload.
+type lemurRequest struct {
+	Owner         string          `json:"owner"`
+	CommonName    string          `json:"commonName"`
+	ValidityYears string          `json:"validityYears"`
+	Notify        bool            `json:"notify"`
+	CSR           string          `json:"csr"`
+	Authority     lemurAuthority  `json:"authority"`
+	Extensions    lemurExtensions `json:"extensions"`
+}
+
+type lemurAuthority struct {
+	Name string `json:"name"`
+}
+
+type lemurExtensions struct {
+	SubjectAltNames lemurSubjectAltNames `json:"subAltNames"`
+}
+
+type lemurSubjectAltNames struct {
+	Names []lemurName `json:"names"`
+}
+
+type lemurName struct {
+	NameType string `json:"nameType"`
+	Value    string `json:"value"`
+}
+
+// lemurResponse is the response payload.
+type lemurResponse struct {
+	Chain string `json:"chain"`
+	Body  string `json:"body"`
+}
+
+// newLemurSigner returns the Lemur API signer,
+// if the lemur signer bin is declared, CSRs are passed to the bin as before.
+func newLemurSigner(c *config.CertSigner, log *logrus.Logger) (Signer, error) {
+
+	l := c.LemurSigner
+	if l.Bin != "" {
+		env := map[string]string{
+			"KEY":      l.Key,
+			"ENDPOINT": l.Endpoint,
+		}
+
+		// if we're in trace logging, pass the debugging env var to the signer.
+		if log.IsLevelEnabled(logrus.TraceLevel) {
+			env["DEBUG_SIGNER"] = "1"
+		}
+
+		return &lemurExec{
+			Exec: Exec{Name: "lemur", Bin: l.Bin, Env: env, Timeout: c.Timeout},
+			args: []string{
+				"--valid-years", l.ValidityYears,
+				"--authority", l.Authority,
+				"--owner", l.Owner,
+			},
+		}, nil
+	}
+
+	if l.Endpoint == "" {
+		return nil, errors.New("lemur signer requires an endpoint to be declared")
+	}
+
+	validityYears := l.ValidityYears
+	if validityYears == "" {
+		validityYears = "1"
+	}
+
+	return &Lemur{
+		Endpoint:      l.Endpoint,
+		Key:           l.Key,
+		Authority:     l.Authority,
+		Owner:         l.Owner,
+		ValidityYears: validityYears,
+		Notify:        l.Notify,
+		client: &http.Client{
+			Timeout: c.Timeout,
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: l.InsecureSkipVerify}, // nolint: gosec
+			},
+		},
+		log: log,
+	}, nil
+}
+
+// lemurExec passes CSRs to the lemur signer bin, with the common name as an arg.
+type lemurExec struct {
+	Exec
+	args []string
+}
+
+func (l *lemurExec) Sign(csr []byte, attributes *cfgresources.HTTPSCertAttributes) ([]byte, error) {
+	e := l.Exec
+	e.Args = append(append([]string{}, l.args...), "--common-name", attributes.CommonName)
+	return e.Sign(csr, attributes)
+}
+
+// Sign posts the CSR to the Lemur API and returns the signed cert, followed by the chain if any.
+func (l *Lemur) Sign(csr []byte, attributes *cfgresources.HTTPSCertAttributes) ([]byte, error) {
+
+	commonName := attributes.CommonName
+	names := []lemurName{{NameType: "DNSName", Value: commonName}}
+
+	dnsNames, ips := altNames(attributes)
+	for _, name := range dnsNames {
+		if name != commonName {
+			names = append(names, lemurName{NameType: "DNSName", Value: name})
+		}
+	}
+
+	for _, ip := range ips {
+		names = append(names, lemurName{NameType: "IPAddress", Value: ip.String()})
+	}
+
+	request := lemurRequest{
+		Owner:         l.Owner,
+		CommonName:    commonName,
+		ValidityYears: l.ValidityYears,
+		Notify:        l.Notify,
+		CSR:           string(csr),
+		Authority:     lemurAuthority{Name: l.Authority},
+		Extensions: lemurExtensions{
+			SubjectAltNames: lemurSubjectAltNames{
+				Names: names,
+			},
+		},
+	}
+
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return nil, &Error{Signer: "lemur", Err: err}
+	}
+
+	req, err := http.NewRequest("POST", l.Endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, &Error{Signer: "lemur", Err: err}
+	}
+
+	req.Header.Add("Authorization", "Bearer "+l.Key)
+	req.Header.Add("Content-Type", "application/json")
+
+	l.log.WithFields(logrus.Fields{
+		"component":  "signer",
+		"signer":     "lemur",
+		"endpoint":   l.Endpoint,
+		"authority":  l.Authority,
+		"commonName": commonName,
+	}).Trace("Posting CSR to Lemur.")
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, &Error{Signer: "lemur", Err: err}
+	}
+
+	defer resp.Body.Close()
+
+	buf, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &Error{Signer: "lemur", Err: err}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &Error{
+			Signer: "lemur",
+			Err:    fmt.Errorf("API returned non 200 response: %d, response body: %s", resp.StatusCode, string(buf)),
+		}
+	}
+
+	var response lemurResponse
+	err = json.Unmarshal(buf, &response)
+	if err != nil {
+		return nil, &Error{Signer: "lemur", Err: fmt.Errorf("unable to parse response: %s", err)}
+	}
+
+	if response.Body == "" {
+		return nil, &Error{Signer: "lemur", Err: errors.New("API returned no cert")}
+	}
+
+	chain := response.Body + "\n"
+	if response.Chain != "" {
+		chain += response.Chain + "\n"
+	}
+
+	return []byte(chain), nil
+}
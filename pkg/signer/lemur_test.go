@@ -0,0 +1,106 @@
+package signer
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bmc-toolbox/bmclib/cfgresources"
+	"github.com/sirupsen/logrus"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+)
+
+// TestLemurSign tests CSRs are posted to the Lemur API and the cert chain is returned.
+func TestLemurSign(t *testing.T) {
+
+	var received lemurRequest
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cr3t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		err := json.NewDecoder(r.Body).Decode(&received)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(lemurResponse{Body: "CERT", Chain: "CHAIN"})
+	}))
+	defer server.Close()
+
+	c := &config.CertSigner{
+		Client:  "lemurSigner",
+		Timeout: time.Second,
+		LemurSigner: &config.LemurSigner{
+			Endpoint:           server.URL,
+			Key:                "s3cr3t",
+			Authority:          "bmc-ca",
+			Owner:              "ops@example.com",
+			InsecureSkipVerify: true,
+		},
+	}
+
+	s, err := New(c, nil, nil, logrus.New())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	csr := testCSR(t, "bmc.example.com")
+	crt, err := s.Sign(csr, &cfgresources.HTTPSCertAttributes{CommonName: "bmc.example.com"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if string(crt) != "CERT\nCHAIN\n" {
+		t.Errorf("Expected cert followed by chain, got %q", crt)
+	}
+
+	if received.CommonName != "bmc.example.com" || received.Authority.Name != "bmc-ca" ||
+		received.ValidityYears != "1" || received.CSR != string(csr) ||
+		received.Extensions.SubjectAltNames.Names[0].Value != "bmc.example.com" {
+		t.Errorf("Unexpected request payload: %+v", received)
+	}
+
+	c.LemurSigner.Key = "wrong"
+	s, _ = New(c, nil, nil, logrus.New())
+	_, err = s.Sign(csr, &cfgresources.HTTPSCertAttributes{CommonName: "bmc.example.com"})
+
+	var signerErr *Error
+	if !errors.As(err, &signerErr) || signerErr.Signer != "lemur" || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Expected lemur signer error for non 200 response, got %v", err)
+	}
+}
+
+// TestLemurTimeout tests requests are abandoned after the timeout.
+func TestLemurTimeout(t *testing.T) {
+
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer server.Close()
+	defer close(done)
+
+	c := &config.CertSigner{
+		Client:      "lemurSigner",
+		Timeout:     100 * time.Millisecond,
+		LemurSigner: &config.LemurSigner{Endpoint: server.URL},
+	}
+
+	s, err := New(c, nil, nil, logrus.New())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	_, err = s.Sign(testCSR(t, "bmc.example.com"), &cfgresources.HTTPSCertAttributes{CommonName: "bmc.example.com"})
+	if err == nil {
+		t.Fatal("Expected timeout error")
+	}
+}
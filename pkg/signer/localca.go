@@ -0,0 +1,167 @@
+package signer
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"time"
+
+	"github.com/bmc-toolbox/bmclib/cfgresources"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+	"github.com/bmc-toolbox/bmcbutler/pkg/secrets"
+)
+
+// defaultValidity is the validity of certs signed when none is declared.
+const defaultValidity = 365 * 24 * time.Hour
+
+// LocalCA signs CSRs with a CA cert and key.
+type LocalCA struct {
+	Cert     *x509.Certificate
+	Key      crypto.Signer
+	Validity time.Duration
+	certPEM  []byte
+}
+
+// newLocalCASigner returns a signer for the CA cert and key read from the PEM files or Vault secrets declared.
+func newLocalCASigner(c *config.LocalCASigner, store secrets.Provider) (Signer, error) {
+
+	certPEM, err := readPEM(c.CertFile, c.CertSecret, store)
+	if err != nil {
+		return nil, &Error{Signer: "local_ca", Err: fmt.Errorf("unable to read CA cert: %s", err)}
+	}
+
+	keyPEM, err := readPEM(c.KeyFile, c.KeySecret, store)
+	if err != nil {
+		return nil, &Error{Signer: "local_ca", Err: fmt.Errorf("unable to read CA key: %s", err)}
+	}
+
+	return NewLocalCA(certPEM, keyPEM, c.Validity)
+}
+
+// NewLocalCA returns a signer for the PEM encoded CA cert and key,
+// certs signed are valid for the validity given, or a year if zero.
+func NewLocalCA(certPEM []byte, keyPEM []byte, validity time.Duration) (*LocalCA, error) {
+
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, &Error{Signer: "local_ca", Err: fmt.Errorf("invalid CA cert/key: %s", err)}
+	}
+
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, &Error{Signer: "local_ca", Err: fmt.Errorf("invalid CA cert: %s", err)}
+	}
+
+	if !cert.IsCA {
+		return nil, &Error{Signer: "local_ca", Err: errors.New("CA cert is not a CA")}
+	}
+
+	key, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, &Error{Signer: "local_ca", Err: errors.New("unsupported CA key type")}
+	}
+
+	if validity == 0 {
+		validity = defaultValidity
+	}
+
+	return &LocalCA{
+		Cert:     cert,
+		Key:      key,
+		Validity: validity,
+		certPEM:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}),
+	}, nil
+}
+
+// Sign signs the CSR and returns the signed cert followed by the CA cert,
+// the common name and subjectAltName attributes are added to the SANs requested if not present.
+func (l *LocalCA) Sign(csr []byte, attributes *cfgresources.HTTPSCertAttributes) ([]byte, error) {
+
+	commonName := attributes.CommonName
+
+	request, err := parseCSR(csr)
+	if err != nil {
+		return nil, &Error{Signer: "local_ca", Err: err}
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, &Error{Signer: "local_ca", Err: err}
+	}
+
+	subject := request.Subject
+	if commonName != "" {
+		subject.CommonName = commonName
+	}
+
+	dnsNames := request.DNSNames
+	if commonName != "" && !contains(dnsNames, commonName) {
+		dnsNames = append([]string{commonName}, dnsNames...)
+	}
+
+	ipAddresses := request.IPAddresses
+	sanNames, sanIPs := altNames(attributes)
+	for _, name := range sanNames {
+		if !contains(dnsNames, name) {
+			dnsNames = append(dnsNames, name)
+		}
+	}
+
+	for _, ip := range sanIPs {
+		if !containsIP(ipAddresses, ip) {
+			ipAddresses = append(ipAddresses, ip)
+		}
+	}
+
+	notBefore := time.Now().Add(-5 * time.Minute)
+	template := &x509.Certificate{
+		SerialNumber:   serial,
+		Subject:        subject,
+		DNSNames:       dnsNames,
+		IPAddresses:    ipAddresses,
+		EmailAddresses: request.EmailAddresses,
+		NotBefore:      notBefore,
+		NotAfter:       notBefore.Add(l.Validity),
+		KeyUsage:       x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	if template.NotAfter.After(l.Cert.NotAfter) {
+		template.NotAfter = l.Cert.NotAfter
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, l.Cert, request.PublicKey, l.Key)
+	if err != nil {
+		return nil, &Error{Signer: "local_ca", Err: err}
+	}
+
+	crt := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	return append(crt, l.certPEM...), nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsIP(ips []net.IP, ip net.IP) bool {
+	for _, v := range ips {
+		if v.Equal(ip) {
+			return true
+		}
+	}
+
+	return false
+}
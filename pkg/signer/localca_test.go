@@ -0,0 +1,85 @@
+package signer
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/bmc-toolbox/bmclib/cfgresources"
+)
+
+// TestLocalCASign tests CSRs are signed by the CA, with the common name and subjectAltName attributes as SANs.
+func TestLocalCASign(t *testing.T) {
+
+	certPEM, keyPEM := testCA(t)
+	ca, err := NewLocalCA(certPEM, keyPEM, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	attributes := &cfgresources.HTTPSCertAttributes{CommonName: "bmc-1.example.com", SubjectAltName: "192.0.2.1, bmc-1.oob.example.com"}
+	crt, err := ca.Sign(testCSR(t, "bmc_1.example.com"), attributes)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	block, rest := pem.Decode(crt)
+	if block == nil {
+		t.Fatal("Expected a PEM encoded cert")
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(rest)
+
+	_, err = cert.Verify(x509.VerifyOptions{DNSName: "bmc-1.example.com", Roots: roots})
+	if err != nil {
+		t.Errorf("Expected cert to verify against the CA cert in the chain: %s", err)
+	}
+
+	if cert.Subject.CommonName != "bmc-1.example.com" || cert.Subject.Organization[0] != "Acme B.V." {
+		t.Errorf("Unexpected cert subject: %s", cert.Subject)
+	}
+
+	if len(cert.IPAddresses) != 1 || cert.IPAddresses[0].String() != "192.0.2.1" || len(cert.DNSNames) != 2 {
+		t.Errorf("Expected the subjectAltName attributes as SANs, got %s %s", cert.DNSNames, cert.IPAddresses)
+	}
+
+	if cert.NotAfter.Sub(cert.NotBefore) > 25*time.Hour {
+		t.Errorf("Expected cert validity of 24h, got %s", cert.NotAfter.Sub(cert.NotBefore))
+	}
+
+	_, err = ca.Sign([]byte("garbage"), &cfgresources.HTTPSCertAttributes{CommonName: "bmc-1.example.com"})
+	if err == nil {
+		t.Error("Expected error for invalid CSR")
+	}
+}
+
+// TestNewLocalCA tests the default validity, and a cert not matching the key is rejected.
+func TestNewLocalCA(t *testing.T) {
+
+	certPEM, keyPEM := testCA(t)
+	ca, err := NewLocalCA(certPEM, keyPEM, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if ca.Validity != defaultValidity {
+		t.Errorf("Expected default validity, got %s", ca.Validity)
+	}
+
+	leaf, err := ca.Sign(testCSR(t, "bmc.example.com"), &cfgresources.HTTPSCertAttributes{CommonName: "bmc.example.com"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	_, err = NewLocalCA(leaf, keyPEM, 0)
+	if err == nil {
+		t.Error("Expected error for a cert not matching the key")
+	}
+}
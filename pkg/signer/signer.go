@@ -0,0 +1,132 @@
+package signer
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+
+	"github.com/bmc-toolbox/bmclib/cfgresources"
+	"github.com/sirupsen/logrus"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+	"github.com/bmc-toolbox/bmcbutler/pkg/secrets"
+)
+
+// Signer is implemented by cert signers,
+// Sign returns the PEM encoded cert chain for the given PEM encoded CSR,
+// the cert attributes are those the CSR was generated with.
+type Signer interface {
+	Sign(csr []byte, attributes *cfgresources.HTTPSCertAttributes) ([]byte, error)
+}
+
+// Error is returned when a CSR could not be signed,
+// it identifies the signer and wraps the underlying error.
+type Error struct {
+	Signer string
+	Err    error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s signer: %s", e.Signer, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ErrInvalidCSR is returned when the CSR passed to a signer could not be parsed.
+var ErrInvalidCSR = errors.New("invalid CSR")
+
+// New returns the cert signer declared in the config,
+// the secrets store is used to look up keys declared as Vault secrets and may be nil,
+// the vault client is required for the vault signer.
+func New(c *config.CertSigner, vault *secrets.Client, store secrets.Provider, log *logrus.Logger) (s Signer, err error) {
+
+	if c == nil {
+		return nil, errors.New("no cert signer declared in config")
+	}
+
+	switch c.Client {
+	case "fakeSigner":
+		s, err = newFakeSigner(c), nil
+	case "execSigner":
+		s, err = newExecSigner(c), nil
+	case "lemurSigner":
+		s, err = newLemurSigner(c, log)
+	case "localCASigner":
+		s, err = newLocalCASigner(c.LocalCASigner, store)
+	case "stepCASigner":
+		s, err = newStepCASigner(c, store, log)
+	case "vaultSigner":
+		s, err = newVaultSigner(c, vault, log)
+	default:
+		err = fmt.Errorf("unknown cert signer declared in config: %s", c.Client)
+	}
+
+	return s, err
+}
+
+// readPEM returns the PEM data from the file or the Vault secret declared.
+func readPEM(file string, secret string, store secrets.Provider) ([]byte, error) {
+
+	switch {
+	case file != "":
+		return ioutil.ReadFile(file)
+	case secret != "":
+		if store == nil {
+			return nil, fmt.Errorf("secret %s declared, requires a secrets provider to be declared", secret)
+		}
+
+		data, err := store.Get(secret)
+		return []byte(data), err
+	default:
+		return nil, errors.New("expected a PEM file or secret to be declared")
+	}
+}
+
+// altNames returns the DNS names and IP addresses declared in the cert subjectAltName attribute,
+// multiple SANs may be declared separated by commas.
+func altNames(attributes *cfgresources.HTTPSCertAttributes) (dnsNames []string, ips []net.IP) {
+
+	for _, name := range strings.Split(attributes.SubjectAltName, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		if ip := net.ParseIP(name); ip != nil {
+			ips = append(ips, ip)
+			continue
+		}
+
+		dnsNames = append(dnsNames, name)
+	}
+
+	return dnsNames, ips
+}
+
+// parseCSR parses the PEM encoded CSR and checks its signature.
+func parseCSR(csr []byte) (*x509.CertificateRequest, error) {
+
+	block, _ := pem.Decode(csr)
+	if block == nil {
+		return nil, ErrInvalidCSR
+	}
+
+	request, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", ErrInvalidCSR, err)
+	}
+
+	err = request.CheckSignature()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", ErrInvalidCSR, err)
+	}
+
+	return request, nil
+}
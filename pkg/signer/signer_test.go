@@ -0,0 +1,171 @@
+package signer
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bmc-toolbox/bmclib/cfgresources"
+	"github.com/sirupsen/logrus"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+)
+
+// testCSR returns a PEM encoded CSR for the common name.
+func testCSR(t *testing.T, commonName string) []byte {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: commonName, Organization: []string{"Acme B.V."}},
+	}, key)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})
+}
+
+// testCA returns a PEM encoded self signed CA cert and key.
+func testCA(t *testing.T) (certPEM []byte, keyPEM []byte) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "bmcbutler test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(2 * 365 * 24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+// writeFile writes the data to a file in the dir and returns its path.
+func writeFile(t *testing.T, dir string, name string, data []byte) string {
+
+	path := filepath.Join(dir, name)
+	err := ioutil.WriteFile(path, data, 0600)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	return path
+}
+
+// TestNew tests the signer declared in config is returned.
+func TestNew(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "signer")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	certPEM, keyPEM := testCA(t)
+	certFile := writeFile(t, dir, "ca.crt", certPEM)
+	keyFile := writeFile(t, dir, "ca.key", keyPEM)
+
+	tests := []struct {
+		c        *config.CertSigner
+		expected string
+	}{
+		{&config.CertSigner{Client: "fakeSigner", FakeSigner: &config.FakeSigner{Bin: "certstrap"}}, "*signer.Exec"},
+		{&config.CertSigner{Client: "execSigner", ExecSigner: &config.ExecSigner{Bin: "signer"}}, "*signer.Exec"},
+		{&config.CertSigner{Client: "lemurSigner", LemurSigner: &config.LemurSigner{Bin: "lemur-signer"}}, "*signer.lemurExec"},
+		{&config.CertSigner{Client: "lemurSigner", LemurSigner: &config.LemurSigner{Endpoint: "https://lemur"}}, "*signer.Lemur"},
+		{&config.CertSigner{Client: "localCASigner", LocalCASigner: &config.LocalCASigner{CertFile: certFile, KeyFile: keyFile}}, "*signer.LocalCA"},
+	}
+
+	for _, tc := range tests {
+		s, err := New(tc.c, nil, nil, logrus.New())
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %s", tc.c.Client, err)
+		}
+
+		if typeName(s) != tc.expected {
+			t.Errorf("Expected %s for %s, got %s", tc.expected, tc.c.Client, typeName(s))
+		}
+	}
+
+	_, err = New(&config.CertSigner{Client: "localCASigner", LocalCASigner: &config.LocalCASigner{KeySecret: "ca_key"}}, nil, nil, logrus.New())
+	var signerErr *Error
+	if !errors.As(err, &signerErr) || signerErr.Signer != "local_ca" {
+		t.Errorf("Expected local_ca signer error for secret without a store, got %v", err)
+	}
+}
+
+func typeName(s Signer) string {
+	switch s.(type) {
+	case *Exec:
+		return "*signer.Exec"
+	case *lemurExec:
+		return "*signer.lemurExec"
+	case *Lemur:
+		return "*signer.Lemur"
+	case *LocalCA:
+		return "*signer.LocalCA"
+	case *StepCA:
+		return "*signer.StepCA"
+	default:
+		return "unknown"
+	}
+}
+
+// TestExecSign tests the CSR is passed to the bin and timeouts are enforced.
+func TestExecSign(t *testing.T) {
+
+	csr := testCSR(t, "bmc.example.com")
+
+	e := &Exec{Name: "exec", Bin: "cat", Timeout: time.Second}
+	crt, err := e.Sign(csr, &cfgresources.HTTPSCertAttributes{CommonName: "bmc.example.com"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if string(crt) != string(csr) {
+		t.Errorf("Expected the bin output to be returned, got %s", crt)
+	}
+
+	e = &Exec{Name: "exec", Bin: "sleep", Args: []string{"5"}, Timeout: 100 * time.Millisecond}
+	_, err = e.Sign(csr, &cfgresources.HTTPSCertAttributes{CommonName: "bmc.example.com"})
+	if err == nil {
+		t.Fatal("Expected timeout error")
+	}
+
+	e = &Exec{Name: "exec", Bin: "false", Timeout: time.Second}
+	_, err = e.Sign(csr, &cfgresources.HTTPSCertAttributes{CommonName: "bmc.example.com"})
+	if err == nil {
+		t.Fatal("Expected error for non zero exit")
+	}
+}
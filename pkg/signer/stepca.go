@@ -0,0 +1,275 @@
+package signer
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bmc-toolbox/bmclib/cfgresources"
+	"github.com/sirupsen/logrus"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+	"github.com/bmc-toolbox/bmcbutler/pkg/secrets"
+)
+
+// StepCA signs CSRs with the step-ca sign API,
+// authorized with a one-time token signed with the JWK provisioner key.
+type StepCA struct {
+	URL         string
+	Provisioner string
+	KeyID       string
+	Key         *ecdsa.PrivateKey
+	Validity    time.Duration
+	client      *http.Client
+	log         *logrus.Logger
+}
+
+// stepCARequest is the sign POST payload.
+type stepCARequest struct {
+	CSR      string `json:"csr"`
+	OTT      string `json:"ott"`
+	NotAfter string `json:"notAfter,omitempty"`
+}
+
+// stepCAResponse is the sign response payload.
+type stepCAResponse struct {
+	Crt       string   `json:"crt"`
+	CA        string   `json:"ca"`
+	CertChain []string `json:"certChain"`
+}
+
+// newStepCASigner returns a signer for the step-ca JWK provisioner declared,
+// the provisioner key is expected to be a decrypted PEM encoded P-256 key.
+func newStepCASigner(c *config.CertSigner, store secrets.Provider, log *logrus.Logger) (Signer, error) {
+
+	s := c.StepCASigner
+	if s.URL == "" || s.Provisioner == "" || s.KeyID == "" {
+		return nil, &Error{Signer: "step_ca", Err: errors.New("url, provisioner and kid are required")}
+	}
+
+	keyPEM, err := readPEM(s.KeyFile, s.KeySecret, store)
+	if err != nil {
+		return nil, &Error{Signer: "step_ca", Err: fmt.Errorf("unable to read provisioner key: %s", err)}
+	}
+
+	key, err := parseECKey(keyPEM)
+	if err != nil {
+		return nil, &Error{Signer: "step_ca", Err: err}
+	}
+
+	tlsConfig := &tls.Config{}
+	if s.RootCA != "" {
+		rootPEM, err := ioutil.ReadFile(s.RootCA)
+		if err != nil {
+			return nil, &Error{Signer: "step_ca", Err: fmt.Errorf("unable to read root CA: %s", err)}
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(rootPEM) {
+			return nil, &Error{Signer: "step_ca", Err: fmt.Errorf("no certs found in root CA: %s", s.RootCA)}
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	return &StepCA{
+		URL:         strings.TrimSuffix(s.URL, "/"),
+		Provisioner: s.Provisioner,
+		KeyID:       s.KeyID,
+		Key:         key,
+		Validity:    s.Validity,
+		client: &http.Client{
+			Timeout:   c.Timeout,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+		log: log,
+	}, nil
+}
+
+// parseECKey parses a PEM encoded P-256 private key in SEC 1 or PKCS #8 form.
+func parseECKey(keyPEM []byte) (*ecdsa.PrivateKey, error) {
+
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, errors.New("invalid provisioner key PEM")
+	}
+
+	var key interface{}
+	key, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid provisioner key: %s", err)
+		}
+	}
+
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok || ecKey.Curve != elliptic.P256() {
+		return nil, errors.New("provisioner key expected to be a P-256 key")
+	}
+
+	return ecKey, nil
+}
+
+// token returns the one-time token authorizing the CSR to be signed for the SANs given.
+func (s *StepCA) token(commonName string, sans []string) (string, error) {
+
+	jti := make([]byte, 16)
+	_, err := rand.Read(jti)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	header := map[string]string{
+		"alg": "ES256",
+		"kid": s.KeyID,
+		"typ": "JWT",
+	}
+
+	claims := map[string]interface{}{
+		"aud":  s.URL + "/1.0/sign",
+		"iss":  s.Provisioner,
+		"sub":  commonName,
+		"sans": sans,
+		"iat":  now.Unix(),
+		"nbf":  now.Unix(),
+		"exp":  now.Add(5 * time.Minute).Unix(),
+		"jti":  hex.EncodeToString(jti),
+	}
+
+	var parts []string
+	for _, v := range []interface{}{header, claims} {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+
+		parts = append(parts, base64.RawURLEncoding.EncodeToString(b))
+	}
+
+	digest := sha256.Sum256([]byte(strings.Join(parts, ".")))
+	r, ss, err := ecdsa.Sign(rand.Reader, s.Key, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	// ES256 signatures are the 32 byte r, s values concatenated.
+	signature := make([]byte, 64)
+	rBytes, sBytes := r.Bytes(), ss.Bytes()
+	copy(signature[32-len(rBytes):32], rBytes)
+	copy(signature[64-len(sBytes):], sBytes)
+
+	return strings.Join(append(parts, base64.RawURLEncoding.EncodeToString(signature)), "."), nil
+}
+
+// Sign posts the CSR to the step-ca sign API and returns the signed cert followed by the chain.
+func (s *StepCA) Sign(csr []byte, attributes *cfgresources.HTTPSCertAttributes) ([]byte, error) {
+
+	commonName := attributes.CommonName
+
+	request, err := parseCSR(csr)
+	if err != nil {
+		return nil, &Error{Signer: "step_ca", Err: err}
+	}
+
+	sans := append([]string{}, request.DNSNames...)
+	if !contains(sans, commonName) {
+		sans = append([]string{commonName}, sans...)
+	}
+
+	sanNames, sanIPs := altNames(attributes)
+	for _, name := range sanNames {
+		if !contains(sans, name) {
+			sans = append(sans, name)
+		}
+	}
+
+	for _, ip := range append(request.IPAddresses, sanIPs...) {
+		if !contains(sans, ip.String()) {
+			sans = append(sans, ip.String())
+		}
+	}
+
+	ott, err := s.token(commonName, sans)
+	if err != nil {
+		return nil, &Error{Signer: "step_ca", Err: fmt.Errorf("unable to sign token: %s", err)}
+	}
+
+	payload := stepCARequest{CSR: string(csr), OTT: ott}
+	if s.Validity > 0 {
+		payload.NotAfter = time.Now().Add(s.Validity).UTC().Format(time.RFC3339)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, &Error{Signer: "step_ca", Err: err}
+	}
+
+	s.log.WithFields(logrus.Fields{
+		"component":   "signer",
+		"signer":      "step_ca",
+		"url":         s.URL,
+		"provisioner": s.Provisioner,
+		"commonName":  commonName,
+	}).Trace("Posting CSR to step-ca.")
+
+	resp, err := s.client.Post(s.URL+"/1.0/sign", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, &Error{Signer: "step_ca", Err: err}
+	}
+
+	defer resp.Body.Close()
+
+	buf, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &Error{Signer: "step_ca", Err: err}
+	}
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return nil, &Error{
+			Signer: "step_ca",
+			Err:    fmt.Errorf("API returned non 201 response: %d, response body: %s", resp.StatusCode, string(buf)),
+		}
+	}
+
+	var response stepCAResponse
+	err = json.Unmarshal(buf, &response)
+	if err != nil {
+		return nil, &Error{Signer: "step_ca", Err: fmt.Errorf("unable to parse response: %s", err)}
+	}
+
+	chain := response.CertChain
+	if len(chain) == 0 && response.Crt != "" {
+		chain = []string{response.Crt, response.CA}
+	}
+
+	var crt []byte
+	for _, c := range chain {
+		if c == "" {
+			continue
+		}
+
+		crt = append(crt, []byte(strings.TrimSpace(c)+"\n")...)
+	}
+
+	if len(crt) == 0 {
+		return nil, &Error{Signer: "step_ca", Err: errors.New("API returned no cert")}
+	}
+
+	return crt, nil
+}
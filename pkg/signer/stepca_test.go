@@ -0,0 +1,157 @@
+package signer
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bmc-toolbox/bmclib/cfgresources"
+	"github.com/sirupsen/logrus"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+)
+
+// verifyToken checks the one-time token is signed with the provisioner key and returns its claims.
+func verifyToken(ott string, key *ecdsa.PublicKey) (map[string]interface{}, bool) {
+
+	parts := strings.Split(ott, ".")
+	if len(parts) != 3 {
+		return nil, false
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(signature) != 64 {
+		return nil, false
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+	if !ecdsa.Verify(key, digest[:], r, s) {
+		return nil, false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, false
+	}
+
+	var claims map[string]interface{}
+	return claims, json.Unmarshal(payload, &claims) == nil
+}
+
+// TestStepCASign tests CSRs are posted to the sign API with a token signed by the provisioner key.
+func TestStepCASign(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "signer")
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	certPEM, keyPEM := testCA(t)
+	ca, err := NewLocalCA(certPEM, keyPEM, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	// the CA key doubles as the provisioner key.
+	provisionerKey, _ := ca.Key.(*ecdsa.PrivateKey)
+
+	var claims map[string]interface{}
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request stepCARequest
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil || r.URL.Path != "/1.0/sign" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var ok bool
+		claims, ok = verifyToken(request.OTT, &provisionerKey.PublicKey)
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		crt, err := ca.Sign([]byte(request.CSR), &cfgresources.HTTPSCertAttributes{CommonName: claims["sub"].(string)})
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		block, rest := pem.Decode(crt)
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(stepCAResponse{
+			Crt: string(pem.EncodeToMemory(block)),
+			CA:  string(rest),
+		})
+	}))
+	defer server.Close()
+
+	rootCA := writeFile(t, dir, "root_ca.crt", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+	keyFile := writeFile(t, dir, "provisioner.key", keyPEM)
+
+	c := &config.CertSigner{
+		Client:  "stepCASigner",
+		Timeout: time.Second,
+		StepCASigner: &config.StepCASigner{
+			URL:         server.URL,
+			Provisioner: "bmcbutler",
+			KeyID:       "kid-1",
+			KeyFile:     keyFile,
+			RootCA:      rootCA,
+		},
+	}
+
+	s, err := New(c, nil, nil, logrus.New())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	crt, err := s.Sign(testCSR(t, "bmc.example.com"), &cfgresources.HTTPSCertAttributes{CommonName: "bmc.example.com"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if claims["iss"] != "bmcbutler" || claims["aud"] != server.URL+"/1.0/sign" {
+		t.Errorf("Unexpected token claims: %+v", claims)
+	}
+
+	block, rest := pem.Decode(crt)
+	if block == nil || !strings.Contains(string(rest), "CERTIFICATE") {
+		t.Fatalf("Expected cert followed by the CA cert, got %s", crt)
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if cert.Subject.CommonName != "bmc.example.com" {
+		t.Errorf("Unexpected cert subject: %s", cert.Subject)
+	}
+
+	// a root CA the server cert is not signed by, is not trusted.
+	c.StepCASigner.RootCA = writeFile(t, dir, "other_ca.crt", certPEM)
+	s, err = New(c, nil, nil, logrus.New())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	_, err = s.Sign(testCSR(t, "bmc.example.com"), &cfgresources.HTTPSCertAttributes{CommonName: "bmc.example.com"})
+	if err == nil {
+		t.Error("Expected TLS error for untrusted server cert")
+	}
+}
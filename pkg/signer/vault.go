@@ -0,0 +1,130 @@
+package signer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bmc-toolbox/bmclib/cfgresources"
+	"github.com/sirupsen/logrus"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+	"github.com/bmc-toolbox/bmcbutler/pkg/secrets"
+)
+
+// Vault signs CSRs with the Vault PKI secrets engine sign/<role> endpoint.
+type Vault struct {
+	Mount  string
+	Role   string
+	Roles  map[string]string
+	TTL    string
+	client *secrets.Client
+	log    *logrus.Logger
+}
+
+// newVaultSigner returns a signer for the Vault PKI role declared,
+// requests are made with the Vault client the secrets are looked up with.
+func newVaultSigner(c *config.CertSigner, client *secrets.Client, log *logrus.Logger) (Signer, error) {
+
+	if client == nil {
+		return nil, &Error{Signer: "vault", Err: errors.New("expected vault configuration section missing")}
+	}
+
+	v := c.VaultSigner
+	return &Vault{
+		Mount:  strings.Trim(v.Mount, "/"),
+		Role:   v.Role,
+		Roles:  v.Roles,
+		TTL:    v.TTL,
+		client: client,
+		log:    log,
+	}, nil
+}
+
+// role returns the PKI role for the cert organizationUnit if declared, else the default role.
+func (v *Vault) role(attributes *cfgresources.HTTPSCertAttributes) string {
+
+	if role, exists := v.Roles[attributes.OrganizationUnit]; exists {
+		return role
+	}
+
+	return v.Role
+}
+
+// Sign submits the CSR to the PKI role and returns the signed cert followed by the CA chain,
+// the common name and alt names requested are the ones declared in the cert attributes.
+func (v *Vault) Sign(csr []byte, attributes *cfgresources.HTTPSCertAttributes) ([]byte, error) {
+
+	role := v.role(attributes)
+	if role == "" {
+		return nil, &Error{
+			Signer: "vault",
+			Err:    fmt.Errorf("no PKI role declared for organizationUnit %q", attributes.OrganizationUnit),
+		}
+	}
+
+	data := map[string]interface{}{
+		"csr":         string(csr),
+		"common_name": attributes.CommonName,
+		"format":      "pem",
+	}
+
+	dnsNames, ips := altNames(attributes)
+	if len(dnsNames) > 0 {
+		data["alt_names"] = strings.Join(dnsNames, ",")
+	}
+
+	if len(ips) > 0 {
+		var ipSans []string
+		for _, ip := range ips {
+			ipSans = append(ipSans, ip.String())
+		}
+
+		data["ip_sans"] = strings.Join(ipSans, ",")
+	}
+
+	if v.TTL != "" {
+		data["ttl"] = v.TTL
+	}
+
+	path := fmt.Sprintf("%s/sign/%s", v.Mount, role)
+
+	v.log.WithFields(logrus.Fields{
+		"component":  "signer",
+		"signer":     "vault",
+		"path":       path,
+		"commonName": attributes.CommonName,
+	}).Trace("Submitting CSR to Vault PKI.")
+
+	secret, err := v.client.Logical().Write(path, data)
+	if err != nil {
+		return nil, &Error{Signer: "vault", Err: err}
+	}
+
+	if secret == nil || secret.Data == nil {
+		return nil, &Error{Signer: "vault", Err: fmt.Errorf("write on %s returned no cert", path)}
+	}
+
+	certificate, _ := secret.Data["certificate"].(string)
+	if certificate == "" {
+		return nil, &Error{Signer: "vault", Err: fmt.Errorf("write on %s returned no cert", path)}
+	}
+
+	chain := []string{certificate}
+	if caChain, ok := secret.Data["ca_chain"].([]interface{}); ok && len(caChain) > 0 {
+		for _, c := range caChain {
+			if s, ok := c.(string); ok {
+				chain = append(chain, s)
+			}
+		}
+	} else if issuingCA, ok := secret.Data["issuing_ca"].(string); ok && issuingCA != "" {
+		chain = append(chain, issuingCA)
+	}
+
+	var crt []byte
+	for _, c := range chain {
+		crt = append(crt, []byte(strings.TrimSpace(c)+"\n")...)
+	}
+
+	return crt, nil
+}
@@ -0,0 +1,101 @@
+package signer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bmc-toolbox/bmclib/cfgresources"
+	"github.com/sirupsen/logrus"
+
+	"github.com/bmc-toolbox/bmcbutler/pkg/config"
+	"github.com/bmc-toolbox/bmcbutler/pkg/secrets"
+)
+
+// TestVaultSign tests CSRs are submitted to the PKI role sign endpoint with the cert attributes.
+func TestVaultSign(t *testing.T) {
+
+	var path string
+	var received map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "s3cr3t" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+
+		path = r.URL.Path
+		_ = json.NewDecoder(r.Body).Decode(&received)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{
+				"certificate": "CERT",
+				"issuing_ca":  "ISSUER",
+				"ca_chain":    []string{"ISSUER", "ROOT"},
+			},
+		})
+	}))
+	defer server.Close()
+
+	c := &config.CertSigner{
+		Client:  "vaultSigner",
+		Timeout: time.Second,
+		VaultSigner: &config.VaultSigner{
+			Mount: "pki_bmc",
+			Role:  "bmc",
+			Roles: map[string]string{"oob": "bmc-oob"},
+			TTL:   "2160h",
+		},
+	}
+
+	vault, err := secrets.NewClient(config.Vault{HostAddress: server.URL, Token: "s3cr3t"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	s, err := New(c, vault, nil, logrus.New())
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	attributes := &cfgresources.HTTPSCertAttributes{
+		CommonName:     "bmc.example.com",
+		SubjectAltName: "192.0.2.1,bmc.oob.example.com",
+	}
+
+	crt, err := s.Sign(testCSR(t, "bmc.example.com"), attributes)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if string(crt) != "CERT\nISSUER\nROOT\n" {
+		t.Errorf("Expected cert followed by the CA chain, got %q", crt)
+	}
+
+	if path != "/v1/pki_bmc/sign/bmc" {
+		t.Errorf("Expected the default role sign path, got %s", path)
+	}
+
+	if received["common_name"] != "bmc.example.com" || received["alt_names"] != "bmc.oob.example.com" ||
+		received["ip_sans"] != "192.0.2.1" || received["ttl"] != "2160h" {
+		t.Errorf("Unexpected request payload: %+v", received)
+	}
+
+	attributes.OrganizationUnit = "oob"
+	_, err = s.Sign(testCSR(t, "bmc.example.com"), attributes)
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+
+	if path != "/v1/pki_bmc/sign/bmc-oob" {
+		t.Errorf("Expected the organizationUnit role sign path, got %s", path)
+	}
+
+	vault.SetToken("wrong")
+	_, err = s.Sign(testCSR(t, "bmc.example.com"), attributes)
+	if err == nil || !strings.Contains(err.Error(), "vault signer") {
+		t.Errorf("Expected vault signer error, got %v", err)
+	}
+}
@@ -0,0 +1,185 @@
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// assetsBucket holds a bucket per asset serial,
+// each asset bucket holds an Entry per resource.
+var assetsBucket = []byte("assets")
+
+// credentialsBucket holds the fingerprint of the credential that last worked on each asset, keyed by serial.
+var credentialsBucket = []byte("credentials")
+
+// Entry records the outcome of the last apply of a resource on an asset.
+type Entry struct {
+	Hash      string    `json:"hash"` //hash of the rendered resource configuration
+	AppliedAt time.Time `json:"applied_at"`
+	Success   bool      `json:"success"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Store is a local state store of resources applied on assets.
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens the state store file, creating it if it doesn't exist.
+func Open(file string) (*Store, error) {
+
+	// the file is locked while open, don't wait on another bmcbutler holding the lock.
+	db, err := bolt.Open(file, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("unable to open state store %s: %s", file, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{assetsBucket, credentialsBucket} {
+			_, err := tx.CreateBucketIfNotExists(bucket)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return &Store{db: db}, nil
+}
+
+// Close closes the state store.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Get returns the entry recorded for the asset resource,
+// exists is false if the resource was never recorded for the asset.
+func (s *Store) Get(serial string, resource string) (entry Entry, exists bool, err error) {
+
+	err = s.db.View(func(tx *bolt.Tx) error {
+		asset := tx.Bucket(assetsBucket).Bucket([]byte(serial))
+		if asset == nil {
+			return nil
+		}
+
+		v := asset.Get([]byte(resource))
+		if v == nil {
+			return nil
+		}
+
+		exists = true
+		return json.Unmarshal(v, &entry)
+	})
+
+	return entry, exists, err
+}
+
+// Put records the entry for the asset resource.
+func (s *Store) Put(serial string, resource string, entry Entry) error {
+
+	v, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		asset, err := tx.Bucket(assetsBucket).CreateBucketIfNotExists([]byte(serial))
+		if err != nil {
+			return err
+		}
+
+		return asset.Put([]byte(resource), v)
+	})
+}
+
+// Entries returns the entries recorded for the asset, keyed by resource.
+func (s *Store) Entries(serial string) (map[string]Entry, error) {
+
+	entries := make(map[string]Entry)
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		asset := tx.Bucket(assetsBucket).Bucket([]byte(serial))
+		if asset == nil {
+			return nil
+		}
+
+		return asset.ForEach(func(k, v []byte) error {
+			var entry Entry
+			err := json.Unmarshal(v, &entry)
+			if err != nil {
+				return err
+			}
+
+			entries[string(k)] = entry
+			return nil
+		})
+	})
+
+	return entries, err
+}
+
+// Credential returns the fingerprint of the credential that last worked on the asset,
+// an empty string if none was recorded.
+func (s *Store) Credential(serial string) (fingerprint string, err error) {
+
+	err = s.db.View(func(tx *bolt.Tx) error {
+		fingerprint = string(tx.Bucket(credentialsBucket).Get([]byte(serial)))
+		return nil
+	})
+
+	return fingerprint, err
+}
+
+// PutCredential records the fingerprint of the credential that worked on the asset.
+func (s *Store) PutCredential(serial string, fingerprint string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(credentialsBucket).Put([]byte(serial), []byte(fingerprint))
+	})
+}
+
+// Unchanged returns true if the resource was last applied successfully on the asset
+// with the given configuration hash, within maxAge - a zero maxAge never expires an entry.
+func (s *Store) Unchanged(serial string, resource string, hash string, maxAge time.Duration) (bool, error) {
+
+	entry, exists, err := s.Get(serial, resource)
+	if err != nil || !exists {
+		return false, err
+	}
+
+	if !entry.Success || entry.Hash != hash {
+		return false, nil
+	}
+
+	if maxAge > 0 && time.Since(entry.AppliedAt) > maxAge {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// Hash returns a hash of the given rendered resource configuration, salted with the asset serial,
+// resource configurations hold passwords (e.g user), a salted hash keeps them from being looked up in the state store.
+func Hash(serial string, config ...interface{}) (string, error) {
+
+	b, err := json.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	h.Write([]byte(serial))
+	h.Write([]byte{0})
+	h.Write(b)
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,133 @@
+package state
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type syslog struct {
+	Server string
+	Port   int
+}
+
+// TestUnchanged tests a resource is reported unchanged only when
+// the last apply was successful with the same configuration hash.
+func TestUnchanged(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "bmcbutler-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := Open(filepath.Join(dir, "state.db"))
+	if err != nil {
+		t.Fatalf("Expected state store to open, got error: %s", err)
+	}
+	defer store.Close()
+
+	hash, err := Hash("FOOBAR", &syslog{Server: "10.0.0.1", Port: 514})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changedHash, _ := Hash("FOOBAR", &syslog{Server: "10.0.0.2", Port: 514})
+
+	unchanged, err := store.Unchanged("FOOBAR", "syslog", hash, 0)
+	if err != nil || unchanged {
+		t.Fatalf("Expected resource never applied to be changed, got %t, %v", unchanged, err)
+	}
+
+	err = store.Put("FOOBAR", "syslog", Entry{Hash: hash, AppliedAt: time.Now().Add(-2 * time.Hour), Success: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		hash     string
+		maxAge   time.Duration
+		expected bool
+	}{
+		{hash, 0, true},
+		{changedHash, 0, false},
+		{hash, time.Hour, false},
+		{hash, 3 * time.Hour, true},
+	}
+
+	for _, c := range cases {
+		unchanged, err := store.Unchanged("FOOBAR", "syslog", c.hash, c.maxAge)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if unchanged != c.expected {
+			t.Errorf("Expected unchanged %t for hash %s maxAge %s, got %t", c.expected, c.hash, c.maxAge, unchanged)
+		}
+	}
+
+	err = store.Put("FOOBAR", "syslog", Entry{Hash: hash, AppliedAt: time.Now(), Success: false, Error: "timeout"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unchanged, _ = store.Unchanged("FOOBAR", "syslog", hash, 0)
+	if unchanged {
+		t.Error("Expected resource that failed to apply to be changed.")
+	}
+}
+
+// TestHash tests configuration hashes are salted with the asset serial.
+func TestHash(t *testing.T) {
+
+	config := &syslog{Server: "10.0.0.1", Port: 514}
+
+	hash, _ := Hash("FOOBAR", config)
+	sameHash, _ := Hash("FOOBAR", &syslog{Server: "10.0.0.1", Port: 514})
+	if hash != sameHash {
+		t.Error("Expected the same configuration hash on an asset to be stable.")
+	}
+
+	otherHash, _ := Hash("ABC123", config)
+	if hash == otherHash {
+		t.Error("Expected the configuration hash to differ between assets.")
+	}
+}
+
+func TestCredential(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "bmcbutler-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := Open(filepath.Join(dir, "state.db"))
+	if err != nil {
+		t.Fatalf("Expected state store to open, got error: %s", err)
+	}
+	defer store.Close()
+
+	fingerprint, err := store.Credential("FOOBAR")
+	if err != nil || fingerprint != "" {
+		t.Fatalf("Expected no credential recorded, got %s, %v", fingerprint, err)
+	}
+
+	err = store.PutCredential("FOOBAR", "abc123")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fingerprint, err = store.Credential("FOOBAR")
+	if err != nil || fingerprint != "abc123" {
+		t.Errorf("Expected the recorded credential fingerprint, got %s, %v", fingerprint, err)
+	}
+
+	// credentials are not listed with the resources applied.
+	entries, _ := store.Entries("FOOBAR")
+	if len(entries) != 0 {
+		t.Errorf("Expected no resource entries, got %+v", entries)
+	}
+}